@@ -0,0 +1,226 @@
+//
+// dead letter queue inspector. Lists the messages the lambdas gave up on and redrives
+// selected ones, either back to the source queue or by publishing the event on the bus again
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+const usage = `usage: dlq-tool <command> [flags]
+
+commands:
+  list       list the dead lettered messages
+  redrive    send the messages back to the source queue (-target)
+  republish  publish the events on the bus again (-bus), every subscriber receives them
+
+messages are only removed from the dead letter queue once redriven, use -dryrun to see
+what would be done
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	var queueUrl string
+	var targetUrl string
+	var busName string
+	var source string
+	var limit int
+	var dryRun bool
+	var messageIds string
+	var match filter
+
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.StringVar(&queueUrl, "queue", os.Getenv("DEAD_LETTER_QUEUE_URL"), "Dead letter queue url")
+	fs.IntVar(&limit, "max", 100, "Maximum number of messages to inspect")
+	fs.StringVar(&match.lambda, "lambda", "", "Only messages failed by this lambda")
+	fs.StringVar(&match.eventName, "eventname", "", "Only events with this name")
+	fs.StringVar(&match.namespace, "namespace", "", "Only events in this namespace")
+	fs.StringVar(&match.identifier, "objid", "", "Only events for this object")
+	fs.StringVar(&messageIds, "messageids", "", "Only these messages, comma separated")
+	if command == "redrive" || command == "republish" {
+		fs.BoolVar(&dryRun, "dryrun", false, "Report what would be redriven without doing it")
+	}
+	switch command {
+	case "list":
+	case "redrive":
+		fs.StringVar(&targetUrl, "target", "", "Source queue url the messages are sent to")
+	case "republish":
+		fs.StringVar(&busName, "bus", os.Getenv("MESSAGE_BUS"), "Message bus name")
+		fs.StringVar(&source, "source", "", "Event source, defaults to the original source")
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	_ = fs.Parse(os.Args[2:])
+	match.messageIds = splitList(messageIds)
+
+	if len(queueUrl) == 0 || (command == "redrive" && len(targetUrl) == 0) || (command == "republish" && len(busName) == 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dlq, err := newQueue(queueUrl)
+	if err != nil {
+		libracommon.LogError("creating queue client (%s)", err.Error())
+		os.Exit(1)
+	}
+
+	letters, err := dlq.receive(ctx, limit)
+	if err != nil {
+		libracommon.LogError("receiving messages (%s)", err.Error())
+		dlq.release(context.WithoutCancel(ctx), letters)
+		os.Exit(1)
+	}
+
+	selected := make([]deadLetter, 0)
+	for _, dl := range letters {
+		if match.matches(dl) == true {
+			selected = append(selected, dl)
+		}
+	}
+
+	var redriven []deadLetter
+	failed := 0
+	switch command {
+	case "list":
+		list(selected)
+	case "redrive":
+		var target *queue
+		target, err = newQueue(targetUrl)
+		if err != nil {
+			break
+		}
+		redriven, failed = redrive(ctx, dlq, selected, dryRun, func(dl deadLetter) error {
+			return target.send(ctx, dl.Body)
+		})
+	case "republish":
+		buses := make(map[string]uvalibrabus.UvaBus)
+		redriven, failed = redrive(ctx, dlq, selected, dryRun, func(dl deadLetter) error {
+			if dl.Event == nil {
+				return fmt.Errorf("message does not contain a bus event")
+			}
+			evSource := source
+			if len(evSource) == 0 {
+				evSource = dl.Source
+			}
+			bus, found := buses[evSource]
+			if found == false {
+				bus, err = libracommon.NewEventBus(busName, evSource)
+				if err != nil {
+					return err
+				}
+				buses[evSource] = bus
+			}
+			return libracommon.PublishEvent(ctx, bus, dl.Event)
+		})
+	}
+
+	// everything not redriven goes back on the queue, even when interrupted
+	remaining := slices.DeleteFunc(letters, func(dl deadLetter) bool {
+		return slices.ContainsFunc(redriven, func(r deadLetter) bool { return r.MessageId == dl.MessageId })
+	})
+	dlq.release(context.WithoutCancel(ctx), remaining)
+
+	if err != nil {
+		libracommon.LogError("%s failed (%s)", command, err.Error())
+		os.Exit(1)
+	}
+	libracommon.LogInfo("inspected %d message(s), %d selected, %d redriven, %d failed", len(letters), len(selected), len(redriven), failed)
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
+
+// redrive the messages using the supplied function and remove them from the dead letter
+// queue, returns the messages removed and the number that failed
+func redrive(ctx context.Context, dlq *queue, letters []deadLetter, dryRun bool, send func(dl deadLetter) error) ([]deadLetter, int) {
+
+	redriven := make([]deadLetter, 0)
+	failed := 0
+	for _, dl := range letters {
+		if ctx.Err() != nil {
+			libracommon.LogWarning("interrupted, %d message(s) not redriven", len(letters)-len(redriven)-failed)
+			break
+		}
+
+		if dryRun == true {
+			libracommon.LogInfo("DRYRUN: would redrive %s (%s)", dl.MessageId, describe(dl))
+			continue
+		}
+
+		err := send(dl)
+		if err != nil {
+			libracommon.LogError("redriving %s (%s)", dl.MessageId, err.Error())
+			failed++
+			continue
+		}
+
+		// once sent, a failure to remove only means the message is seen again
+		err = dlq.remove(ctx, dl)
+		if err != nil {
+			libracommon.LogWarning("removing %s from the dead letter queue (%s)", dl.MessageId, err.Error())
+			continue
+		}
+		libracommon.LogInfo("redrove %s (%s)", dl.MessageId, describe(dl))
+		redriven = append(redriven, dl)
+	}
+	return redriven, failed
+}
+
+// list the messages grouped by lambda
+func list(letters []deadLetter) {
+
+	slices.SortStableFunc(letters, func(a, b deadLetter) int { return strings.Compare(a.Lambda, b.Lambda) })
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAMBDA\tMESSAGE\tEVENT\tNAMESPACE\tOBJECT\tRECEIVES\tERROR")
+	for _, dl := range letters {
+		eventName, namespace, identifier := "?", "?", "?"
+		if dl.Event != nil {
+			eventName, namespace, identifier = dl.Event.EventName, dl.Event.Namespace, dl.Event.Identifier
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", dl.Lambda, dl.MessageId, eventName, namespace, identifier, dl.Receives, dl.Error)
+	}
+	tw.Flush()
+
+	counts := make(map[string]int)
+	for _, dl := range letters {
+		counts[dl.Lambda]++
+	}
+	for _, lambda := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("%s: %d message(s)\n", lambda, counts[lambda])
+	}
+}
+
+func describe(dl deadLetter) string {
+	if dl.Event == nil {
+		return fmt.Sprintf("%s, undecodable", dl.Lambda)
+	}
+	return fmt.Sprintf("%s, %s", dl.Lambda, dl.Event.String())
+}
+
+//
+// end of file
+//
@@ -0,0 +1,111 @@
+//
+// a dead lettered message and the bus event it carries
+//
+
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// the lambda reported for messages that do not say which lambda failed them, these are
+// messages moved to the queue by SQS once their retries were exhausted
+var unknownLambda = "unknown"
+
+type deadLetter struct {
+	MessageId     string                   // the dead letter queue message identifier
+	Lambda        string                   // the lambda that failed the message
+	Error         string                   // why it failed, if known
+	Receives      int                      // the approximate receive count
+	Body          string                   // the original message body
+	Event         *uvalibrabus.UvaBusEvent // the bus event, nil if the body cannot be decoded
+	Source        string                   // the source the event was published by
+	receiptHandle string
+}
+
+func newDeadLetter(m types.Message) deadLetter {
+	dl := deadLetter{
+		MessageId:     aws.ToString(m.MessageId),
+		Lambda:        messageAttribute(m, libracommon.DeadLetterLambdaAttribute),
+		Error:         messageAttribute(m, libracommon.DeadLetterErrorAttribute),
+		Receives:      receiveCount(m),
+		Body:          aws.ToString(m.Body),
+		receiptHandle: aws.ToString(m.ReceiptHandle),
+	}
+	if len(dl.Lambda) == 0 {
+		dl.Lambda = unknownLambda
+	}
+	dl.Source, dl.Event, _ = decodeEvent(dl.Body)
+	return dl
+}
+
+// the message body is an event bridge event containing the bus event
+func decodeEvent(body string) (string, *uvalibrabus.UvaBusEvent, error) {
+	var ebEvent events.EventBridgeEvent
+	err := json.Unmarshal([]byte(body), &ebEvent)
+	if err != nil {
+		return "", nil, err
+	}
+	ev, err := uvalibrabus.MakeBusEvent(ebEvent.Detail)
+	return ebEvent.Source, ev, err
+}
+
+// filter selects dead letters, empty criteria match everything
+type filter struct {
+	lambda     string
+	eventName  string
+	namespace  string
+	identifier string
+	messageIds []string
+}
+
+func (f filter) matches(dl deadLetter) bool {
+	if len(f.lambda) != 0 && f.lambda != dl.Lambda {
+		return false
+	}
+	if len(f.messageIds) != 0 && slices.Contains(f.messageIds, dl.MessageId) == false {
+		return false
+	}
+	if len(f.eventName) == 0 && len(f.namespace) == 0 && len(f.identifier) == 0 {
+		return true
+	}
+
+	// the remaining criteria need the event
+	if dl.Event == nil {
+		return false
+	}
+	if len(f.eventName) != 0 && f.eventName != dl.Event.EventName {
+		return false
+	}
+	if len(f.namespace) != 0 && f.namespace != dl.Event.Namespace {
+		return false
+	}
+	if len(f.identifier) != 0 && f.identifier != dl.Event.Identifier {
+		return false
+	}
+	return true
+}
+
+// split a comma separated list, ignoring empty entries
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+//
+// end of file
+//
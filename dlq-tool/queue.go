@@ -0,0 +1,112 @@
+//
+// dead letter queue access. Messages are received with a visibility timeout long enough
+// for the command to run and are made visible again unless they are redriven
+//
+
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// how long received messages stay hidden from other consumers
+var visibilityTimeout = int32(300)
+
+type queue struct {
+	client   *sqs.Client
+	queueUrl string
+}
+
+func newQueue(queueUrl string) (*queue, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &queue{client: sqs.NewFromConfig(cfg), queueUrl: queueUrl}, nil
+}
+
+// receive up to limit messages, stopping early when the queue appears empty
+func (q *queue) receive(ctx context.Context, limit int) ([]deadLetter, error) {
+
+	letters := make([]deadLetter, 0)
+	for len(letters) < limit {
+		batch := int32(min(10, limit-len(letters)))
+		out, err := q.client.ReceiveMessage(ctx,
+			&sqs.ReceiveMessageInput{
+				QueueUrl:                    aws.String(q.queueUrl),
+				MaxNumberOfMessages:         batch,
+				VisibilityTimeout:           visibilityTimeout,
+				WaitTimeSeconds:             1,
+				MessageAttributeNames:       []string{"All"},
+				MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+			})
+		if err != nil {
+			return letters, err
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+		for _, m := range out.Messages {
+			letters = append(letters, newDeadLetter(m))
+		}
+	}
+	return letters, nil
+}
+
+// make the messages visible again
+func (q *queue) release(ctx context.Context, letters []deadLetter) {
+	for _, dl := range letters {
+		_, err := q.client.ChangeMessageVisibility(ctx,
+			&sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          aws.String(q.queueUrl),
+				ReceiptHandle:     aws.String(dl.receiptHandle),
+				VisibilityTimeout: 0,
+			})
+		if err != nil {
+			libracommon.LogWarning("releasing message %s, it reappears after %d seconds (%s)", dl.MessageId, visibilityTimeout, err.Error())
+		}
+	}
+}
+
+// send a message body to a queue
+func (q *queue) send(ctx context.Context, body string) error {
+	_, err := q.client.SendMessage(ctx,
+		&sqs.SendMessageInput{
+			QueueUrl:    aws.String(q.queueUrl),
+			MessageBody: aws.String(body),
+		})
+	return err
+}
+
+// remove a received message from the queue
+func (q *queue) remove(ctx context.Context, dl deadLetter) error {
+	_, err := q.client.DeleteMessage(ctx,
+		&sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(q.queueUrl),
+			ReceiptHandle: aws.String(dl.receiptHandle),
+		})
+	return err
+}
+
+func receiveCount(m types.Message) int {
+	count, _ := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	return count
+}
+
+func messageAttribute(m types.Message, name string) string {
+	if av, found := m.MessageAttributes[name]; found == true && av.StringValue != nil {
+		return *av.StringValue
+	}
+	return ""
+}
+
+//
+// end of file
+//
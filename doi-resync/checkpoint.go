@@ -0,0 +1,70 @@
+//
+// the checkpoint, one identifier per line for each work whose sync event was published.
+// Identifiers are appended as they are published so the file is current even if the run is
+// killed. Publishing is all the checkpoint records, libra-doi may still fail the sync
+//
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+type checkpoint struct {
+	file *os.File
+	done map[string]bool
+}
+
+// openCheckpoint loads the identifiers already published, creating the file if necessary
+func openCheckpoint(filename string) (*checkpoint, error) {
+
+	cp := checkpoint{done: make(map[string]bool)}
+	buf, err := os.ReadFile(filename)
+	if err != nil && errors.Is(err, fs.ErrNotExist) == false {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if len(id) != 0 {
+			cp.done[id] = true
+		}
+	}
+
+	cp.file, err = os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (cp *checkpoint) contains(id string) bool {
+	return cp.done[id]
+}
+
+func (cp *checkpoint) count() int {
+	return len(cp.done)
+}
+
+// add records the identifier as published
+func (cp *checkpoint) add(id string) error {
+	_, err := fmt.Fprintln(cp.file, id)
+	if err != nil {
+		return err
+	}
+	cp.done[id] = true
+	return nil
+}
+
+func (cp *checkpoint) close() {
+	_ = cp.file.Close()
+}
+
+//
+// end of file
+//
@@ -0,0 +1,174 @@
+//
+// bulk DOI re-sync. Selects works by their easystore fields and publishes a DOI sync command
+// for each at a controlled rate, libra-doi does the work. Progress is checkpointed so that an
+// interrupted run can be resumed
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+const usage = `usage: doi-resync [flags]
+
+publishes a command.work.doisync event for every selected work. Works already published
+(listed in the checkpoint file) are skipped, delete the checkpoint to start over. The
+checkpoint only records that the event was published: syncs that libra-doi fails end up
+in its dead letter queue (see dlq-tool) and are not retried by resuming. Use -dryrun to
+list the selected works without publishing anything
+`
+
+// the event source
+var eventSource = "doi-resync"
+
+func main() {
+
+	var esProxyUrl string
+	var busName string
+	var namespace string
+	var fieldList string
+	var from string
+	var to string
+	var rate float64
+	var limit int
+	var checkpointFile string
+	var dryRun bool
+
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&esProxyUrl, "esproxy", os.Getenv("ES_PROXY_URL"), "Easystore proxy url")
+	flag.StringVar(&busName, "bus", os.Getenv("MESSAGE_BUS"), "Message bus name")
+	flag.StringVar(&namespace, "namespace", libracommon.LibraEtdNamespace, "Object namespace")
+	flag.StringVar(&fieldList, "fields", "draft=false", "Field values the works must have, comma separated name=value pairs")
+	flag.StringVar(&from, "from", "", "Only works published on or after this date (YYYY-MM-DD)")
+	flag.StringVar(&to, "to", "", "Only works published on or before this date (YYYY-MM-DD)")
+	flag.Float64Var(&rate, "rate", 2, "Events published per second")
+	flag.IntVar(&limit, "max", 0, "Maximum number of events to publish, 0 for no limit")
+	flag.StringVar(&checkpointFile, "checkpoint", "doi-resync.published", "File recording the works whose sync event was published")
+	flag.BoolVar(&dryRun, "dryrun", false, "List the selected works without publishing anything")
+	flag.Parse()
+
+	if len(esProxyUrl) == 0 || len(namespace) == 0 || rate <= 0 || (dryRun == false && len(busName) == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sel, err := newSelection(namespace, fieldList, from, to)
+	if err != nil {
+		libracommon.LogError("%s", err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	published, err := openCheckpoint(checkpointFile)
+	if err != nil {
+		libracommon.LogError("opening checkpoint %s (%s)", checkpointFile, err.Error())
+		os.Exit(1)
+	}
+	defer published.close()
+	libracommon.LogInfo("checkpoint %s has %d published work(s)", checkpointFile, published.count())
+
+	var bus uvalibrabus.UvaBus
+	if dryRun == false {
+		bus, err = libracommon.NewEventBus(busName, eventSource)
+		if err != nil {
+			libracommon.LogError("creating event bus client (%s)", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	ids, err := sel.identifiers(ctx, esProxyUrl)
+	if err != nil {
+		libracommon.LogError("selecting works (%s)", err.Error())
+		os.Exit(1)
+	}
+
+	total := resync(ctx, ids, published, limit, rate, dryRun, func(id string) error {
+		ev := uvalibrabus.UvaBusEvent{
+			EventName:  uvalibrabus.EventCommandDoiSync,
+			Namespace:  namespace,
+			Identifier: id,
+		}
+		return libracommon.PublishEvent(ctx, bus, &ev)
+	})
+
+	libracommon.LogInfo("selected %d work(s): %d already published, %d published, %d failed", len(ids), total.skipped, total.published, total.failed)
+	if ctx.Err() != nil {
+		libracommon.LogWarning("interrupted, run again with the same checkpoint to resume")
+		os.Exit(1)
+	}
+	if total.failed != 0 {
+		os.Exit(1)
+	}
+}
+
+// the resync totals
+type totals struct {
+	skipped   int // already in the checkpoint
+	published int // published (or listed when a dry run)
+	failed    int // could not be published
+}
+
+// resync publishes an event for each identifier not already in the checkpoint, no faster
+// than rate per second. Failures are not checkpointed so a later run retries them
+func resync(ctx context.Context, ids []string, published *checkpoint, limit int, rate float64, dryRun bool, publish func(id string) error) totals {
+
+	var total totals
+	throttle := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer throttle.Stop()
+
+	for _, id := range ids {
+		if published.contains(id) == true {
+			total.skipped++
+			continue
+		}
+		if limit > 0 && total.published+total.failed >= limit {
+			libracommon.LogInfo("reached the maximum of %d event(s)", limit)
+			break
+		}
+
+		if dryRun == true {
+			libracommon.LogInfo("DRYRUN: would sync %s", id)
+			total.published++
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return total
+		case <-throttle.C:
+		}
+
+		err := publish(id)
+		if err != nil {
+			libracommon.LogError("publishing sync for %s (%s)", id, err.Error())
+			total.failed++
+			continue
+		}
+		total.published++
+
+		err = published.add(id)
+		if err != nil {
+			// not fatal, the event is published again when resuming
+			libracommon.LogWarning("checkpointing %s (%s)", id, err.Error())
+		}
+	}
+	return total
+}
+
+//
+// end of file
+//
@@ -0,0 +1,118 @@
+//
+// work selection, by easystore field values and an optional publish date range
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// the date layout of the -from and -to flags
+var dateLayout = "2006-01-02"
+
+type selection struct {
+	namespace string
+	fields    uvaeasystore.EasyStoreObjectFields
+	from      time.Time // zero for no lower bound
+	to        time.Time // zero for no upper bound
+}
+
+func newSelection(namespace string, fieldList string, from string, to string) (*selection, error) {
+
+	sel := selection{namespace: namespace, fields: uvaeasystore.DefaultEasyStoreFields()}
+	for _, pair := range strings.Split(fieldList, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		if found == false || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("field selection [%s] is not name=value", pair)
+		}
+		sel.fields[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	var err error
+	if len(from) != 0 {
+		sel.from, err = time.Parse(dateLayout, from)
+		if err != nil {
+			return nil, fmt.Errorf("from date [%s] is not YYYY-MM-DD", from)
+		}
+	}
+	if len(to) != 0 {
+		sel.to, err = time.Parse(dateLayout, to)
+		if err != nil {
+			return nil, fmt.Errorf("to date [%s] is not YYYY-MM-DD", to)
+		}
+		// the whole day is included
+		sel.to = sel.to.AddDate(0, 0, 1)
+	}
+	return &sel, nil
+}
+
+// identifiers returns the identifiers of the selected works, sorted so that runs are repeatable
+func (sel *selection) identifiers(ctx context.Context, esProxyUrl string) ([]string, error) {
+
+	es, err := libracommon.NewEasystoreReadonlyProxy(esProxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer es.Close()
+
+	objs, err := libracommon.GetEasystoreObjectsByFields(ctx, es, sel.namespace, sel.fields, uvaeasystore.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, objs.Count())
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		eso, err := objs.Next()
+		if errors.Is(err, io.EOF) == true {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sel.published(eso.Fields()["publish-date"]) == true {
+			ids = append(ids, eso.Id())
+		}
+	}
+
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// published reports if the publish date is in the range, any date will do without a range
+func (sel *selection) published(publishDate string) bool {
+	if sel.from.IsZero() == true && sel.to.IsZero() == true {
+		return true
+	}
+	published, err := time.Parse(time.RFC3339, publishDate)
+	if err != nil {
+		return false
+	}
+	if sel.from.IsZero() == false && published.Before(sel.from) == true {
+		return false
+	}
+	if sel.to.IsZero() == false && published.Before(sel.to) == false {
+		return false
+	}
+	return true
+}
+
+//
+// end of file
+//
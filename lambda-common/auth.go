@@ -2,11 +2,11 @@
 //
 //
 
-package main
+package libracommon
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -15,9 +15,9 @@ type AuthResponse struct {
 	Token   string `json:"token"`
 }
 
-func getAuthToken(client *http.Client, url string) (string, error) {
+func GetAuthToken(ctx context.Context, client *http.Client, url string) (string, error) {
 
-	payload, err := httpGet(client, url)
+	payload, err := HttpGet(ctx, client, url)
 	if err != nil {
 		return "", err
 	}
@@ -25,7 +25,7 @@ func getAuthToken(client *http.Client, url string) (string, error) {
 	resp := AuthResponse{}
 	err = json.Unmarshal(payload, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of AuthResponse (%s)\n", err.Error())
+		LogError("json unmarshal of AuthResponse (%s)", err.Error())
 		return "", err
 	}
 
@@ -0,0 +1,42 @@
+//
+// tests for the auth token helper
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func TestGetAuthToken(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/auth", 200, `{"expires":"2024-01-01T13:00:00Z","token":"a-token"}`)
+
+	token, err := libracommon.GetAuthToken(context.Background(), svc.Client(), svc.URL+"/auth")
+	if err != nil || token != "a-token" {
+		t.Errorf("expected a-token, got [%s] %v", token, err)
+	}
+}
+
+func TestGetAuthTokenFailures(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/garbled", 200, `not json`)
+
+	// no service is permanent, as is an unreadable response
+	for _, path := range []string{"/missing", "/garbled"} {
+		_, err := libracommon.GetAuthToken(context.Background(), svc.Client(), svc.URL+path)
+		if err == nil {
+			t.Errorf("%s: expected a failure", path)
+		}
+	}
+}
+
+//
+// end of file
+//
@@ -1,22 +1,24 @@
 //
+// commandline runner, used by the cmdline builds
 //
-//
-
-// include this on a cmdline build only
-//go:build cmdline
 
-package main
+package libracommon
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func main() {
+// EventProcessor processes a single bus event
+type EventProcessor func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error
+
+// RunCmdline builds a bus event from the commandline and processes it
+func RunCmdline(process EventProcessor) {
 
 	var messageId string
 	var source string
@@ -36,7 +38,7 @@ func main() {
 	flag.Parse()
 
 	if len(eventName) == 0 || len(namespace) == 0 || len(objectId) == 0 {
-		fmt.Printf("ERROR: incorrect commandline, use --help for details\n")
+		LogError("incorrect commandline, use --help for details")
 		os.Exit(1)
 	}
 
@@ -49,14 +51,27 @@ func main() {
 		ev.Detail = json.RawMessage(detail)
 	}
 
-	pl, _ := ev.Serialize()
-	err := process(messageId, source, pl)
+	// interrupting stops processing cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := ProcessEvent(ctx, process, messageId, source, ev)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		LogError("%s", err.Error())
 		os.Exit(1)
 	}
 
-	fmt.Printf("INFO: terminating normally\n")
+	LogInfo("terminating normally")
+}
+
+// ProcessEvent runs a single bus event through the processor, instrumented as it is
+// when deployed
+func ProcessEvent(ctx context.Context, process EventProcessor, messageId string, source string, ev uvalibrabus.UvaBusEvent) error {
+	pl, err := ev.Serialize()
+	if err != nil {
+		return err
+	}
+	return withLogEvent(withTracing(withMetrics(process)))(ctx, messageId, source, pl)
 }
 
 //
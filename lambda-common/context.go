@@ -0,0 +1,27 @@
+//
+// deadline handling, work stops a little before the lambda deadline so that it can
+// checkpoint and report its progress rather than being killed part way through
+//
+
+package libracommon
+
+import (
+	"context"
+	"time"
+)
+
+// DeadlineMargin is the time reserved at the end of each invocation
+var DeadlineMargin = 5 * time.Second
+
+// derive a context that is done DeadlineMargin before the lambda deadline
+func withDeadlineMargin(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if ok == false {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-DeadlineMargin))
+}
+
+//
+// end of file
+//
@@ -0,0 +1,329 @@
+//
+// the Datacite JSON payload and the mapping of an ETD work to it, shared by DOI registration
+// and preservation so that both describe a work the same way.
+// https://support.datacite.org/reference/introduction
+//
+
+package libracommon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	librametadata "github.com/uvalib/libra-metadata"
+)
+
+// the relation of the work to its related URLs
+var relatedURLRelationType = "IsSupplementedBy"
+
+type AffiliationData struct {
+	Name                        string `json:"name"`
+	SchemeURI                   string `json:"schemeUri,omitempty"`
+	AffiliationIdentifier       string `json:"affiliationIdentifier,omitempty"`
+	AffiliationIdentifierScheme string `json:"affiliationIdentifierScheme,omitempty"`
+}
+
+type TitleData struct {
+	Title string `json:"title"`
+	Lang  string `json:"lang,omitempty"`
+}
+type DescriptionData struct {
+	Description     string `json:"description"`
+	DescriptionType string `json:"descriptionType"`
+}
+type PersonData struct {
+	Name            string               `json:"name,omitempty"`
+	GivenName       string               `json:"givenName"`
+	FamilyName      string               `json:"familyName"`
+	NameType        string               `json:"nameType,omitempty"`
+	ContributorType string               `json:"contributorType,omitempty"`
+	Affiliation     []AffiliationData    `json:"affiliation,omitempty"`
+	NameIdentifiers []NameIdentifierData `json:"nameIdentifiers,omitempty"`
+}
+type NameIdentifierData struct {
+	SchemeURI            string `json:"schemeUri"`
+	NameIdentifier       string `json:"nameIdentifier"`
+	NameIdentifierScheme string `json:"nameIdentifierScheme"`
+}
+type SubjectData struct {
+	Subject string `json:"subject,omitempty"`
+}
+type RightsData struct {
+	Rights    string `json:"rights,omitempty"`
+	RightsURI string `json:"rightsUri,omitempty"`
+}
+type FundingData struct {
+	FunderName string `json:"funderName,omitempty"`
+}
+type TypeData struct {
+	ResourceType        string `json:"resourceType,omitempty"`
+	ResourceTypeGeneral string `json:"resourceTypeGeneral,omitempty"`
+}
+type RelatedIdentifierData struct {
+	RelatedIdentifier     string `json:"relatedIdentifier"`
+	RelatedIdentifierType string `json:"relatedIdentifierType"`
+	RelationType          string `json:"relationType"`
+}
+type AlternateIdentifierData struct {
+	AlternateIdentifier     string `json:"alternateIdentifier"`
+	AlternateIdentifierType string `json:"alternateIdentifierType"`
+}
+type DateData struct {
+	Date            string `json:"date"`
+	DateType        string `json:"dateType"`
+	DateInformation string `json:"dateInformation,omitempty"`
+}
+
+type PublisherData struct {
+	Name                      string `json:"name"`
+	SchemeURI                 string `json:"schemeUri,omitempty"`
+	PublisherIdentifier       string `json:"publisherIdentifier,omitempty"`
+	PublisherIdentifierScheme string `json:"publisherIdentifierScheme,omitempty"`
+}
+
+type AttributesData struct {
+	Event             string            `json:"event,omitempty"` // eg: publish
+	DOI               string            `json:"doi,omitempty"`   // Datacite generates a DOI when empty
+	Prefix            string            `json:"prefix"`
+	URL               string            `json:"url"`
+	Titles            []TitleData       `json:"titles"`
+	Descriptions      []DescriptionData `json:"descriptions,omitempty"`
+	Creators          []PersonData      `json:"creators,omitempty"`
+	Contributors      []PersonData      `json:"contributors,omitempty"`
+	Subjects          []SubjectData     `json:"subjects,omitempty"`
+	RightsList        []RightsData      `json:"rightsList,omitempty"`
+	FundingReferences []FundingData     `json:"fundingReferences,omitempty"`
+	Types             TypeData          `json:"types,omitempty"`
+	Dates             []DateData        `json:"dates,omitempty"`
+	PublicationYear   string            `json:"publicationYear,omitempty"`
+	Publisher         PublisherData     `json:"publisher"`
+
+	Language             string                    `json:"language,omitempty"`
+	RelatedIdentifiers   []RelatedIdentifierData   `json:"relatedIdentifiers,omitempty"`
+	AlternateIdentifiers []AlternateIdentifierData `json:"alternateIdentifiers,omitempty"`
+	Formats              []string                  `json:"formats,omitempty"`
+	Sizes                []string                  `json:"sizes,omitempty"`
+	Version              string                    `json:"version,omitempty"`
+	XML                  string                    `json:"xml,omitempty"` // base64 Datacite XML
+}
+
+type DataciteData struct {
+	Data struct {
+		TypeName   string         `json:"type"`
+		Attributes AttributesData `json:"attributes"`
+	} `json:"data"`
+}
+
+// UVAAffiliation with ROR ID
+func UVAAffiliation() AffiliationData {
+	return AffiliationData{
+		Name:                        uvaName,
+		SchemeURI:                   "https://ror.org",
+		AffiliationIdentifier:       uvaRor,
+		AffiliationIdentifierScheme: "ROR",
+	}
+}
+
+// UVAPublisher with ROR ID
+func UVAPublisher() PublisherData {
+	return PublisherData{
+		Name:                      uvaName,
+		PublisherIdentifier:       uvaRor,
+		SchemeURI:                 "https://ror.org",
+		PublisherIdentifierScheme: "ROR",
+	}
+}
+
+// EtdDataciteAttributes maps the ETD work to Datacite attributes. orcids are the ORCIDs looked
+// up by computing id, the work's own ORCIDs are used when nil. The DOI comes from the doi field
+// and the types from the resource type table, the prefix, URL, event and file details are left
+// to the caller
+func EtdDataciteAttributes(work *librametadata.ETDWork, fields uvaeasystore.EasyStoreObjectFields, orcids map[string]string, now func() time.Time) (AttributesData, error) {
+
+	types, err := EtdResourceType(work.Degree)
+	if err != nil {
+		return AttributesData{}, err
+	}
+
+	attributes := AttributesData{
+		DOI:               doiFromField(fields["doi"]),
+		Titles:            []TitleData{{Title: work.Title}},
+		Descriptions:      DataciteDescriptions(work.Abstract),
+		Creators:          []PersonData{DatacitePerson(work.Author, "", orcids)},
+		Contributors:      EtdAdvisors(work.Advisors, orcids),
+		Subjects:          DataciteSubjects(work.Keywords),
+		RightsList:        DataciteRights(work.License, work.LicenseURL),
+		FundingReferences: DataciteFunding(work.Sponsors),
+
+		Types:     types,
+		Publisher: UVAPublisher(),
+
+		Language:             DataciteLanguage(work.Language),
+		RelatedIdentifiers:   DataciteRelatedIdentifiers(work.RelatedURLs),
+		AlternateIdentifiers: DataciteAlternateIdentifiers(fields["source-id"]),
+	}
+	attributes.SetPublishDate(fields["publish-date"], now)
+	return attributes, nil
+}
+
+// the doi field is a url, https://doi.org/10.18130/xxxx
+func doiFromField(value string) string {
+	if idx := strings.Index(value, "10."); idx != -1 {
+		return value[idx:]
+	}
+	return ""
+}
+
+func DataciteDescriptions(abstract string) []DescriptionData {
+	if abstract == "" {
+		return []DescriptionData{}
+	}
+	return []DescriptionData{{
+		Description:     abstract,
+		DescriptionType: "Abstract",
+	}}
+}
+
+func DataciteRights(rights string, rightsURL string) []RightsData {
+	if rights == "" {
+		return []RightsData{}
+	}
+	return []RightsData{{Rights: rights, RightsURI: rightsURL}}
+}
+
+func DataciteFunding(sponsors []string) []FundingData {
+	fundingList := []FundingData{}
+	for _, sponsor := range sponsors {
+		fundingList = append(fundingList, FundingData{FunderName: sponsor})
+	}
+	return fundingList
+}
+
+// DataciteSubjects maps keywords to subjects
+func DataciteSubjects(keywords []string) []SubjectData {
+	subjects := []SubjectData{}
+	for _, keyword := range keywords {
+		subjects = append(subjects, SubjectData{Subject: keyword})
+	}
+	return subjects
+}
+
+// DataciteLanguage returns the language code, the language may already be a code
+func DataciteLanguage(language string) string {
+	if len(strings.TrimSpace(language)) == 0 {
+		return ""
+	}
+	code := LanguageCode(language)
+	if len(code) == 0 {
+		LogWarning("unknown language [%s], omitting", language)
+	}
+	return code
+}
+
+func DataciteRelatedIdentifiers(urls []string) []RelatedIdentifierData {
+	related := []RelatedIdentifierData{}
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if len(u) == 0 {
+			continue
+		}
+		ri := RelatedIdentifierData{RelatedIdentifier: u, RelatedIdentifierType: "URL", RelationType: relatedURLRelationType}
+		// DOIs are identified as such
+		if doi := DoiFromURL(u); len(doi) != 0 {
+			ri.RelatedIdentifier = doi
+			ri.RelatedIdentifierType = "DOI"
+		}
+		related = append(related, ri)
+	}
+	return related
+}
+
+// DataciteAlternateIdentifiers makes the source identifier, e.g. sis:12345, an alternate identifier
+func DataciteAlternateIdentifiers(sourceId string) []AlternateIdentifierData {
+	source, id, found := strings.Cut(sourceId, ":")
+	if found == false || len(source) == 0 || len(id) == 0 {
+		return []AlternateIdentifierData{}
+	}
+	return []AlternateIdentifierData{{AlternateIdentifier: id, AlternateIdentifierType: strings.ToUpper(source)}}
+}
+
+// DatacitePeople maps the contributors that have a name
+func DatacitePeople(contributors []librametadata.ContributorData, contribType string, orcids map[string]string) []PersonData {
+	var personList []PersonData
+	for _, person := range contributors {
+		if len(person.FirstName) > 0 || len(person.LastName) > 0 {
+			personList = append(personList, DatacitePerson(person, contribType, orcids))
+		}
+	}
+	return personList
+}
+
+// EtdAdvisors maps the advisors of an ETD. The deposit form lists the thesis advisor first and
+// the committee members after, so the first named advisor is the Supervisor and the others
+// keep the RelatedPerson type of committee members
+func EtdAdvisors(advisors []librametadata.ContributorData, orcids map[string]string) []PersonData {
+	personList := DatacitePeople(advisors, "RelatedPerson", orcids)
+	if len(personList) != 0 {
+		personList[0].ContributorType = "Supervisor"
+	}
+	return personList
+}
+
+// DatacitePerson maps a contributor, orcids as for EtdDataciteAttributes
+func DatacitePerson(contributor librametadata.ContributorData, contribType string, orcids map[string]string) PersonData {
+	var person PersonData
+	person.GivenName = contributor.FirstName
+	person.FamilyName = contributor.LastName
+	if len(person.GivenName) > 0 && len(person.FamilyName) > 0 {
+		person.Name = fmt.Sprintf("%s, %s", person.FamilyName, person.GivenName)
+	}
+	person.ContributorType = contribType
+	person.NameType = "Personal"
+	if len(contributor.ComputeID) > 0 {
+		person.Affiliation = []AffiliationData{UVAAffiliation()}
+	} else if len(contributor.Institution) > 0 {
+		person.Affiliation = []AffiliationData{{Name: contributor.Institution}}
+	}
+
+	orcid := contributor.ORCID
+	if orcids != nil {
+		orcid = orcids[contributor.ComputeID]
+	}
+	if len(orcid) > 0 {
+		person.NameIdentifiers = []NameIdentifierData{{
+			NameIdentifier:       orcid,
+			SchemeURI:            "https://orcid.org",
+			NameIdentifierScheme: "ORCID",
+		}}
+	}
+	return person
+}
+
+// SetPublishDate sets the issued date and publication year, works that are not published
+// yet get the current year
+func (attributes *AttributesData) SetPublishDate(publishDate string, now func() time.Time) {
+	if len(publishDate) == 0 {
+		currentYear := now().Year()
+		LogInfo("setting empty PublicationYear to: %d", currentYear)
+		attributes.PublicationYear = fmt.Sprintf("%d", currentYear)
+		return
+	}
+
+	parsedDate, err := time.Parse(time.RFC3339, publishDate)
+	if err != nil {
+		LogWarning("unable to parse publish date %s", err.Error())
+		return
+	}
+
+	attributes.Dates = []DateData{{
+		Date:     parsedDate.Format("2006-01-02"),
+		DateType: "Issued",
+	}}
+	attributes.PublicationYear = parsedDate.Format("2006")
+}
+
+//
+// end of file
+//
@@ -0,0 +1,186 @@
+//
+// tests for the mapping of an ETD work to Datacite attributes
+//
+
+package libracommon_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librametadata "github.com/uvalib/libra-metadata"
+)
+
+// a fixed clock
+func testNow() time.Time {
+	return time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func testWork() *librametadata.ETDWork {
+	return &librametadata.ETDWork{
+		Title:       "A Study of Things",
+		Degree:      "PHD (Doctor of Philosophy)",
+		Abstract:    "Things were studied",
+		Author:      librametadata.ContributorData{ComputeID: "ab1c", FirstName: "Ann", LastName: "Bell", ORCID: "https://orcid.org/0000-0001"},
+		Advisors:    []librametadata.ContributorData{{FirstName: "Cal", LastName: "Dunn", Institution: "Elsewhere University"}, {}},
+		Keywords:    []string{"things", "study"},
+		Language:    "English",
+		License:     "CC BY",
+		LicenseURL:  "https://creativecommons.org/licenses/by/4.0/",
+		Sponsors:    []string{"NSF"},
+		RelatedURLs: []string{"https://doi.org/10.5072/related", "https://example.edu/data", " "},
+	}
+}
+
+func TestEtdDataciteAttributes(t *testing.T) {
+	fields := uvaeasystore.EasyStoreObjectFields{
+		"doi":          "https://doi.org/10.5072/abc",
+		"publish-date": "2024-05-01T10:00:00Z",
+		"source-id":    "sis:12345",
+	}
+
+	attributes, err := libracommon.EtdDataciteAttributes(testWork(), fields, nil, testNow)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	if attributes.DOI != "10.5072/abc" {
+		t.Errorf("unexpected DOI [%s]", attributes.DOI)
+	}
+	if len(attributes.Titles) != 1 || attributes.Titles[0].Title != "A Study of Things" {
+		t.Errorf("unexpected titles %+v", attributes.Titles)
+	}
+	if attributes.Types.ResourceType != "Dissertation" || attributes.Types.ResourceTypeGeneral != "Text" {
+		t.Errorf("unexpected types %+v", attributes.Types)
+	}
+	if attributes.PublicationYear != "2024" || len(attributes.Dates) != 1 || attributes.Dates[0].Date != "2024-05-01" || attributes.Dates[0].DateType != "Issued" {
+		t.Errorf("unexpected publication dates %s %+v", attributes.PublicationYear, attributes.Dates)
+	}
+	if attributes.Language != "en" {
+		t.Errorf("unexpected language [%s]", attributes.Language)
+	}
+	if len(attributes.AlternateIdentifiers) != 1 || attributes.AlternateIdentifiers[0].AlternateIdentifier != "12345" || attributes.AlternateIdentifiers[0].AlternateIdentifierType != "SIS" {
+		t.Errorf("unexpected alternate identifiers %+v", attributes.AlternateIdentifiers)
+	}
+	if len(attributes.Subjects) != 2 || len(attributes.FundingReferences) != 1 || len(attributes.RightsList) != 1 || len(attributes.Descriptions) != 1 {
+		t.Errorf("unexpected subjects, funding, rights or descriptions %+v", attributes)
+	}
+	if attributes.Publisher.Name != libracommon.UVAPublisher().Name {
+		t.Errorf("unexpected publisher %+v", attributes.Publisher)
+	}
+
+	// the author keeps their own ORCID and the unnamed advisor is left out
+	creator := attributes.Creators[0]
+	if creator.Name != "Bell, Ann" || len(creator.NameIdentifiers) != 1 || creator.NameIdentifiers[0].NameIdentifier != "https://orcid.org/0000-0001" {
+		t.Errorf("unexpected creator %+v", creator)
+	}
+	if len(attributes.Contributors) != 1 || attributes.Contributors[0].Affiliation[0].Name != "Elsewhere University" {
+		t.Errorf("unexpected contributors %+v", attributes.Contributors)
+	}
+}
+
+func TestEtdAdvisors(t *testing.T) {
+	advisors := []librametadata.ContributorData{
+		{},
+		{FirstName: "Cal", LastName: "Dunn"},
+		{FirstName: "Dee", LastName: "Ely"},
+		{FirstName: "Fay", LastName: "Gill"},
+	}
+
+	// the first named advisor supervises, the others are committee members
+	people := libracommon.EtdAdvisors(advisors, nil)
+	if len(people) != 3 {
+		t.Fatalf("expected 3 contributors, got %+v", people)
+	}
+	for ix, expected := range []string{"Supervisor", "RelatedPerson", "RelatedPerson"} {
+		if people[ix].ContributorType != expected {
+			t.Errorf("contributor %d expected %s, got %+v", ix, expected, people[ix])
+		}
+	}
+	if people[0].Name != "Dunn, Cal" {
+		t.Errorf("expected the first named advisor to supervise, got %+v", people[0])
+	}
+
+	if people = libracommon.EtdAdvisors(nil, nil); len(people) != 0 {
+		t.Errorf("expected no contributors, got %+v", people)
+	}
+}
+
+func TestEtdDataciteAttributesUnknownDegree(t *testing.T) {
+	work := testWork()
+	work.Degree = "Certificate of Attendance"
+	_, err := libracommon.EtdDataciteAttributes(work, uvaeasystore.EasyStoreObjectFields{}, nil, testNow)
+	if libracommon.IsPermanent(err) == false {
+		t.Errorf("expected a permanent failure, got %v", err)
+	}
+}
+
+func TestDatacitePersonOrcids(t *testing.T) {
+	contributor := librametadata.ContributorData{ComputeID: "ab1c", FirstName: "Ann", LastName: "Bell", ORCID: "https://orcid.org/stale"}
+
+	// looked up ORCIDs replace the one in the work, even when there is none
+	person := libracommon.DatacitePerson(contributor, "", map[string]string{"ab1c": "https://orcid.org/current"})
+	if len(person.NameIdentifiers) != 1 || person.NameIdentifiers[0].NameIdentifier != "https://orcid.org/current" {
+		t.Errorf("expected the looked up ORCID, got %+v", person.NameIdentifiers)
+	}
+	person = libracommon.DatacitePerson(contributor, "", map[string]string{})
+	if len(person.NameIdentifiers) != 0 {
+		t.Errorf("expected no ORCID, got %+v", person.NameIdentifiers)
+	}
+
+	// UVA people are affiliated with UVA
+	if len(person.Affiliation) != 1 || person.Affiliation[0].AffiliationIdentifier != libracommon.UVAAffiliation().AffiliationIdentifier {
+		t.Errorf("expected the UVA affiliation, got %+v", person.Affiliation)
+	}
+}
+
+func TestDatacitePersonPartialName(t *testing.T) {
+	person := libracommon.DatacitePerson(librametadata.ContributorData{LastName: "Bell"}, "", nil)
+	if person.Name != "" || person.FamilyName != "Bell" || len(person.Affiliation) != 0 {
+		t.Errorf("unexpected person %+v", person)
+	}
+}
+
+func TestDataciteRelatedIdentifiers(t *testing.T) {
+	related := libracommon.DataciteRelatedIdentifiers([]string{"https://doi.org/10.5072/related", " https://example.edu/data ", ""})
+	if len(related) != 2 {
+		t.Fatalf("expected 2 related identifiers, got %+v", related)
+	}
+	if related[0].RelatedIdentifier != "10.5072/related" || related[0].RelatedIdentifierType != "DOI" {
+		t.Errorf("expected a DOI, got %+v", related[0])
+	}
+	if related[1].RelatedIdentifier != "https://example.edu/data" || related[1].RelatedIdentifierType != "URL" {
+		t.Errorf("expected a URL, got %+v", related[1])
+	}
+}
+
+func TestDataciteAlternateIdentifiers(t *testing.T) {
+	for sourceId, expected := range map[string]int{"sis:12345": 1, "optional:": 0, ":12345": 0, "12345": 0, "": 0} {
+		if ai := libracommon.DataciteAlternateIdentifiers(sourceId); len(ai) != expected {
+			t.Errorf("DataciteAlternateIdentifiers(%q) expected %d, got %+v", sourceId, expected, ai)
+		}
+	}
+}
+
+func TestSetPublishDate(t *testing.T) {
+
+	// unpublished works get the current year and no issued date
+	var attributes libracommon.AttributesData
+	attributes.SetPublishDate("", testNow)
+	if attributes.PublicationYear != "2024" || len(attributes.Dates) != 0 {
+		t.Errorf("unexpected unpublished dates %s %+v", attributes.PublicationYear, attributes.Dates)
+	}
+
+	// unusable dates are left out
+	attributes = libracommon.AttributesData{}
+	attributes.SetPublishDate("May 2024", testNow)
+	if attributes.PublicationYear != "" || len(attributes.Dates) != 0 {
+		t.Errorf("unexpected dates for a bad publish date %s %+v", attributes.PublicationYear, attributes.Dates)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,274 @@
+//
+// Datacite XML (kernel 4) rendering of the Datacite attributes, used for the Datacite xml
+// attribute and for preservation. https://datacite-metadata-schema.readthedocs.io
+//
+
+package libracommon
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+var ErrNoDOI = fmt.Errorf("work has no DOI")
+
+// the kernel 4 namespace and schema
+const dataciteKernelNamespace = "http://datacite.org/schema/kernel-4"
+const dataciteKernelSchema = "http://schema.datacite.org/meta/kernel-4/metadata.xsd"
+
+// UVA identifiers
+const uvaName = "University of Virginia"
+const uvaRor = "https://ror.org/0153tk833"
+
+type dcResource struct {
+	XMLName              xml.Name                `xml:"resource"`
+	Xmlns                string                  `xml:"xmlns,attr"`
+	XmlnsXsi             string                  `xml:"xmlns:xsi,attr"`
+	SchemaLocation       string                  `xml:"xsi:schemaLocation,attr"`
+	Identifier           dcIdentifier            `xml:"identifier"`
+	Creators             []dcPerson              `xml:"creators>creator"`
+	Titles               []string                `xml:"titles>title"`
+	Publisher            dcPublisher             `xml:"publisher"`
+	PublicationYear      string                  `xml:"publicationYear"`
+	ResourceType         dcResourceType          `xml:"resourceType"`
+	Subjects             *dcSubjects             `xml:"subjects,omitempty"`
+	Contributors         *dcContributors         `xml:"contributors,omitempty"`
+	Dates                *dcDates                `xml:"dates,omitempty"`
+	Language             string                  `xml:"language,omitempty"`
+	AlternateIdentifiers *dcAlternateIdentifiers `xml:"alternateIdentifiers,omitempty"`
+	RelatedIdentifiers   *dcRelatedIdentifiers   `xml:"relatedIdentifiers,omitempty"`
+	Sizes                *dcSizes                `xml:"sizes,omitempty"`
+	Formats              *dcFormats              `xml:"formats,omitempty"`
+	RightsList           *dcRightsList           `xml:"rightsList,omitempty"`
+	Descriptions         *dcDescriptions         `xml:"descriptions,omitempty"`
+	FundingReferences    *dcFundingReferences    `xml:"fundingReferences,omitempty"`
+}
+
+// the optional lists, encoding/xml does not omit an empty parent>child list
+type dcSubjects struct {
+	Subjects []string `xml:"subject"`
+}
+type dcContributors struct {
+	Contributors []dcPerson `xml:"contributor"`
+}
+type dcDates struct {
+	Dates []dcDate `xml:"date"`
+}
+type dcAlternateIdentifiers struct {
+	AlternateIdentifiers []dcAlternateIdentifier `xml:"alternateIdentifier"`
+}
+type dcRelatedIdentifiers struct {
+	RelatedIdentifiers []dcRelatedIdentifier `xml:"relatedIdentifier"`
+}
+type dcSizes struct {
+	Sizes []string `xml:"size"`
+}
+type dcFormats struct {
+	Formats []string `xml:"format"`
+}
+type dcRightsList struct {
+	Rights []dcRights `xml:"rights"`
+}
+type dcDescriptions struct {
+	Descriptions []dcDescription `xml:"description"`
+}
+type dcFundingReferences struct {
+	FundingReferences []dcFundingReference `xml:"fundingReference"`
+}
+
+type dcIdentifier struct {
+	Type  string `xml:"identifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dcPerson struct {
+	XMLName         xml.Name           // creator or contributor
+	ContributorType string             `xml:"contributorType,attr,omitempty"`
+	Name            dcName             // creatorName or contributorName
+	GivenName       string             `xml:"givenName,omitempty"`
+	FamilyName      string             `xml:"familyName,omitempty"`
+	NameIdentifiers []dcNameIdentifier `xml:"nameIdentifier,omitempty"`
+	Affiliations    []dcAffiliation    `xml:"affiliation,omitempty"`
+}
+
+type dcName struct {
+	XMLName  xml.Name
+	NameType string `xml:"nameType,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type dcNameIdentifier struct {
+	Scheme    string `xml:"nameIdentifierScheme,attr"`
+	SchemeURI string `xml:"schemeURI,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+type dcAffiliation struct {
+	Identifier       string `xml:"affiliationIdentifier,attr,omitempty"`
+	IdentifierScheme string `xml:"affiliationIdentifierScheme,attr,omitempty"`
+	SchemeURI        string `xml:"schemeURI,attr,omitempty"`
+	Value            string `xml:",chardata"`
+}
+
+type dcPublisher struct {
+	Identifier       string `xml:"publisherIdentifier,attr,omitempty"`
+	IdentifierScheme string `xml:"publisherIdentifierScheme,attr,omitempty"`
+	SchemeURI        string `xml:"schemeURI,attr,omitempty"`
+	Value            string `xml:",chardata"`
+}
+
+type dcResourceType struct {
+	General string `xml:"resourceTypeGeneral,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type dcDate struct {
+	Type  string `xml:"dateType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dcAlternateIdentifier struct {
+	Type  string `xml:"alternateIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dcRelatedIdentifier struct {
+	Type         string `xml:"relatedIdentifierType,attr"`
+	RelationType string `xml:"relationType,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type dcRights struct {
+	URI   string `xml:"rightsURI,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type dcDescription struct {
+	Type  string `xml:"descriptionType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dcFundingReference struct {
+	FunderName string `xml:"funderName"`
+}
+
+// RenderDataciteXML renders the attributes as Datacite kernel 4 XML. The identifier is
+// required so attributes without a DOI return ErrNoDOI
+func RenderDataciteXML(attributes *AttributesData) ([]byte, error) {
+
+	if IsDOI(attributes.DOI) == false {
+		return nil, ErrNoDOI
+	}
+
+	res := dcResource{
+		Xmlns:           dataciteKernelNamespace,
+		XmlnsXsi:        "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation:  dataciteKernelNamespace + " " + dataciteKernelSchema,
+		Identifier:      dcIdentifier{Type: "DOI", Value: attributes.DOI},
+		Creators:        xmlPeople("creator", attributes.Creators),
+		Publisher:       dcPublisher{Identifier: attributes.Publisher.PublisherIdentifier, IdentifierScheme: attributes.Publisher.PublisherIdentifierScheme, SchemeURI: attributes.Publisher.SchemeURI, Value: attributes.Publisher.Name},
+		PublicationYear: attributes.PublicationYear,
+		ResourceType:    dcResourceType{General: attributes.Types.ResourceTypeGeneral, Value: attributes.Types.ResourceType},
+		Language:        attributes.Language,
+	}
+	if len(res.ResourceType.General) == 0 {
+		res.ResourceType.General = "Text"
+	}
+	for _, t := range attributes.Titles {
+		res.Titles = append(res.Titles, t.Title)
+	}
+
+	if len(attributes.Subjects) != 0 {
+		res.Subjects = &dcSubjects{}
+		for _, s := range attributes.Subjects {
+			res.Subjects.Subjects = append(res.Subjects.Subjects, s.Subject)
+		}
+	}
+	if len(attributes.Contributors) != 0 {
+		res.Contributors = &dcContributors{Contributors: xmlPeople("contributor", attributes.Contributors)}
+	}
+	if len(attributes.Dates) != 0 {
+		res.Dates = &dcDates{}
+		for _, d := range attributes.Dates {
+			res.Dates.Dates = append(res.Dates.Dates, dcDate{Type: d.DateType, Value: d.Date})
+		}
+	}
+	if len(attributes.AlternateIdentifiers) != 0 {
+		res.AlternateIdentifiers = &dcAlternateIdentifiers{}
+		for _, ai := range attributes.AlternateIdentifiers {
+			res.AlternateIdentifiers.AlternateIdentifiers = append(res.AlternateIdentifiers.AlternateIdentifiers, dcAlternateIdentifier{Type: ai.AlternateIdentifierType, Value: ai.AlternateIdentifier})
+		}
+	}
+	if len(attributes.RelatedIdentifiers) != 0 {
+		res.RelatedIdentifiers = &dcRelatedIdentifiers{}
+		for _, ri := range attributes.RelatedIdentifiers {
+			res.RelatedIdentifiers.RelatedIdentifiers = append(res.RelatedIdentifiers.RelatedIdentifiers, dcRelatedIdentifier{Type: ri.RelatedIdentifierType, RelationType: ri.RelationType, Value: ri.RelatedIdentifier})
+		}
+	}
+	if len(attributes.Sizes) != 0 {
+		res.Sizes = &dcSizes{Sizes: attributes.Sizes}
+	}
+	if len(attributes.Formats) != 0 {
+		res.Formats = &dcFormats{Formats: attributes.Formats}
+	}
+	if len(attributes.RightsList) != 0 {
+		res.RightsList = &dcRightsList{}
+		for _, r := range attributes.RightsList {
+			res.RightsList.Rights = append(res.RightsList.Rights, dcRights{URI: r.RightsURI, Value: r.Rights})
+		}
+	}
+	if len(attributes.Descriptions) != 0 {
+		res.Descriptions = &dcDescriptions{}
+		for _, d := range attributes.Descriptions {
+			res.Descriptions.Descriptions = append(res.Descriptions.Descriptions, dcDescription{Type: d.DescriptionType, Value: d.Description})
+		}
+	}
+	if len(attributes.FundingReferences) != 0 {
+		res.FundingReferences = &dcFundingReferences{}
+		for _, f := range attributes.FundingReferences {
+			res.FundingReferences.FundingReferences = append(res.FundingReferences.FundingReferences, dcFundingReference{FunderName: f.FunderName})
+		}
+	}
+
+	buf, err := xml.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), buf...), nil
+}
+
+func xmlPeople(element string, people []PersonData) []dcPerson {
+	list := make([]dcPerson, 0, len(people))
+	for _, p := range people {
+		list = append(list, xmlPerson(element, p))
+	}
+	return list
+}
+
+func xmlPerson(element string, p PersonData) dcPerson {
+
+	person := dcPerson{
+		XMLName:         xml.Name{Local: element},
+		ContributorType: p.ContributorType,
+		Name:            dcName{XMLName: xml.Name{Local: element + "Name"}, NameType: p.NameType, Value: p.Name},
+		GivenName:       p.GivenName,
+		FamilyName:      p.FamilyName,
+	}
+	// the name is required, the payload only has one when there are both parts
+	if len(person.Name.Value) == 0 {
+		person.Name.Value = strings.TrimSpace(p.FamilyName + " " + p.GivenName)
+	}
+
+	for _, a := range p.Affiliation {
+		person.Affiliations = append(person.Affiliations, dcAffiliation{Identifier: a.AffiliationIdentifier, IdentifierScheme: a.AffiliationIdentifierScheme, SchemeURI: a.SchemeURI, Value: a.Name})
+	}
+	for _, ni := range p.NameIdentifiers {
+		person.NameIdentifiers = append(person.NameIdentifiers, dcNameIdentifier{Scheme: ni.NameIdentifierScheme, SchemeURI: ni.SchemeURI, Value: ni.NameIdentifier})
+	}
+	return person
+}
+
+//
+// end of file
+//
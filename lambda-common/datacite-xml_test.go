@@ -0,0 +1,81 @@
+//
+// tests for the Datacite XML rendering
+//
+
+package libracommon_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func TestRenderDataciteXMLNeedsDOI(t *testing.T) {
+	attributes := libracommon.AttributesData{Titles: []libracommon.TitleData{{Title: "No DOI"}}}
+	_, err := libracommon.RenderDataciteXML(&attributes)
+	if errors.Is(err, libracommon.ErrNoDOI) == false {
+		t.Errorf("expected ErrNoDOI, got %v", err)
+	}
+}
+
+func TestRenderDataciteXML(t *testing.T) {
+	fields := uvaeasystore.EasyStoreObjectFields{
+		"doi":          "https://doi.org/10.5072/abc",
+		"publish-date": "2024-05-01T10:00:00Z",
+		"source-id":    "sis:12345",
+	}
+	attributes, err := libracommon.EtdDataciteAttributes(testWork(), fields, nil, testNow)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	attributes.Titles[0].Title = "Things & <Stuff>"
+	attributes.Sizes = []string{"12345 bytes"}
+	attributes.Formats = []string{"application/pdf"}
+
+	buf, err := libracommon.RenderDataciteXML(&attributes)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	// well formed
+	var doc struct {
+		XMLName xml.Name
+		Titles  []string `xml:"titles>title"`
+	}
+	err = xml.Unmarshal(buf, &doc)
+	if err != nil {
+		t.Fatalf("expected well formed XML (%s)", err.Error())
+	}
+	if doc.XMLName.Local != "resource" || doc.Titles[0] != "Things & <Stuff>" {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	rendered := string(buf)
+	for _, expected := range []string{
+		`<identifier identifierType="DOI">10.5072/abc</identifier>`,
+		`<creatorName nameType="Personal">Bell, Ann</creatorName>`,
+		`<nameIdentifier nameIdentifierScheme="ORCID" schemeURI="https://orcid.org">https://orcid.org/0000-0001</nameIdentifier>`,
+		`<resourceType resourceTypeGeneral="Text">Dissertation</resourceType>`,
+		`<publicationYear>2024</publicationYear>`,
+		`<date dateType="Issued">2024-05-01</date>`,
+		`<alternateIdentifier alternateIdentifierType="SIS">12345</alternateIdentifier>`,
+		`<relatedIdentifier relatedIdentifierType="DOI" relationType="IsSupplementedBy">10.5072/related</relatedIdentifier>`,
+		`<language>en</language>`,
+		`<size>12345 bytes</size>`,
+		`<format>application/pdf</format>`,
+		`<funderName>NSF</funderName>`,
+		`<description descriptionType="Abstract">Things were studied</description>`,
+	} {
+		if strings.Contains(rendered, expected) == false {
+			t.Errorf("expected the XML to contain %s", expected)
+		}
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,70 @@
+//
+// Datacite helpers shared by the lambdas that describe works using the Datacite schema
+//
+
+package libracommon
+
+import (
+	"regexp"
+	"strings"
+)
+
+var doiPattern = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// language names used by the deposit form and their IETF codes
+var languageCodes = map[string]string{
+	"arabic":     "ar",
+	"chinese":    "zh",
+	"english":    "en",
+	"french":     "fr",
+	"german":     "de",
+	"greek":      "el",
+	"hebrew":     "he",
+	"italian":    "it",
+	"japanese":   "ja",
+	"korean":     "ko",
+	"latin":      "la",
+	"portuguese": "pt",
+	"russian":    "ru",
+	"spanish":    "es",
+}
+
+// IsDOI reports whether the value is a bare DOI such as 10.18130/xxxx
+func IsDOI(value string) bool {
+	return doiPattern.MatchString(value)
+}
+
+// IsLanguageCode reports whether the value is an IETF BCP 47 style code such as en or en-US
+func IsLanguageCode(value string) bool {
+	return languagePattern.MatchString(value)
+}
+
+// LanguageCode returns the code for a language name, the language may already be a code.
+// Empty when the language is unknown
+func LanguageCode(language string) string {
+	language = strings.TrimSpace(language)
+	if code, found := languageCodes[strings.ToLower(language)]; found == true {
+		return code
+	}
+	if IsLanguageCode(language) == true {
+		return language
+	}
+	return ""
+}
+
+// DoiFromURL returns the DOI from a doi.org url, doi: reference or bare DOI, empty if the
+// value is none of these
+func DoiFromURL(value string) string {
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		value = strings.TrimPrefix(value, prefix)
+	}
+	if IsDOI(value) == true {
+		return value
+	}
+	return ""
+}
+
+//
+// end of file
+//
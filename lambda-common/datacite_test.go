@@ -0,0 +1,67 @@
+//
+// tests for the Datacite identifier and language helpers
+//
+
+package libracommon_test
+
+import (
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func TestIsDOI(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"10.18130/v3-abc1-2345":         true,
+		"10.5072/x":                     true,
+		"10.123/short-registrant":       false,
+		"https://doi.org/10.18130/abc":  false,
+		"10.18130/":                     false,
+		"10.18130/has space":            false,
+		"":                              false,
+		"doi:10.18130/abc":              false,
+		"11.18130/not-a-directory-code": false,
+	} {
+		if libracommon.IsDOI(value) != expected {
+			t.Errorf("IsDOI(%q) expected %t", value, expected)
+		}
+	}
+}
+
+func TestLanguageCode(t *testing.T) {
+	for language, expected := range map[string]string{
+		"English":   "en",
+		" spanish ": "es",
+		"en":        "en",
+		"en-US":     "en-US",
+		"zh-Hant":   "zh-Hant",
+		"Klingon":   "",
+		"":          "",
+		"e":         "",
+	} {
+		if code := libracommon.LanguageCode(language); code != expected {
+			t.Errorf("LanguageCode(%q) expected [%s], got [%s]", language, expected, code)
+		}
+	}
+}
+
+func TestDoiFromURL(t *testing.T) {
+	for value, expected := range map[string]string{
+		"https://doi.org/10.18130/abc":    "10.18130/abc",
+		"http://doi.org/10.18130/abc":     "10.18130/abc",
+		"https://dx.doi.org/10.18130/abc": "10.18130/abc",
+		"doi:10.18130/abc":                "10.18130/abc",
+		"10.18130/abc":                    "10.18130/abc",
+		"https://example.edu/10.18130/ab": "",
+		"https://doi.org/":                "",
+		"":                                "",
+	} {
+		if doi := libracommon.DoiFromURL(value); doi != expected {
+			t.Errorf("DoiFromURL(%q) expected [%s], got [%s]", value, expected, doi)
+		}
+	}
+}
+
+//
+// end of file
+//
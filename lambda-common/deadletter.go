@@ -0,0 +1,85 @@
+//
+// a record of messages that failed permanently, these are acknowledged rather than
+// retried and written to the dead letter queue for later inspection
+//
+
+package libracommon
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// message attributes added to a dead letter record
+const DeadLetterLambdaAttribute = "libra-lambda"
+const DeadLetterErrorAttribute = "libra-error"
+
+// DeadLetterRecord is a failed message and the reason it failed
+type DeadLetterRecord struct {
+	MessageId string // the original message identifier
+	Body      string // the original message body
+	Error     string // why it failed
+}
+
+// DeadLetterQueue abstracts where permanently failed messages are sent
+type DeadLetterQueue interface {
+	Send(ctx context.Context, record DeadLetterRecord) error
+}
+
+// our DeadLetterQueue implementation
+type sqsDeadLetterQueue struct {
+	client   *sqs.Client
+	queueUrl string
+}
+
+// the fallback when no queue is configured, the record is only logged
+type logDeadLetterQueue struct{}
+
+// NewDeadLetterQueue creates a queue client for DEAD_LETTER_QUEUE_URL, if it is not
+// configured dead letter records are logged instead
+func NewDeadLetterQueue() (DeadLetterQueue, error) {
+	queueUrl := os.Getenv("DEAD_LETTER_QUEUE_URL")
+	if queueUrl == "" {
+		return &logDeadLetterQueue{}, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &sqsDeadLetterQueue{client: sqs.NewFromConfig(cfg), queueUrl: queueUrl}, nil
+}
+
+func (dq *sqsDeadLetterQueue) Send(ctx context.Context, record DeadLetterRecord) error {
+	_, err := dq.client.SendMessage(ctx,
+		&sqs.SendMessageInput{
+			QueueUrl:    aws.String(dq.queueUrl),
+			MessageBody: aws.String(record.Body),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				DeadLetterLambdaAttribute: {DataType: aws.String("String"), StringValue: aws.String(lambdaName())},
+				DeadLetterErrorAttribute:  {DataType: aws.String("String"), StringValue: aws.String(record.Error)},
+			},
+		})
+
+	if err != nil {
+		LogError("sending message %s to the dead letter queue (%s)", record.MessageId, err.Error())
+		return err
+	}
+
+	LogInfo("message %s sent to the dead letter queue", record.MessageId)
+	return nil
+}
+
+func (dq *logDeadLetterQueue) Send(ctx context.Context, record DeadLetterRecord) error {
+	LogError("DEAD LETTER message %s (%s): %s", record.MessageId, record.Error, record.Body)
+	return nil
+}
+
+//
+// end of file
+//
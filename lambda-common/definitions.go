@@ -2,7 +2,7 @@
 //
 //
 
-package main
+package libracommon
 
 import "fmt"
 
@@ -10,9 +10,19 @@ import "fmt"
 var ErrNoMetadata = fmt.Errorf("no metadata")
 var ErrUserNotFound = fmt.Errorf("user not found")
 var ErrEmailNotFound = fmt.Errorf("email not found")
+var ErrBadConfigType = fmt.Errorf("unsupported configuration type")
+var ErrNoDeadLetterQueue = fmt.Errorf("no dead letter queue")
+var ErrNoIdempotencyStore = fmt.Errorf("no idempotency store")
+var ErrEventInProgress = fmt.Errorf("event is being processed")
+var ErrNoContentLength = fmt.Errorf("no content length")
 
-// namespace definition
-var libraEtdNamespace = "libraetd"
+// events not yet defined by the bus sdk
+var EventScheduleDoiReconcile = "schedule.doi.reconcile" // time to reconcile DOI state with Datacite
+var EventCommandDoiReconcile = "command.doi.reconcile"   // reconcile the DOI state of one work with Datacite
+
+// namespace definitions
+var LibraEtdNamespace = "libraetd"
+var LibraOpenNamespace = "libraopen"
 
 //
 // end of file
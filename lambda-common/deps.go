@@ -0,0 +1,67 @@
+//
+// the external dependencies used when processing an event. Lambdas use DefaultDeps,
+// tests replace them with the in-memory versions from the fakes package
+//
+
+package libracommon
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// ParameterStore abstracts the SSM parameter store
+type ParameterStore interface {
+	GetParameter(ctx context.Context, name string) (string, error)
+	GetSecureParameter(ctx context.Context, name string) (string, error)
+	SetParameter(ctx context.Context, name string, value string) error
+}
+
+// ObjectStore abstracts the S3 object store
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket string, key string, buffer []byte) error
+	UploadFile(ctx context.Context, bucket string, key string, localName string) error
+}
+
+// SecretStore abstracts the secrets manager
+type SecretStore interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// Deps is the injectable dependency bundle
+type Deps struct {
+	Easystore         func(endpoint string) (uvaeasystore.EasyStore, error)
+	EasystoreReadonly func(endpoint string) (uvaeasystore.EasyStoreReadonly, error)
+	EventBus          func(eventBus string, eventSource string) (uvalibrabus.UvaBus, error)
+	Parameters        func() (ParameterStore, error)
+	Objects           func() (ObjectStore, error)
+	Secrets           func() (SecretStore, error)
+	DeadLetters       func() (DeadLetterQueue, error)
+	Idempotency       func() (IdempotencyStore, error)
+	HttpClient        func(maxConnections int, timeout int) *http.Client
+	Now               func() time.Time
+}
+
+// DefaultDeps returns the real dependencies
+func DefaultDeps() Deps {
+	return Deps{
+		Easystore:         NewEasystoreProxy,
+		EasystoreReadonly: NewEasystoreReadonlyProxy,
+		EventBus:          NewEventBus,
+		Parameters:        NewParameterClient,
+		Objects:           NewS3Client,
+		Secrets:           NewSecretsClient,
+		DeadLetters:       NewDeadLetterQueue,
+		Idempotency:       NewIdempotencyStore,
+		HttpClient:        NewHttpClient,
+		Now:               time.Now,
+	}
+}
+
+//
+// end of file
+//
@@ -2,72 +2,94 @@
 //
 //
 
-package main
+package libracommon
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var maxEsRetries = 3
 var esRetrySleepTime = 100 * time.Millisecond
 
-func newEasystoreProxy(cfg *Config) (uvaeasystore.EasyStore, error) {
+func NewEasystoreProxy(endpoint string) (uvaeasystore.EasyStore, error) {
 
 	config := uvaeasystore.ProxyConfigImpl{
-		ServiceEndpoint: cfg.EsProxyUrl,
-		Log:             log.Default(),
+		ServiceEndpoint: endpoint,
+		Log:             NewStdLogger(),
 	}
 	return uvaeasystore.NewEasyStoreProxy(config)
 }
 
-func newEasystoreReadonlyProxy(cfg *Config) (uvaeasystore.EasyStoreReadonly, error) {
+func NewEasystoreReadonlyProxy(endpoint string) (uvaeasystore.EasyStoreReadonly, error) {
 
 	config := uvaeasystore.ProxyConfigImpl{
-		ServiceEndpoint: cfg.EsProxyUrl,
-		Log:             log.Default(),
+		ServiceEndpoint: endpoint,
+		Log:             NewStdLogger(),
 	}
 	return uvaeasystore.NewEasyStoreProxyReadonly(config)
 }
 
-func createEasystoreObject(es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject) error {
+func CreateEasystoreObject(ctx context.Context, es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject) error {
 
+	if ctx.Err() != nil {
+		return Transient(ctx.Err())
+	}
+	span := startEasystoreSpan(ctx, "create", obj.Namespace(), obj.Id())
 	obj, err := es.ObjectCreate(obj)
+	endSpan(span, err)
 	if err == nil {
-		fmt.Printf("INFO: created new easystore object [%s/%s]\n", obj.Namespace(), obj.Id())
+		LogInfo("created new easystore object [%s/%s]", obj.Namespace(), obj.Id())
 	}
-	return err
+	return easystoreError(err)
 }
 
-func getEasystoreObjectByKey(es uvaeasystore.EasyStoreReadonly, namespace string, identifier string, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObject, error) {
+func GetEasystoreObjectByKey(ctx context.Context, es uvaeasystore.EasyStoreReadonly, namespace string, identifier string, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObject, error) {
+	if ctx.Err() != nil {
+		return nil, Transient(ctx.Err())
+	}
+	span := startEasystoreSpan(ctx, "get", namespace, identifier)
 	obj, err := es.ObjectGetByKey(namespace, identifier, what)
+	endSpan(span, err)
 	if err == nil {
-		fmt.Printf("INFO: got easystore object [%s/%s]\n", obj.Namespace(), obj.Id())
+		LogInfo("got easystore object [%s/%s]", obj.Namespace(), obj.Id())
 	}
-	return obj, err
+	return obj, easystoreError(err)
 }
 
-func getEasystoreObjectsByFields(es uvaeasystore.EasyStoreReadonly, namespace string, fields uvaeasystore.EasyStoreObjectFields, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObjectSet, error) {
+func GetEasystoreObjectsByFields(ctx context.Context, es uvaeasystore.EasyStoreReadonly, namespace string, fields uvaeasystore.EasyStoreObjectFields, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObjectSet, error) {
+	if ctx.Err() != nil {
+		return nil, Transient(ctx.Err())
+	}
+	span := startEasystoreSpan(ctx, "find", namespace, "")
 	objSet, err := es.ObjectGetByFields(namespace, fields, what)
+	endSpan(span, err)
 	if err == nil {
-		fmt.Printf("INFO: got %d easystore objects\n", objSet.Count())
+		LogInfo("got %d easystore objects", objSet.Count())
 	}
-	return objSet, err
+	return objSet, easystoreError(err)
 }
 
-func putEasystoreObject(es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents) error {
+func PutEasystoreObject(ctx context.Context, es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents) error {
+	if ctx.Err() != nil {
+		return Transient(ctx.Err())
+	}
+	span := startEasystoreSpan(ctx, "update", obj.Namespace(), obj.Id())
 	obj, err := es.ObjectUpdate(obj, what)
+	endSpan(span, err)
 	if err == nil {
-		fmt.Printf("INFO: updated easystore object [%s/%s]\n", obj.Namespace(), obj.Id())
+		LogInfo("updated easystore object [%s/%s]", obj.Namespace(), obj.Id())
 	}
-	return err
+	return easystoreError(err)
 }
 
-func putEasystoreFieldWithRetry(es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents, field string, value string) (uvaeasystore.EasyStoreObject, error) {
-	err := putEasystoreObject(es, obj, uvaeasystore.Fields)
+func PutEasystoreFieldWithRetry(ctx context.Context, es uvaeasystore.EasyStore, obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents, field string, value string) (uvaeasystore.EasyStoreObject, error) {
+	err := PutEasystoreObject(ctx, es, obj, uvaeasystore.Fields)
 	// happy day, return...
 	if err == nil {
 		return obj, err
@@ -76,16 +98,20 @@ func putEasystoreFieldWithRetry(es uvaeasystore.EasyStore, obj uvaeasystore.Easy
 	// our retry loop
 	for retry := 0; retry < maxEsRetries; retry++ {
 		// if our object is stale
-		if err == uvaeasystore.ErrStaleObject {
+		if errors.Is(err, uvaeasystore.ErrStaleObject) == true {
 
-			fmt.Printf("WARNING: easystore object is stale [%s/%s], retry #%d\n", obj.Namespace(), obj.Id(), retry+1)
+			LogWarning("easystore object is stale [%s/%s], retry #%d", obj.Namespace(), obj.Id(), retry+1)
+			IncrementCounter("EasystoreStaleRetries", nil)
 
 			// sleep for a bit before retrying
-			time.Sleep(esRetrySleepTime)
+			err = sleepContext(ctx, esRetrySleepTime)
+			if err != nil {
+				return obj, Transient(err)
+			}
 
 			// try and get it again
 			var newObj uvaeasystore.EasyStoreObject
-			newObj, err = getEasystoreObjectByKey(es, obj.Namespace(), obj.Id(), what)
+			newObj, err = GetEasystoreObjectByKey(ctx, es, obj.Namespace(), obj.Id(), what)
 			// it's all over, return error
 			if err != nil {
 				return obj, err
@@ -94,7 +120,7 @@ func putEasystoreFieldWithRetry(es uvaeasystore.EasyStore, obj uvaeasystore.Easy
 			fields := obj.Fields()
 			fields[field] = value
 			obj.SetFields(fields)
-			err = putEasystoreObject(es, obj, uvaeasystore.Fields)
+			err = PutEasystoreObject(ctx, es, obj, uvaeasystore.Fields)
 			// happy day, return...
 			if err == nil {
 				return obj, err
@@ -103,16 +129,24 @@ func putEasystoreFieldWithRetry(es uvaeasystore.EasyStore, obj uvaeasystore.Easy
 			// otherwise, an error... if its stale, retry, otherwise abandon loop and return the error
 		} else {
 			// it's all over, return error
-			fmt.Printf("WARNING: abandoning retry for [%s/%s] (%s)\n", obj.Namespace(), obj.Id(), err.Error())
+			LogWarning("abandoning retry for [%s/%s] (%s)", obj.Namespace(), obj.Id(), err.Error())
 			return obj, err
 		}
 	}
 
 	// we have retried and are giving up
-	fmt.Printf("ERROR: easystore object was stale [%s/%s], gave up\n", obj.Namespace(), obj.Id())
+	LogError("easystore object was stale [%s/%s], gave up", obj.Namespace(), obj.Id())
 	return obj, err
 }
 
+// the easystore proxy does not take a context so its calls are traced here
+func startEasystoreSpan(ctx context.Context, operation string, namespace string, identifier string) trace.Span {
+	_, span := tracer.Start(ctx, "easystore "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("libra.namespace", namespace), attribute.String("libra.id", identifier)))
+	return span
+}
+
 //
 // end of file
 //
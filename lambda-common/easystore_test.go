@@ -0,0 +1,90 @@
+//
+// tests for the easystore helpers
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func addTestObject(fd *fakes.Dependencies, id string) uvaeasystore.EasyStoreObject {
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["title"] = "original"
+	obj.SetFields(fields)
+	return fd.Easystore.Add(obj)
+}
+
+func TestPutEasystoreFieldWithRetry(t *testing.T) {
+	fd := fakes.New()
+	stale := addTestObject(fd, "oid:work1")
+
+	// someone else updates the object first
+	current := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work1")
+	fields := current.Fields()
+	fields["title"] = "changed elsewhere"
+	current.SetFields(fields)
+	_, err := fd.Easystore.ObjectUpdate(current, uvaeasystore.Fields)
+	if err != nil {
+		t.Fatalf("updating object (%s)", err.Error())
+	}
+
+	// our update of the stale copy is applied to the latest version
+	fields = stale.Fields()
+	fields["sent"] = "yes"
+	stale.SetFields(fields)
+	obj, err := libracommon.PutEasystoreFieldWithRetry(context.Background(), fd.Easystore, stale, uvaeasystore.Fields, "sent", "yes")
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	stored := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work1").Fields()
+	if stored["sent"] != "yes" || stored["title"] != "changed elsewhere" {
+		t.Errorf("expected the field to be added to the latest version, got %v", stored)
+	}
+	if obj.Fields()["sent"] != "yes" {
+		t.Errorf("expected the returned object to have the field")
+	}
+}
+
+func TestPutEasystoreFieldWithRetryMissing(t *testing.T) {
+	fd := fakes.New()
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, "oid:gone")
+
+	_, err := libracommon.PutEasystoreFieldWithRetry(context.Background(), fd.Easystore, obj, uvaeasystore.Fields, "sent", "yes")
+	if libracommon.IsPermanent(err) == false {
+		t.Errorf("expected a permanent failure, got %v", err)
+	}
+}
+
+func TestGetEasystoreObjectByKey(t *testing.T) {
+	fd := fakes.New()
+	addTestObject(fd, "oid:work1")
+
+	obj, err := libracommon.GetEasystoreObjectByKey(context.Background(), fd.Easystore, libracommon.LibraEtdNamespace, "oid:work1", uvaeasystore.Fields)
+	if err != nil || obj.Fields()["title"] != "original" {
+		t.Errorf("expected the object, got %v", err)
+	}
+
+	// a missing object will never be found, running out of time is worth a retry
+	_, err = libracommon.GetEasystoreObjectByKey(context.Background(), fd.Easystore, libracommon.LibraEtdNamespace, "oid:gone", uvaeasystore.Fields)
+	if libracommon.IsPermanent(err) == false {
+		t.Errorf("expected a permanent failure, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = libracommon.GetEasystoreObjectByKey(ctx, fd.Easystore, libracommon.LibraEtdNamespace, "oid:work1", uvaeasystore.Fields)
+	if err == nil || libracommon.IsTransient(err) == false {
+		t.Errorf("expected a transient failure, got %v", err)
+	}
+}
+
+//
+// end of file
+//
@@ -2,55 +2,60 @@
 //
 //
 
-package main
+package libracommon
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func envWithDefault(env string, defaultValue string) string {
+func EnvWithDefault(env string, defaultValue string) string {
 	val, set := os.LookupEnv(env)
 
 	if set == false {
-		fmt.Printf("INFO: environment variable not set: [%s] using default value [%s]\n", env, defaultValue)
+		LogInfo("environment variable not set: [%s] using default value [%s]", env, defaultValue)
 		return defaultValue
 	}
 
 	return val
 }
 
-func ensureSet(env string) (string, error) {
+func EnsureSet(env string) (string, error) {
 	val, set := os.LookupEnv(env)
 
 	if set == false {
 		err := fmt.Errorf("environment variable not set: [%s]", env)
-		fmt.Printf("ERROR: %s\n", err.Error())
+		LogError("%s", err.Error())
 		return "", err
 	}
 
 	return val, nil
 }
 
-func ensureSetAndNonEmpty(env string) (string, error) {
-	val, err := ensureSet(env)
+func EnsureSetAndNonEmpty(env string) (string, error) {
+	val, err := EnsureSet(env)
 	if err != nil {
 		return "", err
 	}
 
 	if val == "" {
 		err := fmt.Errorf("environment variable is empty: [%s]", env)
-		fmt.Printf("ERROR: %s\n", err.Error())
+		LogError("%s", err.Error())
 		return "", err
 	}
 
 	return val, nil
 }
 
-func envToInt(env string) (int, error) {
+func EnvToInt(env string) (int, error) {
 
-	number, err := ensureSetAndNonEmpty(env)
+	number, err := EnsureSetAndNonEmpty(env)
 	if err != nil {
 		return -1, err
 	}
@@ -62,9 +67,9 @@ func envToInt(env string) (int, error) {
 	return n, nil
 }
 
-func envToBool(env string) (bool, error) {
+func EnvToBool(env string) (bool, error) {
 
-	str, err := ensureSetAndNonEmpty(env)
+	str, err := EnsureSetAndNonEmpty(env)
 	if err != nil {
 		return false, err
 	}
@@ -76,6 +81,217 @@ func envToBool(env string) (bool, error) {
 	return b, nil
 }
 
+// LoadConfig populates the fields of the supplied struct pointer using their struct tags:
+//
+//	env:"NAME"        the environment variable to read
+//	default:"value"   the value used when the variable is not set or empty
+//	required:"true"   the variable must be set and non-empty
+//	secret:"true"     the value is redacted by PrintConfig
+//
+// Untagged nested structs are populated recursively. Values of the form ssm:/path or
+// secretsmanager:name#key are resolved at load time, and always redacted by PrintConfig. Every missing or invalid variable
+// is reported in the returned error rather than just the first one.
+func LoadConfig(cfg any) error {
+	return LoadConfigWith(cfg, defaultSecretResolver)
+}
+
+// LoadConfigWith is LoadConfig using the supplied secret resolver
+func LoadConfigWith(cfg any, secrets *SecretResolver) error {
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: configuration must be a struct pointer", ErrBadConfigType)
+	}
+
+	missing := make([]string, 0)
+	invalid := make([]error, 0)
+	walkConfig(v.Elem(), "", func(label string, field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("env")
+		raw, set := configValue(field)
+		if set == false {
+			if field.Tag.Get("required") == "true" {
+				missing = append(missing, name)
+			}
+			return
+		}
+		raw, err := secrets.Resolve(raw)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("environment variable cannot be resolved: [%s] (%s)", name, err.Error()))
+			return
+		}
+		err = setConfigValue(value, raw)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("environment variable is invalid: [%s] (%s)", name, err.Error()))
+		}
+	})
+
+	if len(missing) != 0 {
+		invalid = append([]error{fmt.Errorf("environment variable(s) not set: [%s]", strings.Join(missing, ", "))}, invalid...)
+	}
+	for _, err := range invalid {
+		LogError("%s", err.Error())
+	}
+	return errors.Join(invalid...)
+}
+
+// PrintConfig logs each tagged configuration field, redacting secrets
+func PrintConfig(cfg any) {
+
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	// gather first so the values line up
+	labels := make([]string, 0)
+	values := make([]string, 0)
+	width := 0
+	walkConfig(v, "", func(label string, field reflect.StructField, value reflect.Value) {
+		str := formatConfigValue(value)
+		if raw, _ := configValue(field); field.Tag.Get("secret") == "true" || isSecretReference(raw) == true {
+			str = "REDACTED"
+		}
+		labels = append(labels, label)
+		values = append(values, str)
+		width = max(width, len(label))
+	})
+
+	for ix := range labels {
+		LogInfo("[conf] %-*s = [%s]", width, labels[ix], values[ix])
+	}
+}
+
+// configValue returns the unresolved value of the field, an empty variable is treated as
+// not set so that the default applies
+func configValue(field reflect.StructField) (string, bool) {
+	raw, set := os.LookupEnv(field.Tag.Get("env"))
+	if set == true && raw != "" {
+		return raw, true
+	}
+	return field.Tag.Lookup("default")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var urlType = reflect.TypeOf(url.URL{})
+
+// call fn for each env tagged field, descending into untagged structs
+func walkConfig(v reflect.Value, prefix string, fn func(string, reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for ix := 0; ix < t.NumField(); ix++ {
+		field := t.Field(ix)
+		if field.IsExported() == false {
+			continue
+		}
+		value := v.Field(ix)
+		if _, tagged := field.Tag.Lookup("env"); tagged == true {
+			fn(prefix+field.Name, field, value)
+			continue
+		}
+		if value.Kind() == reflect.Struct {
+			walkConfig(value, prefix+field.Name+".", fn)
+		}
+	}
+}
+
+func setConfigValue(value reflect.Value, raw string) error {
+
+	switch {
+	case value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+
+	case value.Type() == urlType, value.Type() == reflect.PointerTo(urlType):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		if value.Kind() == reflect.Pointer {
+			value.Set(reflect.ValueOf(u))
+		} else {
+			value.Set(reflect.ValueOf(*u))
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%w (%s)", ErrBadConfigType, value.Type())
+		}
+		// comma separated list, empty entries are ignored
+		list := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item).Convert(value.Type().Elem()))
+			}
+		}
+		value.Set(list)
+
+	default:
+		return fmt.Errorf("%w (%s)", ErrBadConfigType, value.Type())
+	}
+	return nil
+}
+
+func formatConfigValue(value reflect.Value) string {
+
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Type() == urlType:
+		u := value.Interface().(url.URL)
+		return u.Redacted()
+	case value.Type() == reflect.PointerTo(urlType):
+		if value.IsNil() {
+			return ""
+		}
+		return value.Interface().(*url.URL).Redacted()
+	case value.Kind() == reflect.Slice:
+		items := make([]string, 0, value.Len())
+		for ix := 0; ix < value.Len(); ix++ {
+			items = append(items, fmt.Sprintf("%v", value.Index(ix).Interface()))
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
+
 //
 // end of file
 //
@@ -0,0 +1,237 @@
+//
+// tests for the struct tag driven configuration loader
+//
+
+package libracommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a parameter store holding the supplied values
+type testParameters map[string]string
+
+func (tp testParameters) GetParameter(ctx context.Context, name string) (string, error) {
+	v, found := tp[name]
+	if found == false {
+		return "", fmt.Errorf("%w: [%s]", ErrParameterNotFound, name)
+	}
+	return v, nil
+}
+
+func (tp testParameters) GetSecureParameter(ctx context.Context, name string) (string, error) {
+	return tp.GetParameter(ctx, name)
+}
+
+func (tp testParameters) SetParameter(ctx context.Context, name string, value string) error {
+	tp[name] = value
+	return nil
+}
+
+func testResolver(values map[string]string) *SecretResolver {
+	return NewSecretResolver(Deps{Parameters: func() (ParameterStore, error) { return testParameters(values), nil }})
+}
+
+// captureLogs returns the messages logged by the test
+func captureLogs(t *testing.T) func() []string {
+	var buf bytes.Buffer
+	saved := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { logger = saved })
+
+	return func() []string {
+		messages := make([]string, 0)
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record struct {
+				Msg string `json:"msg"`
+			}
+			if json.Unmarshal([]byte(line), &record) == nil {
+				messages = append(messages, record.Msg)
+			}
+		}
+		return messages
+	}
+}
+
+type testNested struct {
+	Host string `env:"TEST_NESTED_HOST" default:"localhost"`
+}
+
+type testConfig struct {
+	Name     string        `env:"TEST_NAME" required:"true"`
+	Port     int           `env:"TEST_PORT" default:"8080"`
+	Enabled  bool          `env:"TEST_ENABLED" default:"true"`
+	Timeout  time.Duration `env:"TEST_TIMEOUT" default:"30s"`
+	Endpoint *url.URL      `env:"TEST_ENDPOINT_URL"`
+	Service  url.URL       `env:"TEST_SERVICE_URL"`
+	Hosts    []string      `env:"TEST_HOSTS"`
+	Ratio    float64       `env:"TEST_RATIO"`
+	Password string        `env:"TEST_PASSWORD" secret:"true"`
+	Token    string        `env:"TEST_TOKEN"`
+	Nested   testNested
+}
+
+func clearTestEnv(t *testing.T) {
+	for _, name := range []string{"TEST_NAME", "TEST_PORT", "TEST_ENABLED", "TEST_TIMEOUT", "TEST_ENDPOINT_URL", "TEST_SERVICE_URL", "TEST_HOSTS", "TEST_RATIO", "TEST_PASSWORD", "TEST_TOKEN", "TEST_NESTED_HOST"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearTestEnv(t)
+	t.Setenv("TEST_NAME", "libra")
+	// an empty variable is treated as not set
+	t.Setenv("TEST_PORT", "")
+
+	var cfg testConfig
+	err := LoadConfigWith(&cfg, testResolver(nil))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if cfg.Name != "libra" || cfg.Port != 8080 || cfg.Enabled != true || cfg.Timeout != 30*time.Second || cfg.Nested.Host != "localhost" {
+		t.Errorf("expected the defaults, got %+v", cfg)
+	}
+	if cfg.Hosts != nil || cfg.Password != "" || cfg.Endpoint != nil {
+		t.Errorf("expected unset fields to be left alone, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigParses(t *testing.T) {
+	clearTestEnv(t)
+	t.Setenv("TEST_NAME", "libra")
+	t.Setenv("TEST_PORT", "9090")
+	t.Setenv("TEST_ENABLED", "false")
+	t.Setenv("TEST_TIMEOUT", "1m30s")
+	t.Setenv("TEST_SERVICE_URL", "https://user:pw@service.example.edu/path?q=1")
+	t.Setenv("TEST_ENDPOINT_URL", "https://endpoint.example.edu")
+	t.Setenv("TEST_HOSTS", " one, two ,,three ")
+	t.Setenv("TEST_RATIO", "0.25")
+	t.Setenv("TEST_NESTED_HOST", "db.example.edu")
+
+	var cfg testConfig
+	err := LoadConfigWith(&cfg, testResolver(nil))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if cfg.Port != 9090 || cfg.Enabled != false || cfg.Timeout != 90*time.Second || cfg.Ratio != 0.25 || cfg.Nested.Host != "db.example.edu" {
+		t.Errorf("unexpected values %+v", cfg)
+	}
+	if cfg.Service.Host != "service.example.edu" || cfg.Service.Path != "/path" || cfg.Service.RawQuery != "q=1" {
+		t.Errorf("unexpected url %+v", cfg.Service)
+	}
+	if cfg.Endpoint == nil || cfg.Endpoint.Host != "endpoint.example.edu" {
+		t.Errorf("unexpected url pointer %+v", cfg.Endpoint)
+	}
+	if strings.Join(cfg.Hosts, "|") != "one|two|three" {
+		t.Errorf("unexpected list %q", cfg.Hosts)
+	}
+}
+
+func TestLoadConfigReportsEverything(t *testing.T) {
+	clearTestEnv(t)
+	t.Setenv("TEST_PORT", "eighty")
+	t.Setenv("TEST_TIMEOUT", "soon")
+	t.Setenv("TEST_SERVICE_URL", "http://bad host/")
+
+	type requiredConfig struct {
+		Base  testConfig
+		Other string `env:"TEST_OTHER" required:"true"`
+	}
+	t.Setenv("TEST_OTHER", "")
+
+	var cfg requiredConfig
+	err := LoadConfigWith(&cfg, testResolver(nil))
+	if err == nil {
+		t.Fatalf("expected a failure")
+	}
+
+	// the missing variables are reported together, followed by each invalid one
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 4 || lines[0] != "environment variable(s) not set: [TEST_NAME, TEST_OTHER]" {
+		t.Errorf("unexpected error %q", err.Error())
+	}
+	for _, name := range []string{"TEST_PORT", "TEST_TIMEOUT", "TEST_SERVICE_URL"} {
+		if strings.Contains(err.Error(), "environment variable is invalid: ["+name+"]") == false {
+			t.Errorf("expected %s to be reported, got %q", name, err.Error())
+		}
+	}
+}
+
+func TestLoadConfigResolvesReferences(t *testing.T) {
+	clearTestEnv(t)
+	t.Setenv("TEST_NAME", "libra")
+	t.Setenv("TEST_PASSWORD", "ssm:/libra/password")
+	t.Setenv("TEST_TOKEN", "ssm:/libra/missing")
+
+	var cfg testConfig
+	err := LoadConfigWith(&cfg, testResolver(map[string]string{"/libra/password": "s3cret"}))
+	if err == nil || strings.Contains(err.Error(), "environment variable cannot be resolved: [TEST_TOKEN]") == false {
+		t.Fatalf("expected the unresolved reference to be reported, got %v", err)
+	}
+	if cfg.Password != "s3cret" {
+		t.Errorf("expected the resolved value, got [%s]", cfg.Password)
+	}
+}
+
+func TestLoadConfigNeedsStructPointer(t *testing.T) {
+	var cfg testConfig
+	for _, bad := range []any{cfg, "string", new(int)} {
+		err := LoadConfigWith(bad, testResolver(nil))
+		if errors.Is(err, ErrBadConfigType) == false {
+			t.Errorf("%T: expected ErrBadConfigType, got %v", bad, err)
+		}
+	}
+
+	// every field must be of a supported type
+	type unsupported struct {
+		Ports []int `env:"TEST_PORTS" default:"1,2"`
+	}
+	err := LoadConfigWith(&unsupported{}, testResolver(nil))
+	if err == nil || strings.Contains(err.Error(), ErrBadConfigType.Error()) == false {
+		t.Errorf("expected ErrBadConfigType for an int list, got %v", err)
+	}
+}
+
+func TestPrintConfigRedacts(t *testing.T) {
+	clearTestEnv(t)
+	t.Setenv("TEST_NAME", "libra")
+	t.Setenv("TEST_PASSWORD", "plain-password")
+	t.Setenv("TEST_TOKEN", "secretsmanager:libra/api#token")
+	t.Setenv("TEST_SERVICE_URL", "https://user:pw@service.example.edu/path")
+	t.Setenv("TEST_HOSTS", "one,two")
+
+	cfg := testConfig{Name: "libra", Password: "plain-password", Token: "resolved-token", Hosts: []string{"one", "two"}, Port: 8080}
+	cfg.Service.Scheme = "https"
+	cfg.Service.Host = "service.example.edu"
+	cfg.Service.User = url.UserPassword("user", "pw")
+	messages := captureLogs(t)
+	PrintConfig(cfg)
+
+	logged := strings.Join(messages(), "\n")
+	for _, leaked := range []string{"plain-password", "resolved-token", ":pw@"} {
+		if strings.Contains(logged, leaked) == true {
+			t.Errorf("expected %s to be redacted, got\n%s", leaked, logged)
+		}
+	}
+	for _, expected := range []string{"Password", "Token", "Nested.Host", "= [libra]", "= [one, two]", "= [8080]"} {
+		if strings.Contains(logged, expected) == false {
+			t.Errorf("expected %q to be logged, got\n%s", expected, logged)
+		}
+	}
+	if strings.Count(logged, "= [REDACTED]") != 2 {
+		t.Errorf("expected the secret and the reference to be redacted, got\n%s", logged)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,137 @@
+//
+// error classification, decides whether a failed event is worth retrying
+//
+
+package libracommon
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// error classes, test using errors.Is or the Is... helpers
+var ErrPermanent = fmt.Errorf("permanent failure")
+var ErrTransient = fmt.Errorf("transient failure")
+var ErrThrottled = fmt.Errorf("throttled")
+
+// errors that will never succeed on retry, even when not explicitly classified
+var permanentErrors = []error{
+	ErrNoMetadata,
+	ErrUserNotFound,
+	ErrEmailNotFound,
+	ErrBadConfigType,
+	ErrUnknownDegree,
+	ErrUnknownResourceType,
+	uvaeasystore.ErrBadParameter,
+	uvaeasystore.ErrNotFound,
+	uvalibrabus.ErrEventDeserialize,
+}
+
+// classifiedError keeps the original error message and both errors.Is chains
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (ce *classifiedError) Error() string {
+	return ce.err.Error()
+}
+
+func (ce *classifiedError) Unwrap() []error {
+	return []error{ce.err, ce.class}
+}
+
+// Permanent marks an error as one that will fail again if retried
+func Permanent(err error) error {
+	return classify(err, ErrPermanent)
+}
+
+// Transient marks an error as one that may succeed if retried
+func Transient(err error) error {
+	return classify(err, ErrTransient)
+}
+
+// Throttled marks an error as a transient one caused by rate limiting
+func Throttled(err error) error {
+	return classify(err, ErrThrottled)
+}
+
+// IsPermanent reports whether an error is permanent, the outermost classification wins and
+// unclassified errors are assumed transient unless they are known to be permanent
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) == true {
+		return ce.class == ErrPermanent
+	}
+	for _, perm := range permanentErrors {
+		if errors.Is(err, perm) == true {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTransient reports whether an error may succeed if retried, this includes throttling
+func IsTransient(err error) bool {
+	return err != nil && IsPermanent(err) == false
+}
+
+// IsThrottled reports whether an error was caused by rate limiting
+func IsThrottled(err error) bool {
+	var ce *classifiedError
+	return errors.As(err, &ce) == true && ce.class == ErrThrottled
+}
+
+// HttpStatusError is returned by HttpSend when the service responds with an error status
+type HttpStatusError struct {
+	StatusCode int
+}
+
+func (he *HttpStatusError) Error() string {
+	return fmt.Sprintf("request returns HTTP %d", he.StatusCode)
+}
+
+// IsHttpNotFound reports if the service responded with 404
+func IsHttpNotFound(err error) bool {
+	var statusErr *HttpStatusError
+	return errors.As(err, &statusErr) == true && statusErr.StatusCode == http.StatusNotFound
+}
+
+// classify an HTTP error status
+func httpStatusError(status int) error {
+	err := &HttpStatusError{StatusCode: status}
+	switch {
+	case status == http.StatusTooManyRequests:
+		return Throttled(err)
+	case status == http.StatusRequestTimeout, status >= 500:
+		return Transient(err)
+	default:
+		return Permanent(err)
+	}
+}
+
+// classify an easystore error
+func easystoreError(err error) error {
+	if IsPermanent(err) == true {
+		return Permanent(err)
+	}
+	return Transient(err)
+}
+
+func classify(err error, class error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: class}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,121 @@
+//
+// tests for the error classification
+//
+
+package libracommon
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+func TestClassification(t *testing.T) {
+	base := errors.New("failed")
+
+	for name, tc := range map[string]struct {
+		err       error
+		permanent bool
+		throttled bool
+	}{
+		"nil":          {nil, false, false},
+		"unclassified": {base, false, false},
+		"permanent":    {Permanent(base), true, false},
+		"transient":    {Transient(base), false, false},
+		"throttled":    {Throttled(base), false, true},
+		"wrapped":      {fmt.Errorf("context (%w)", Permanent(base)), true, false},
+	} {
+		if IsPermanent(tc.err) != tc.permanent {
+			t.Errorf("%s: expected IsPermanent %t", name, tc.permanent)
+		}
+		if IsTransient(tc.err) != (tc.err != nil && tc.permanent == false) {
+			t.Errorf("%s: expected IsTransient %t", name, tc.permanent == false)
+		}
+		if IsThrottled(tc.err) != tc.throttled {
+			t.Errorf("%s: expected IsThrottled %t", name, tc.throttled)
+		}
+	}
+
+	// classifying nil is still nil
+	if Permanent(nil) != nil || Transient(nil) != nil || Throttled(nil) != nil {
+		t.Errorf("expected nil to stay nil")
+	}
+}
+
+func TestClassificationPrecedence(t *testing.T) {
+	base := errors.New("failed")
+
+	// the outermost classification wins
+	if IsPermanent(Transient(Permanent(base))) == true {
+		t.Errorf("expected Transient(Permanent) to be transient")
+	}
+	if IsPermanent(Permanent(Transient(base))) == false {
+		t.Errorf("expected Permanent(Transient) to be permanent")
+	}
+	if IsThrottled(Permanent(Throttled(base))) == true {
+		t.Errorf("expected Permanent(Throttled) not to be throttled")
+	}
+
+	// and an explicit classification overrides a known permanent error
+	if IsPermanent(Transient(uvaeasystore.ErrNotFound)) == true {
+		t.Errorf("expected Transient(ErrNotFound) to be transient")
+	}
+
+	// both chains remain visible to errors.Is
+	err := Permanent(ErrUserNotFound)
+	if errors.Is(err, ErrPermanent) == false || errors.Is(err, ErrUserNotFound) == false {
+		t.Errorf("expected the class and the cause in the chain")
+	}
+	if err.Error() != ErrUserNotFound.Error() {
+		t.Errorf("expected the original message, got [%s]", err.Error())
+	}
+}
+
+func TestKnownPermanentErrors(t *testing.T) {
+	for _, err := range []error{ErrNoMetadata, ErrUserNotFound, ErrEmailNotFound, ErrBadConfigType, ErrUnknownDegree, uvaeasystore.ErrBadParameter, uvaeasystore.ErrNotFound, uvalibrabus.ErrEventDeserialize} {
+		if IsPermanent(fmt.Errorf("context (%w)", err)) == false {
+			t.Errorf("expected %v to be permanent", err)
+		}
+	}
+	if IsPermanent(easystoreError(uvaeasystore.ErrNotFound)) == false || IsPermanent(easystoreError(uvaeasystore.ErrStaleObject)) == true {
+		t.Errorf("unexpected easystore classification")
+	}
+}
+
+func TestHttpStatusError(t *testing.T) {
+	for status, expected := range map[int]string{
+		400: "permanent",
+		401: "permanent",
+		404: "permanent",
+		408: "transient",
+		429: "throttled",
+		500: "transient",
+		503: "transient",
+	} {
+		err := httpStatusError(status)
+		class := "transient"
+		switch {
+		case IsPermanent(err):
+			class = "permanent"
+		case IsThrottled(err):
+			class = "throttled"
+		}
+		if class != expected {
+			t.Errorf("%d: expected %s, got %s", status, expected, class)
+		}
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) == false || statusErr.StatusCode != status {
+			t.Errorf("%d: expected the status in the chain", status)
+		}
+	}
+	if IsHttpNotFound(httpStatusError(404)) == false || IsHttpNotFound(httpStatusError(410)) == true {
+		t.Errorf("unexpected IsHttpNotFound")
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,65 @@
+//
+// a bus that appends published events to a local file rather than sending them to
+// EventBridge, used when running lambdas locally. It is selected by a bus name of the
+// form file:/path/to/events.jsonl
+//
+
+package libracommon
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// LocalBusPrefix selects the local bus
+const LocalBusPrefix = "file:"
+
+// LocalBusRecord is a line in the local bus file
+type LocalBusRecord struct {
+	Source string                  `json:"source"` // the publishing source
+	Event  uvalibrabus.UvaBusEvent `json:"event"`  // the published event
+}
+
+// our local UvaBus implementation
+type localBus struct {
+	mu       sync.Mutex
+	filename string
+	source   string
+}
+
+func newLocalBus(filename string, source string) uvalibrabus.UvaBus {
+	return &localBus{filename: filename, source: source}
+}
+
+func (lb *localBus) PublishEvent(ev *uvalibrabus.UvaBusEvent) error {
+
+	if len(ev.EventTime) == 0 {
+		ev.EventTime = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	buf, err := json.Marshal(LocalBusRecord{Source: lb.source, Event: *ev})
+	if err != nil {
+		return err
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	file, err := os.OpenFile(lb.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		LogError("opening local bus [%s] (%s)", lb.filename, err.Error())
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(buf, '\n'))
+	return err
+}
+
+//
+// end of file
+//
@@ -1,24 +1,49 @@
-package main
+package libracommon
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"strings"
+
 	"github.com/uvalib/easystore/uvaeasystore"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewEventBus(eventBus string, eventSource string) (uvalibrabus.UvaBus, error) {
 	// we will accept bad config and return nil quietly
 	if len(eventBus) == 0 {
-		fmt.Printf("INFO: Event bus is not configured, no telemetry emitted\n")
+		LogInfo("Event bus is not configured, no telemetry emitted")
 		return nil, uvalibrabus.ErrConfig
 	}
 
+	// publish to a local file when running locally
+	if strings.HasPrefix(eventBus, LocalBusPrefix) == true {
+		return newLocalBus(strings.TrimPrefix(eventBus, LocalBusPrefix), eventSource), nil
+	}
+
 	cfg := uvalibrabus.UvaBusConfig{BusName: eventBus, Source: eventSource, Log: nil}
 	return uvalibrabus.NewUvaBus(cfg)
 }
 
-func pubAuditEvent(bus uvalibrabus.UvaBus, obj uvaeasystore.EasyStoreObject, who string, fname string, before string, after string) error {
+// PublishEvent publishes the event, adding the current trace context to the event detail
+func PublishEvent(ctx context.Context, bus uvalibrabus.UvaBus, ev *uvalibrabus.UvaBusEvent) error {
+	if bus == nil {
+		return uvalibrabus.ErrConfig
+	}
+
+	ctx, span := tracer.Start(ctx, "publish "+ev.EventName,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("libra.namespace", ev.Namespace), attribute.String("libra.id", ev.Identifier)))
+
+	ev.Detail = injectTrace(ctx, ev.Detail)
+	err := bus.PublishEvent(ev)
+	endSpan(span, err)
+	return err
+}
+
+func PubAuditEvent(ctx context.Context, bus uvalibrabus.UvaBus, obj uvaeasystore.EasyStoreObject, who string, fname string, before string, after string) error {
 	if bus == nil {
 		return uvalibrabus.ErrConfig
 	}
@@ -29,7 +54,7 @@ func pubAuditEvent(bus uvalibrabus.UvaBus, obj uvaeasystore.EasyStoreObject, who
 		Identifier: obj.Id(),
 		Detail:     detail,
 	}
-	return bus.PublishEvent(&ev)
+	return PublishEvent(ctx, bus, &ev)
 }
 
 func auditPayload(who string, fname string, before string, after string) (json.RawMessage, error) {
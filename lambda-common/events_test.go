@@ -0,0 +1,58 @@
+//
+// tests for the event publishing helpers
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+func TestPubAuditEvent(t *testing.T) {
+	fd := fakes.New()
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, "oid:work1")
+
+	err := libracommon.PubAuditEvent(context.Background(), fd.Bus, obj, "libra-test", "doi", "", "https://doi.org/10.5072/abc")
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	events := fd.Bus.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %+v", events)
+	}
+	ev := events[0]
+	if ev.EventName != uvalibrabus.EventFieldUpdate || ev.Namespace != libracommon.LibraEtdNamespace || ev.Identifier != "oid:work1" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	audit, err := uvalibrabus.MakeAuditEvent(ev.Detail)
+	if err != nil {
+		t.Fatalf("expected an audit payload (%s)", err.Error())
+	}
+	if audit.Who != "libra-test" || audit.FieldName != "doi" || audit.Before != "" || audit.After != "https://doi.org/10.5072/abc" {
+		t.Errorf("unexpected audit payload %+v", audit)
+	}
+}
+
+func TestPublishWithoutBus(t *testing.T) {
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, "oid:work1")
+	err := libracommon.PubAuditEvent(context.Background(), nil, obj, "libra-test", "doi", "", "")
+	if errors.Is(err, uvalibrabus.ErrConfig) == false {
+		t.Errorf("expected ErrConfig, got %v", err)
+	}
+	_, err = libracommon.NewEventBus("", "libra-test")
+	if errors.Is(err, uvalibrabus.ErrConfig) == false {
+		t.Errorf("expected ErrConfig for an unconfigured bus, got %v", err)
+	}
+}
+
+//
+// end of file
+//
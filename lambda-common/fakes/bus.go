@@ -0,0 +1,50 @@
+//
+// a message bus that records published events
+//
+
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// Bus records the events published to it
+type Bus struct {
+	mu     sync.Mutex
+	Source string                    // the source the bus was created with
+	Err    error                     // if set, returned from PublishEvent
+	events []uvalibrabus.UvaBusEvent // published events
+	now    func() time.Time
+}
+
+// NewBus creates a bus, event times come from the supplied clock
+func NewBus(now func() time.Time) *Bus {
+	return &Bus{now: now}
+}
+
+func (fb *Bus) PublishEvent(ev *uvalibrabus.UvaBusEvent) error {
+	if fb.Err != nil {
+		return fb.Err
+	}
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if len(ev.EventTime) == 0 {
+		ev.EventTime = fb.now().UTC().Format(time.RFC3339)
+	}
+	fb.events = append(fb.events, *ev)
+	return nil
+}
+
+// Events returns the events published so far
+func (fb *Bus) Events() []uvalibrabus.UvaBusEvent {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return append([]uvalibrabus.UvaBusEvent{}, fb.events...)
+}
+
+//
+// end of file
+//
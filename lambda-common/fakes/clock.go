@@ -0,0 +1,42 @@
+//
+// a settable clock
+//
+
+package fakes
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a settable clock
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (fc *Clock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *Clock) Set(now time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = now
+}
+
+func (fc *Clock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+}
+
+//
+// end of file
+//
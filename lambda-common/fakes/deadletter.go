@@ -0,0 +1,44 @@
+//
+// an in-memory dead letter queue
+//
+
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// DeadLetterQueue records dead letters so they can be inspected
+type DeadLetterQueue struct {
+	Err     error // returned by Send when set
+	mu      sync.Mutex
+	records []libracommon.DeadLetterRecord
+}
+
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{records: make([]libracommon.DeadLetterRecord, 0)}
+}
+
+func (fq *DeadLetterQueue) Send(ctx context.Context, record libracommon.DeadLetterRecord) error {
+	if fq.Err != nil {
+		return fq.Err
+	}
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.records = append(fq.records, record)
+	return nil
+}
+
+// Records returns the dead letters sent so far
+func (fq *DeadLetterQueue) Records() []libracommon.DeadLetterRecord {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return append([]libracommon.DeadLetterRecord(nil), fq.records...)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,79 @@
+//
+// in-memory stand-ins for the external dependencies so that process() can be
+// exercised end-to-end in go test without any AWS or service access
+//
+
+package fakes
+
+import (
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// Dependencies is the set of fakes behind a libracommon.Deps bundle
+type Dependencies struct {
+	Easystore   *Easystore
+	Bus         *Bus
+	Parameters  *ParameterStore
+	Objects     *ObjectStore
+	Secrets     *SecretStore
+	DeadLetters *DeadLetterQueue
+	Idempotency *IdempotencyStore
+	Clock       *Clock
+}
+
+// New creates a new set of fakes, the clock starts at a fixed time
+func New() *Dependencies {
+	clock := NewClock(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC))
+	return &Dependencies{
+		Easystore:   NewEasystore(clock.Now),
+		Bus:         NewBus(clock.Now),
+		Parameters:  NewParameterStore(),
+		Objects:     NewObjectStore(),
+		Secrets:     NewSecretStore(),
+		DeadLetters: NewDeadLetterQueue(),
+		Idempotency: NewIdempotencyStore(),
+		Clock:       clock,
+	}
+}
+
+// Deps returns a dependency bundle backed by the fakes. The http client is the real one,
+// point the configuration at an HttpService to intercept requests
+func (fd *Dependencies) Deps() libracommon.Deps {
+	return libracommon.Deps{
+		Easystore: func(endpoint string) (uvaeasystore.EasyStore, error) {
+			return fd.Easystore, nil
+		},
+		EasystoreReadonly: func(endpoint string) (uvaeasystore.EasyStoreReadonly, error) {
+			return fd.Easystore, nil
+		},
+		EventBus: func(eventBus string, eventSource string) (uvalibrabus.UvaBus, error) {
+			fd.Bus.Source = eventSource
+			return fd.Bus, nil
+		},
+		Parameters: func() (libracommon.ParameterStore, error) {
+			return fd.Parameters, nil
+		},
+		Objects: func() (libracommon.ObjectStore, error) {
+			return fd.Objects, nil
+		},
+		Secrets: func() (libracommon.SecretStore, error) {
+			return fd.Secrets, nil
+		},
+		DeadLetters: func() (libracommon.DeadLetterQueue, error) {
+			return fd.DeadLetters, nil
+		},
+		Idempotency: func() (libracommon.IdempotencyStore, error) {
+			return fd.Idempotency, nil
+		},
+		HttpClient: libracommon.NewHttpClient,
+		Now:        fd.Clock.Now,
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,392 @@
+//
+// an in-memory easystore
+//
+
+package fakes
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+)
+
+// Easystore is an in-memory easystore
+type Easystore struct {
+	mu      sync.Mutex
+	objects map[string]*easystoreObject
+	counter int
+	now     func() time.Time
+}
+
+// NewEasystore creates an empty store, object timestamps come from the supplied clock
+func NewEasystore(now func() time.Time) *Easystore {
+	return &Easystore{objects: make(map[string]*easystoreObject), now: now}
+}
+
+// Add places an object directly into the store, bypassing the version checks
+func (fe *Easystore) Add(obj uvaeasystore.EasyStoreObject) uvaeasystore.EasyStoreObject {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	o := fe.copyIn(obj, uvaeasystore.AllComponents)
+	fe.objects[fe.key(o.namespace, o.id)] = o
+	return o.copyOut(uvaeasystore.AllComponents)
+}
+
+// Get returns the stored version of an object or nil if it does not exist
+func (fe *Easystore) Get(namespace string, id string) uvaeasystore.EasyStoreObject {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	o, found := fe.objects[fe.key(namespace, id)]
+	if found == false {
+		return nil
+	}
+	return o.copyOut(uvaeasystore.AllComponents)
+}
+
+func (fe *Easystore) ObjectGetByKey(namespace string, id string, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObject, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	o, found := fe.objects[fe.key(namespace, id)]
+	if found == false {
+		return nil, uvaeasystore.ErrNotFound
+	}
+	return o.copyOut(what), nil
+}
+
+func (fe *Easystore) ObjectGetByKeys(namespace string, ids []string, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObjectSet, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	objs := make([]uvaeasystore.EasyStoreObject, 0)
+	for _, id := range ids {
+		if o, found := fe.objects[fe.key(namespace, id)]; found == true {
+			objs = append(objs, o.copyOut(what))
+		}
+	}
+	if len(objs) == 0 {
+		return nil, uvaeasystore.ErrNotFound
+	}
+	return &easystoreObjectSet{objects: objs}, nil
+}
+
+func (fe *Easystore) ObjectGetByFields(namespace string, fields uvaeasystore.EasyStoreObjectFields, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObjectSet, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	objs := make([]uvaeasystore.EasyStoreObject, 0)
+	for _, o := range fe.objects {
+		if o.namespace != namespace {
+			continue
+		}
+		match := true
+		for k, v := range fields {
+			if o.fields[k] != v {
+				match = false
+				break
+			}
+		}
+		if match == true {
+			objs = append(objs, o.copyOut(what))
+		}
+	}
+	return &easystoreObjectSet{objects: objs}, nil
+}
+
+func (fe *Easystore) FileGetByKey(namespace string, id string, name string) (uvaeasystore.EasyStoreBlob, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	o, found := fe.objects[fe.key(namespace, id)]
+	if found == false {
+		return nil, uvaeasystore.ErrNotFound
+	}
+	for _, f := range o.files {
+		if f.Name() == name {
+			return f, nil
+		}
+	}
+	return nil, uvaeasystore.ErrFileNotFound
+}
+
+func (fe *Easystore) Close() error {
+	return nil
+}
+
+func (fe *Easystore) Check() error {
+	return nil
+}
+
+func (fe *Easystore) ObjectCreate(obj uvaeasystore.EasyStoreObject) (uvaeasystore.EasyStoreObject, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	if _, found := fe.objects[fe.key(obj.Namespace(), obj.Id())]; found == true {
+		return nil, uvaeasystore.ErrAlreadyExists
+	}
+	o := fe.copyIn(obj, uvaeasystore.AllComponents)
+	fe.objects[fe.key(o.namespace, o.id)] = o
+	return o.copyOut(uvaeasystore.AllComponents), nil
+}
+
+func (fe *Easystore) ObjectUpdate(obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObject, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	current, found := fe.objects[fe.key(obj.Namespace(), obj.Id())]
+	if found == false {
+		return nil, uvaeasystore.ErrNotFound
+	}
+	if current.vtag != obj.VTag() {
+		return nil, uvaeasystore.ErrStaleObject
+	}
+	updated := fe.copyIn(obj, what)
+	if what&uvaeasystore.Fields == 0 {
+		updated.fields = current.fields
+	}
+	if what&uvaeasystore.Metadata == 0 {
+		updated.metadata = current.metadata
+	}
+	if what&uvaeasystore.Files == 0 {
+		updated.files = current.files
+	}
+	updated.created = current.created
+	fe.objects[fe.key(updated.namespace, updated.id)] = updated
+	return updated.copyOut(uvaeasystore.AllComponents), nil
+}
+
+func (fe *Easystore) ObjectDelete(obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents) (uvaeasystore.EasyStoreObject, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	key := fe.key(obj.Namespace(), obj.Id())
+	current, found := fe.objects[key]
+	if found == false {
+		return nil, uvaeasystore.ErrNotFound
+	}
+	if what == uvaeasystore.BaseComponent || what == uvaeasystore.AllComponents {
+		delete(fe.objects, key)
+		return current.copyOut(uvaeasystore.AllComponents), nil
+	}
+	if what&uvaeasystore.Fields != 0 {
+		current.fields = uvaeasystore.DefaultEasyStoreFields()
+	}
+	if what&uvaeasystore.Metadata != 0 {
+		current.metadata = nil
+	}
+	if what&uvaeasystore.Files != 0 {
+		current.files = nil
+	}
+	current.vtag = fe.nextVtag()
+	return current.copyOut(uvaeasystore.AllComponents), nil
+}
+
+func (fe *Easystore) FileCreate(namespace string, id string, file uvaeasystore.EasyStoreBlob) error {
+	return fe.fileOp(namespace, id, func(o *easystoreObject) error {
+		for _, f := range o.files {
+			if f.Name() == file.Name() {
+				return uvaeasystore.ErrAlreadyExists
+			}
+		}
+		o.files = append(o.files, file)
+		return nil
+	})
+}
+
+func (fe *Easystore) FileDelete(namespace string, id string, name string) error {
+	return fe.fileOp(namespace, id, func(o *easystoreObject) error {
+		for ix, f := range o.files {
+			if f.Name() == name {
+				o.files = append(o.files[:ix], o.files[ix+1:]...)
+				return nil
+			}
+		}
+		return uvaeasystore.ErrFileNotFound
+	})
+}
+
+func (fe *Easystore) FileRename(namespace string, id string, name string, new string) error {
+	return fe.fileOp(namespace, id, func(o *easystoreObject) error {
+		for ix, f := range o.files {
+			if f.Name() == name {
+				pl, err := f.Payload()
+				if err != nil {
+					return err
+				}
+				o.files[ix] = uvaeasystore.NewEasyStoreBlob(new, f.MimeType(), pl)
+				return nil
+			}
+		}
+		return uvaeasystore.ErrFileNotFound
+	})
+}
+
+func (fe *Easystore) FileUpdate(namespace string, id string, file uvaeasystore.EasyStoreBlob) error {
+	return fe.fileOp(namespace, id, func(o *easystoreObject) error {
+		for ix, f := range o.files {
+			if f.Name() == file.Name() {
+				o.files[ix] = file
+				return nil
+			}
+		}
+		return uvaeasystore.ErrFileNotFound
+	})
+}
+
+func (fe *Easystore) fileOp(namespace string, id string, op func(*easystoreObject) error) error {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	o, found := fe.objects[fe.key(namespace, id)]
+	if found == false {
+		return uvaeasystore.ErrNotFound
+	}
+	err := op(o)
+	if err == nil {
+		o.vtag = fe.nextVtag()
+		o.modified = fe.now()
+	}
+	return err
+}
+
+func (fe *Easystore) key(namespace string, id string) string {
+	return fmt.Sprintf("%s/%s", namespace, id)
+}
+
+func (fe *Easystore) nextVtag() string {
+	fe.counter++
+	return fmt.Sprintf("vtag-%d", fe.counter)
+}
+
+// copy an inbound object into a new stored version
+func (fe *Easystore) copyIn(obj uvaeasystore.EasyStoreObject, what uvaeasystore.EasyStoreComponents) *easystoreObject {
+	id := obj.Id()
+	if len(id) == 0 {
+		fe.counter++
+		id = fmt.Sprintf("oid:fake%06d", fe.counter)
+	}
+	now := fe.now()
+	o := &easystoreObject{
+		namespace: obj.Namespace(),
+		id:        id,
+		vtag:      fe.nextVtag(),
+		created:   now,
+		modified:  now,
+	}
+	if what&uvaeasystore.Fields != 0 {
+		o.fields = copyFields(obj.Fields())
+	}
+	if what&uvaeasystore.Metadata != 0 {
+		o.metadata = obj.Metadata()
+	}
+	if what&uvaeasystore.Files != 0 {
+		o.files = append([]uvaeasystore.EasyStoreBlob{}, obj.Files()...)
+	}
+	return o
+}
+
+// easystoreObject is an object held by the fake easystore
+type easystoreObject struct {
+	namespace string
+	id        string
+	vtag      string
+	created   time.Time
+	modified  time.Time
+	fields    uvaeasystore.EasyStoreObjectFields
+	metadata  uvaeasystore.EasyStoreMetadata
+	files     []uvaeasystore.EasyStoreBlob
+}
+
+// copy a stored object out, including only the requested components
+func (o *easystoreObject) copyOut(what uvaeasystore.EasyStoreComponents) *easystoreObject {
+	c := &easystoreObject{
+		namespace: o.namespace,
+		id:        o.id,
+		vtag:      o.vtag,
+		created:   o.created,
+		modified:  o.modified,
+	}
+	if what&uvaeasystore.Fields != 0 {
+		c.fields = copyFields(o.fields)
+	}
+	if what&uvaeasystore.Metadata != 0 {
+		c.metadata = o.metadata
+	}
+	if what&uvaeasystore.Files != 0 {
+		c.files = append([]uvaeasystore.EasyStoreBlob{}, o.files...)
+	}
+	return c
+}
+
+func (o *easystoreObject) Namespace() string {
+	return o.namespace
+}
+
+func (o *easystoreObject) Id() string {
+	return o.id
+}
+
+func (o *easystoreObject) VTag() string {
+	return o.vtag
+}
+
+func (o *easystoreObject) Created() time.Time {
+	return o.created
+}
+
+func (o *easystoreObject) Modified() time.Time {
+	return o.modified
+}
+
+func (o *easystoreObject) Fields() uvaeasystore.EasyStoreObjectFields {
+	return o.fields
+}
+
+func (o *easystoreObject) Metadata() uvaeasystore.EasyStoreMetadata {
+	return o.metadata
+}
+
+func (o *easystoreObject) Files() []uvaeasystore.EasyStoreBlob {
+	return o.files
+}
+
+func (o *easystoreObject) SetNamespace(namespace string) {
+	o.namespace = namespace
+}
+
+func (o *easystoreObject) SetFields(f uvaeasystore.EasyStoreObjectFields) {
+	o.fields = f
+}
+
+func (o *easystoreObject) SetMetadata(m uvaeasystore.EasyStoreMetadata) {
+	o.metadata = m
+}
+
+func (o *easystoreObject) SetFiles(f []uvaeasystore.EasyStoreBlob) {
+	o.files = f
+}
+
+// easystoreObjectSet iterates over a set of fake objects
+type easystoreObjectSet struct {
+	objects []uvaeasystore.EasyStoreObject
+	current int
+}
+
+func (s *easystoreObjectSet) Count() uint {
+	return uint(len(s.objects))
+}
+
+func (s *easystoreObjectSet) Next() (uvaeasystore.EasyStoreObject, error) {
+	if s.current == len(s.objects) {
+		return nil, io.EOF
+	}
+	s.current++
+	return s.objects[s.current-1], nil
+}
+
+func copyFields(fields uvaeasystore.EasyStoreObjectFields) uvaeasystore.EasyStoreObjectFields {
+	c := uvaeasystore.DefaultEasyStoreFields()
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+//
+// end of file
+//
@@ -0,0 +1,134 @@
+//
+// tests for the in-memory easystore, it must behave like the proxy for the tests built on it
+//
+
+package fakes
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+)
+
+var testTime = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func testObject(id string, fields map[string]string) uvaeasystore.EasyStoreObject {
+	obj := uvaeasystore.NewEasyStoreObject("libraetd", id)
+	f := uvaeasystore.DefaultEasyStoreFields()
+	for k, v := range fields {
+		f[k] = v
+	}
+	obj.SetFields(f)
+	return obj
+}
+
+func TestEasystoreCreate(t *testing.T) {
+	es := NewEasystore(NewClock(testTime).Now)
+
+	created, err := es.ObjectCreate(testObject("", nil))
+	if err != nil || len(created.Id()) == 0 || len(created.VTag()) == 0 {
+		t.Fatalf("expected an identifier and vtag to be assigned, got %+v %v", created, err)
+	}
+	if created.Created() != testTime {
+		t.Errorf("expected the clock time, got %s", created.Created())
+	}
+	_, err = es.ObjectCreate(testObject(created.Id(), nil))
+	if errors.Is(err, uvaeasystore.ErrAlreadyExists) == false {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+func TestEasystoreUpdateChecksVersion(t *testing.T) {
+	es := NewEasystore(NewClock(testTime).Now)
+	obj := es.Add(testObject("oid:1", map[string]string{"title": "first"}))
+
+	fields := obj.Fields()
+	fields["title"] = "second"
+	obj.SetFields(fields)
+	updated, err := es.ObjectUpdate(obj, uvaeasystore.Fields)
+	if err != nil || updated.VTag() == obj.VTag() {
+		t.Fatalf("expected a new version, got %v", err)
+	}
+
+	// the old version is now stale
+	_, err = es.ObjectUpdate(obj, uvaeasystore.Fields)
+	if errors.Is(err, uvaeasystore.ErrStaleObject) == false {
+		t.Errorf("expected ErrStaleObject, got %v", err)
+	}
+	_, err = es.ObjectUpdate(testObject("oid:missing", nil), uvaeasystore.Fields)
+	if errors.Is(err, uvaeasystore.ErrNotFound) == false {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEasystoreUpdateOnlyWhat(t *testing.T) {
+	es := NewEasystore(NewClock(testTime).Now)
+	obj := testObject("oid:1", map[string]string{"title": "first"})
+	obj.SetMetadata(uvaeasystore.NewEasyStoreMetadata("application/json", []byte(`{"a":1}`)))
+	obj = es.Add(obj)
+
+	// a fields update leaves the metadata alone
+	fields := obj.Fields()
+	fields["title"] = "second"
+	obj.SetFields(fields)
+	obj.SetMetadata(nil)
+	_, err := es.ObjectUpdate(obj, uvaeasystore.Fields)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	stored := es.Get("libraetd", "oid:1")
+	if stored.Fields()["title"] != "second" || stored.Metadata() == nil {
+		t.Errorf("expected only the fields to change, got %v %v", stored.Fields(), stored.Metadata())
+	}
+}
+
+func TestEasystoreCopies(t *testing.T) {
+	es := NewEasystore(NewClock(testTime).Now)
+	es.Add(testObject("oid:1", map[string]string{"title": "first"}))
+
+	// changing a returned object does not change the store
+	obj, _ := es.ObjectGetByKey("libraetd", "oid:1", uvaeasystore.Fields)
+	fields := obj.Fields()
+	fields["title"] = "changed"
+	if es.Get("libraetd", "oid:1").Fields()["title"] != "first" {
+		t.Errorf("expected the stored object to be unchanged")
+	}
+
+	// and only the requested components are returned
+	obj, _ = es.ObjectGetByKey("libraetd", "oid:1", uvaeasystore.Metadata)
+	if len(obj.Fields()) != 0 {
+		t.Errorf("expected no fields, got %v", obj.Fields())
+	}
+}
+
+func TestEasystoreGetByFields(t *testing.T) {
+	es := NewEasystore(NewClock(testTime).Now)
+	es.Add(testObject("oid:1", map[string]string{"source": "sis", "draft": "true"}))
+	es.Add(testObject("oid:2", map[string]string{"source": "sis", "draft": "false"}))
+	es.Add(testObject("oid:3", map[string]string{"source": "optional", "draft": "true"}))
+
+	set, err := es.ObjectGetByFields("libraetd", uvaeasystore.EasyStoreObjectFields{"source": "sis", "draft": "true"}, uvaeasystore.Fields)
+	if err != nil || set.Count() != 1 {
+		t.Fatalf("expected one match, got %v", err)
+	}
+	obj, _ := set.Next()
+	if obj.Id() != "oid:1" {
+		t.Errorf("expected oid:1, got %s", obj.Id())
+	}
+	_, err = set.Next()
+	if err == nil {
+		t.Errorf("expected the set to be exhausted")
+	}
+
+	set, _ = es.ObjectGetByFields("libraopen", nil, uvaeasystore.Fields)
+	if set.Count() != 0 {
+		t.Errorf("expected nothing in another namespace, got %d", set.Count())
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,92 @@
+//
+// http service stand-ins with canned responses
+//
+
+package fakes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// HttpRequest is a request received by a fake http service
+type HttpRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// httpResponse is a canned response
+type httpResponse struct {
+	Status int
+	Body   string
+}
+
+// HttpService is an httptest server returning canned responses by method and path,
+// unknown routes return 404
+type HttpService struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses map[string][]httpResponse
+	requests  []HttpRequest
+}
+
+func NewHttpService() *HttpService {
+	fs := &HttpService{responses: make(map[string][]httpResponse)}
+	fs.Server = httptest.NewServer(http.HandlerFunc(fs.serve))
+	return fs
+}
+
+// Respond adds a canned response for the method and path. Multiple responses for the same route
+// are returned in order, the last one is repeated
+func (fs *HttpService) Respond(method string, path string, status int, body string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	route := method + " " + path
+	fs.responses[route] = append(fs.responses[route], httpResponse{Status: status, Body: body})
+}
+
+// Requests returns the requests received so far
+func (fs *HttpService) Requests() []HttpRequest {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]HttpRequest{}, fs.requests...)
+}
+
+func (fs *HttpService) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	fs.mu.Lock()
+	fs.requests = append(fs.requests, HttpRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	route := r.Method + " " + r.URL.Path
+	responses := fs.responses[route]
+	var resp *httpResponse
+	if len(responses) != 0 {
+		resp = &responses[0]
+		if len(responses) > 1 {
+			fs.responses[route] = responses[1:]
+		}
+	}
+	fs.mu.Unlock()
+
+	if resp == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
+
+//
+// end of file
+//
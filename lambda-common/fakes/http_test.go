@@ -0,0 +1,67 @@
+//
+// tests for the canned response http service
+//
+
+package fakes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s (%s)", url, err.Error())
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHttpServiceResponses(t *testing.T) {
+	svc := NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/thing", 503, "busy")
+	svc.Respond("GET", "/thing", 200, "ok")
+
+	// responses are returned in order and the last one repeats
+	for _, expected := range []int{503, 200, 200} {
+		status, _ := get(t, svc.URL+"/thing")
+		if status != expected {
+			t.Errorf("expected %d, got %d", expected, status)
+		}
+	}
+	// unknown routes are not found, the method is part of the route
+	if status, _ := get(t, svc.URL+"/other"); status != 404 {
+		t.Errorf("expected 404, got %d", status)
+	}
+	resp, err := http.Post(svc.URL+"/thing", "text/plain", bytes.NewReader([]byte("payload")))
+	if err != nil || resp.StatusCode != 404 {
+		t.Errorf("expected 404 for another method, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHttpServiceRequests(t *testing.T) {
+	svc := NewHttpService()
+	defer svc.Close()
+
+	resp, _ := http.Post(svc.URL+"/thing?a=1", "text/plain", bytes.NewReader([]byte("payload")))
+	resp.Body.Close()
+
+	requests := svc.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	r := requests[0]
+	if r.Method != "POST" || r.Path != "/thing" || r.Query != "a=1" || string(r.Body) != "payload" || r.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected request %+v", r)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,73 @@
+//
+// an in-memory idempotency ledger
+//
+
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// IdempotencyStore is an in-memory ledger, records are keyed by event id and lambda
+type IdempotencyStore struct {
+	Err     error // returned by every method when set
+	mu      sync.Mutex
+	records map[string]libracommon.IdempotencyRecord
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{records: make(map[string]libracommon.IdempotencyRecord)}
+}
+
+func (fi *IdempotencyStore) Claim(ctx context.Context, record libracommon.IdempotencyRecord) (*libracommon.IdempotencyRecord, error) {
+	if fi.Err != nil {
+		return nil, fi.Err
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	key := record.EventId + "/" + record.Lambda
+	existing, found := fi.records[key]
+	if found == true && existing.Expires.Before(record.Updated) == false {
+		return &existing, nil
+	}
+	fi.records[key] = record
+	return nil, nil
+}
+
+func (fi *IdempotencyStore) Complete(ctx context.Context, record libracommon.IdempotencyRecord) error {
+	if fi.Err != nil {
+		return fi.Err
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.records[record.EventId+"/"+record.Lambda] = record
+	return nil
+}
+
+func (fi *IdempotencyStore) Release(ctx context.Context, eventId string, lambda string) error {
+	if fi.Err != nil {
+		return fi.Err
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	delete(fi.records, eventId+"/"+lambda)
+	return nil
+}
+
+// Records returns the ledger contents
+func (fi *IdempotencyStore) Records() []libracommon.IdempotencyRecord {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	records := make([]libracommon.IdempotencyRecord, 0, len(fi.records))
+	for _, r := range fi.records {
+		records = append(records, r)
+	}
+	return records
+}
+
+//
+// end of file
+//
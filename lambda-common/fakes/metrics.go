@@ -0,0 +1,51 @@
+//
+// a metrics sink that records metrics so they can be inspected
+//
+
+package fakes
+
+import (
+	"sync"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// MetricsSink records the metrics sent to it
+type MetricsSink struct {
+	mu      sync.Mutex
+	metrics []libracommon.Metric
+}
+
+func NewMetricsSink() *MetricsSink {
+	return &MetricsSink{metrics: make([]libracommon.Metric, 0)}
+}
+
+func (fm *MetricsSink) Record(metric libracommon.Metric) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.metrics = append(fm.metrics, metric)
+}
+
+// Metrics returns the metrics recorded so far
+func (fm *MetricsSink) Metrics() []libracommon.Metric {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return append([]libracommon.Metric(nil), fm.metrics...)
+}
+
+// Sum returns the total of the named metric
+func (fm *MetricsSink) Sum(name string) float64 {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	total := 0.0
+	for _, m := range fm.metrics {
+		if m.Name == name {
+			total += m.Value
+		}
+	}
+	return total
+}
+
+//
+// end of file
+//
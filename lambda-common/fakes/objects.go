@@ -0,0 +1,48 @@
+//
+// an in-memory object store
+//
+
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ObjectStore is an in-memory object store, objects are keyed by bucket/key
+type ObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewObjectStore() *ObjectStore {
+	return &ObjectStore{objects: make(map[string][]byte)}
+}
+
+func (fo *ObjectStore) PutObject(ctx context.Context, bucket string, key string, buffer []byte) error {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	fo.objects[fmt.Sprintf("%s/%s", bucket, key)] = append([]byte{}, buffer...)
+	return nil
+}
+
+func (fo *ObjectStore) UploadFile(ctx context.Context, bucket string, key string, localName string) error {
+	buf, err := os.ReadFile(localName)
+	if err != nil {
+		return os.ErrNotExist
+	}
+	return fo.PutObject(ctx, bucket, key, buf)
+}
+
+// Get returns the object stored at bucket/key or nil
+func (fo *ObjectStore) Get(bucket string, key string) []byte {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	return fo.objects[fmt.Sprintf("%s/%s", bucket, key)]
+}
+
+//
+// end of file
+//
@@ -0,0 +1,48 @@
+//
+// an in-memory parameter store
+//
+
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// ParameterStore is an in-memory parameter store
+type ParameterStore struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func NewParameterStore() *ParameterStore {
+	return &ParameterStore{values: make(map[string]string)}
+}
+
+func (fp *ParameterStore) GetParameter(ctx context.Context, name string) (string, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	v, found := fp.values[name]
+	if found == false {
+		return "", fmt.Errorf("%w: [%s]", libracommon.ErrParameterNotFound, name)
+	}
+	return v, nil
+}
+
+func (fp *ParameterStore) GetSecureParameter(ctx context.Context, name string) (string, error) {
+	return fp.GetParameter(ctx, name)
+}
+
+func (fp *ParameterStore) SetParameter(ctx context.Context, name string, value string) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.values[name] = value
+	return nil
+}
+
+//
+// end of file
+//
@@ -0,0 +1,42 @@
+//
+// an in-memory secrets manager
+//
+
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SecretStore is an in-memory secrets manager
+type SecretStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func NewSecretStore() *SecretStore {
+	return &SecretStore{secrets: make(map[string]string)}
+}
+
+// Set adds or replaces a secret, usually a JSON object of key/value pairs
+func (fs *SecretStore) Set(name string, value string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.secrets[name] = value
+}
+
+func (fs *SecretStore) GetSecret(ctx context.Context, name string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	v, found := fs.secrets[name]
+	if found == false {
+		return "", fmt.Errorf("secret not found: [%s]", name)
+	}
+	return v, nil
+}
+
+//
+// end of file
+//
@@ -1,19 +1,24 @@
-package main
+package libracommon
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"errors"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
-)
 
-var maxHttpRetries = 3
-var httpRetrySleepTime = 100 * time.Millisecond
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
 
-func newHttpClient(maxConnections int, timeout int) *http.Client {
+func NewHttpClient(maxConnections int, timeout int) *http.Client {
 
 	defaultTransport := &http.Transport{
 		Dial: (&net.Dialer{
@@ -31,34 +36,34 @@ func newHttpClient(maxConnections int, timeout int) *http.Client {
 	}
 }
 
-func httpGet(client *http.Client, url string) ([]byte, error) {
+func HttpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		fmt.Printf("ERROR: GET %s failed with error (%s)\n", url, err)
+		LogError("GET %s failed with error (%s)", url, err)
 		return nil, err
 	}
 
-	return httpSend(client, req)
+	return HttpSend(client, req)
 }
 
-func httpDelete(client *http.Client, url string) ([]byte, error) {
+func HttpDelete(ctx context.Context, client *http.Client, url string) ([]byte, error) {
 
-	req, err := http.NewRequest("DELETE", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
-		fmt.Printf("ERROR: DELETE %s failed with error (%s)\n", url, err)
+		LogError("DELETE %s failed with error (%s)", url, err)
 		return nil, err
 	}
 
-	return httpSend(client, req)
+	return HttpSend(client, req)
 }
 
-func httpPost(client *http.Client, url string, payload []byte, contentType string) ([]byte, error) {
+func HttpPost(ctx context.Context, client *http.Client, url string, payload []byte, contentType string) ([]byte, error) {
 
 	reader := bytes.NewReader(payload)
-	req, err := http.NewRequest("POST", url, reader)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, reader)
 	if err != nil {
-		fmt.Printf("ERROR: POST %s failed with error (%s)\n", url, err)
+		LogError("POST %s failed with error (%s)", url, err)
 		return nil, err
 	}
 
@@ -67,15 +72,15 @@ func httpPost(client *http.Client, url string, payload []byte, contentType strin
 		req.Header.Add("content-type", contentType)
 	}
 
-	return httpSend(client, req)
+	return HttpSend(client, req)
 }
 
-func httpPut(client *http.Client, url string, payload []byte, contentType string) ([]byte, error) {
+func HttpPut(ctx context.Context, client *http.Client, url string, payload []byte, contentType string) ([]byte, error) {
 
 	reader := bytes.NewReader(payload)
-	req, err := http.NewRequest("PUT", url, reader)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, reader)
 	if err != nil {
-		fmt.Printf("ERROR: PUT %s failed with error (%s)\n", url, err)
+		LogError("PUT %s failed with error (%s)", url, err)
 		return nil, err
 	}
 
@@ -84,89 +89,167 @@ func httpPut(client *http.Client, url string, payload []byte, contentType string
 		req.Header.Add("content-type", contentType)
 	}
 
-	return httpSend(client, req)
+	return HttpSend(client, req)
 }
 
-func httpSend(client *http.Client, req *http.Request) ([]byte, error) {
+// HttpContentLength gets the size of the resource at the url, only the first byte is requested
+// when the server supports ranges and the body is never read. A GET rather than a HEAD so that
+// presigned object URLs work
+func HttpContentLength(ctx context.Context, client *http.Client, url string) (int64, error) {
 
-	var response *http.Response
-	var err error
-	url := req.URL.String()
-	count := 0
-	for {
-		start := time.Now()
-		response, err = client.Do(req)
-		duration := time.Since(start)
-		fmt.Printf("INFO: %s %s (elapsed %d ms)\n", req.Method, url, duration.Milliseconds())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		LogError("GET %s failed with error (%s)", url, err)
+		return 0, Permanent(err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
 
-		count++
-		if err != nil {
-			if canRetry(err) == false {
-				fmt.Printf("ERROR: %s %s failed with error (%s)\n", req.Method, url, err)
-				return nil, err
-			}
+	_, response, err := httpSendTraced(client, req, DefaultRetryPolicy, false)
+	if err != nil {
+		return 0, err
+	}
 
-			// break when tried too many times
-			if count >= maxHttpRetries {
-				return nil, err
-			}
+	// a partial response, bytes 0-0/12345, otherwise the server is sending everything
+	contentRange := response.Header.Get("Content-Range")
+	if len(contentRange) == 0 {
+		if response.ContentLength < 0 {
+			LogError("GET %s returned no content length", url)
+			return 0, Permanent(ErrNoContentLength)
+		}
+		return response.ContentLength, nil
+	}
+	_, total, _ := strings.Cut(contentRange, "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		LogError("GET %s returned an unusable content range [%s]", url, contentRange)
+		return 0, Permanent(err)
+	}
+	return size, nil
+}
 
-			fmt.Printf("ERROR: %s %s failed with error, retrying (%s)\n", req.Method, url, err)
+// HttpSend sends the request using DefaultRetryPolicy, retries stop when the request context is done
+func HttpSend(client *http.Client, req *http.Request) ([]byte, error) {
+	return HttpSendWithPolicy(client, req, DefaultRetryPolicy)
+}
 
-			// sleep for a bit before retrying
-			time.Sleep(httpRetrySleepTime)
-		} else {
+// HttpSendWithPolicy sends the request, retrying transient failures as the policy allows.
+// The request is traced and the trace context passed to the service
+func HttpSendWithPolicy(client *http.Client, req *http.Request, policy RetryPolicy) ([]byte, error) {
+	body, _, err := httpSendTraced(client, req, policy, true)
+	return body, err
+}
 
-			defer response.Body.Close()
+// httpSendTraced sends the request in a span, the response is returned with the body. The body
+// of a successful response is only read when readBody is set, otherwise it is closed unread
+func httpSendTraced(client *http.Client, req *http.Request, policy RetryPolicy, readBody bool) ([]byte, *http.Response, error) {
+
+	// the query may contain credentials so only the path is recorded
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(string(semconv.HTTPRequestMethodKey), req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path)))
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	body, response, err := httpSend(client, req, policy, readBody)
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) == true {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusErr.StatusCode))
+	}
+	endSpan(span, err)
+	return body, response, err
+}
 
-			if response.StatusCode >= 300 {
-				logLevel := "ERROR"
-				// log StatusNotFound as informational instead of as an error
-				if response.StatusCode == http.StatusNotFound {
-					logLevel = "INFO"
-				}
-				fmt.Printf("%s: %s %s failed with status %d\n", logLevel, req.Method, url, response.StatusCode)
+func httpSend(client *http.Client, req *http.Request, policy RetryPolicy, readBody bool) ([]byte, *http.Response, error) {
 
-				body, _ := io.ReadAll(response.Body)
+	url := req.URL.String()
+	for attempt := 1; ; attempt++ {
 
-				return body, fmt.Errorf("request returns HTTP %d", response.StatusCode)
+		// the body is consumed by each attempt so get a fresh one before retrying
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				LogError("%s %s cannot recreate request body (%s)", req.Method, url, err)
+				return nil, nil, Permanent(err)
 			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		response, err := client.Do(req)
+		duration := time.Since(start)
+		LogInfo("%s %s (elapsed %d ms)", req.Method, url, duration.Milliseconds())
+
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(response.StatusCode)
+		}
+		RecordTiming("HttpLatency", duration, map[string]string{"Host": req.URL.Host, "Status": status})
 
-			body, err := io.ReadAll(response.Body)
+		if err != nil {
+			if policy.canRetry(req, attempt) == false || retryableError(err) == false {
+				LogError("%s %s failed with error (%s)", req.Method, url, err)
+				return nil, nil, transportError(err)
+			}
+
+			delay := policy.backoff(attempt)
+			LogWarning("%s %s failed with error, retrying in %d ms (%s)", req.Method, url, delay.Milliseconds(), err)
+			err = sleepContext(req.Context(), delay)
 			if err != nil {
-				fmt.Printf("ERROR: %s %s failed with error (%s)\n", req.Method, url, err)
-				return nil, err
+				return nil, nil, transportError(err)
 			}
-			//fmt.Printf( body )
-			return body, nil
+			continue
 		}
-	}
-}
 
-// examines the error and decides if it can be retried
-func canRetry(err error) bool {
+		if response.StatusCode >= 300 {
+			body, _ := io.ReadAll(response.Body)
+			response.Body.Close()
 
-	if strings.Contains(err.Error(), "operation timed out") == true {
-		return true
-	}
+			if retryableStatus(response.StatusCode) == true && policy.canRetry(req, attempt) == true {
+				delay := policy.retryAfter(response.Header.Get("Retry-After"), attempt, time.Now())
+				LogWarning("%s %s failed with status %d, retrying in %d ms", req.Method, url, response.StatusCode, delay.Milliseconds())
+				err = sleepContext(req.Context(), delay)
+				if err != nil {
+					return body, response, Transient(err)
+				}
+				continue
+			}
 
-	if strings.Contains(err.Error(), "Client.Timeout exceeded") == true {
-		return true
-	}
+			// log StatusNotFound as informational instead of as an error
+			if response.StatusCode == http.StatusNotFound {
+				LogInfo("%s %s failed with status %d", req.Method, url, response.StatusCode)
+			} else {
+				LogError("%s %s failed with status %d", req.Method, url, response.StatusCode)
+			}
+			return body, response, httpStatusError(response.StatusCode)
+		}
 
-	if strings.Contains(err.Error(), "write: broken pipe") == true {
-		return true
-	}
+		if readBody == false {
+			response.Body.Close()
+			return nil, response, nil
+		}
 
-	if strings.Contains(err.Error(), "no such host") == true {
-		return true
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			LogError("%s %s failed with error (%s)", req.Method, url, err)
+			return nil, nil, transportError(err)
+		}
+		//fmt.Printf( body )
+		return body, response, nil
 	}
+}
 
-	if strings.Contains(err.Error(), "network is down") == true {
-		return true
+// transportError classifies a failure to get a response. Errors that fail every time (a bad
+// URL or certificate, an unsupported scheme) are permanent, running out of time is not
+func transportError(err error) error {
+	if errors.Is(err, context.Canceled) == true || errors.Is(err, context.DeadlineExceeded) == true || retryableError(err) == true {
+		return Transient(err)
 	}
-
-	return false
+	return Permanent(err)
 }
 
 //
@@ -0,0 +1,82 @@
+//
+// tests for the HTTP helpers
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func TestHttpContentLengthRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("expected the first byte to be requested, got [%s]", r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Range", "bytes 0-0/12345")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("x"))
+	}))
+	defer server.Close()
+
+	size, err := libracommon.HttpContentLength(context.Background(), server.Client(), server.URL)
+	if err != nil || size != 12345 {
+		t.Errorf("expected 12345, got %d %v", size, err)
+	}
+}
+
+func TestHttpContentLengthIgnoredRange(t *testing.T) {
+	// the server sends everything, the first chunk then nothing more until the client goes away
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "104857600")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(make([]byte, 4096))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	// reading the body would run into the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	size, err := libracommon.HttpContentLength(ctx, server.Client(), server.URL)
+	if err != nil || size != 104857600 {
+		t.Errorf("expected the content length without reading the body, got %d %v", size, err)
+	}
+}
+
+func TestHttpContentLengthUnknown(t *testing.T) {
+	// a streamed response has no length
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("streamed"))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(" content"))
+	}))
+	defer server.Close()
+
+	_, err := libracommon.HttpContentLength(context.Background(), server.Client(), server.URL)
+	if errors.Is(err, libracommon.ErrNoContentLength) == false || libracommon.IsPermanent(err) == false {
+		t.Errorf("expected a permanent ErrNoContentLength, got %v", err)
+	}
+}
+
+func TestHttpContentLengthFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := libracommon.HttpContentLength(context.Background(), server.Client(), server.URL)
+	if libracommon.IsHttpNotFound(err) == false {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+//
+// end of file
+//
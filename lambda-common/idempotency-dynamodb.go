@@ -0,0 +1,123 @@
+//
+// idempotency ledger kept in a DynamoDB table. The table is keyed on event_id (partition)
+// and lambda (sort) and the expires attribute can be used as the table TTL
+//
+
+package libracommon
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// our DynamoDB IdempotencyStore implementation
+type dynamoIdempotencyStore struct {
+	client *dynamodb.Client
+	table  string
+}
+
+func newDynamoIdempotencyStore(table string) (IdempotencyStore, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &dynamoIdempotencyStore{client: dynamodb.NewFromConfig(cfg), table: table}, nil
+}
+
+func (ds *dynamoIdempotencyStore) Claim(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	_, err := ds.client.PutItem(ctx,
+		&dynamodb.PutItemInput{
+			TableName:           aws.String(ds.table),
+			Item:                dynamoItem(record),
+			ConditionExpression: aws.String("attribute_not_exists(event_id) OR expires < :now"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": dynamoTime(record.Updated),
+			},
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		})
+
+	if err == nil {
+		return nil, nil
+	}
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) == true {
+		existing := dynamoRecord(ccf.Item)
+		return &existing, nil
+	}
+	return nil, err
+}
+
+func (ds *dynamoIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	_, err := ds.client.PutItem(ctx,
+		&dynamodb.PutItemInput{
+			TableName: aws.String(ds.table),
+			Item:      dynamoItem(record),
+		})
+	return err
+}
+
+func (ds *dynamoIdempotencyStore) Release(ctx context.Context, eventId string, lambda string) error {
+	_, err := ds.client.DeleteItem(ctx,
+		&dynamodb.DeleteItemInput{
+			TableName: aws.String(ds.table),
+			Key: map[string]types.AttributeValue{
+				"event_id": &types.AttributeValueMemberS{Value: eventId},
+				"lambda":   &types.AttributeValueMemberS{Value: lambda},
+			},
+		})
+	return err
+}
+
+func dynamoItem(record IdempotencyRecord) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"event_id": &types.AttributeValueMemberS{Value: record.EventId},
+		"lambda":   &types.AttributeValueMemberS{Value: record.Lambda},
+		"outcome":  &types.AttributeValueMemberS{Value: record.Outcome},
+		"updated":  dynamoTime(record.Updated),
+		"expires":  dynamoTime(record.Expires),
+	}
+}
+
+func dynamoRecord(item map[string]types.AttributeValue) IdempotencyRecord {
+	return IdempotencyRecord{
+		EventId: dynamoString(item["event_id"]),
+		Lambda:  dynamoString(item["lambda"]),
+		Outcome: dynamoString(item["outcome"]),
+		Updated: dynamoTimeValue(item["updated"]),
+		Expires: dynamoTimeValue(item["expires"]),
+	}
+}
+
+// times are stored as epoch seconds so that they can be used as a TTL
+func dynamoTime(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+func dynamoTimeValue(av types.AttributeValue) time.Time {
+	if n, ok := av.(*types.AttributeValueMemberN); ok == true {
+		secs, err := strconv.ParseInt(n.Value, 10, 64)
+		if err == nil {
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
+
+func dynamoString(av types.AttributeValue) string {
+	if s, ok := av.(*types.AttributeValueMemberS); ok == true {
+		return s.Value
+	}
+	return ""
+}
+
+//
+// end of file
+//
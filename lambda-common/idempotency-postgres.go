@@ -0,0 +1,102 @@
+//
+// idempotency ledger kept in a postgres table, see migrations for the schema
+//
+
+package libracommon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// the database configuration, only required when the postgres store is selected
+type idempotencyDbConfig struct {
+	DbHost     string `env:"IDEMPOTENCY_DB_HOST" required:"true"`                   // database host
+	DbPort     int    `env:"IDEMPOTENCY_DB_PORT" default:"5432"`                    // database port
+	DbName     string `env:"IDEMPOTENCY_DB_NAME" required:"true"`                   // database name
+	DbUser     string `env:"IDEMPOTENCY_DB_USER" required:"true"`                   // database user
+	DbPassword string `env:"IDEMPOTENCY_DB_PASSWORD" required:"true" secret:"true"` // database password
+}
+
+// our postgres IdempotencyStore implementation
+type postgresIdempotencyStore struct {
+	db    *sql.DB
+	table string
+}
+
+func newPostgresIdempotencyStore(table string) (IdempotencyStore, error) {
+	var cfg idempotencyDbConfig
+	err := LoadConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	connectionStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+		cfg.DbHost, cfg.DbPort, cfg.DbUser, cfg.DbPassword, cfg.DbName)
+
+	db, err := sql.Open("postgres", connectionStr)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresIdempotencyStore{db: db, table: pq.QuoteIdentifier(table)}, nil
+}
+
+// how many times a claim is attempted when the conflicting record disappears before it can be read
+const postgresClaimAttempts = 3
+
+func (ps *postgresIdempotencyStore) Claim(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+
+	// insert, or take over an expired record
+	insert := fmt.Sprintf(`INSERT INTO %[1]s (event_id, lambda, outcome, updated_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_id, lambda) DO UPDATE
+		SET outcome = EXCLUDED.outcome, updated_at = EXCLUDED.updated_at, expires_at = EXCLUDED.expires_at
+		WHERE %[1]s.expires_at < EXCLUDED.updated_at`, ps.table)
+	query := fmt.Sprintf("SELECT outcome, updated_at, expires_at FROM %s WHERE event_id = $1 AND lambda = $2", ps.table)
+
+	for attempt := 1; attempt <= postgresClaimAttempts; attempt++ {
+		result, err := ps.db.ExecContext(ctx, insert, record.EventId, record.Lambda, record.Outcome, record.Updated, record.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n != 0 {
+			return nil, nil
+		}
+
+		existing := IdempotencyRecord{EventId: record.EventId, Lambda: record.Lambda}
+		err = ps.db.QueryRowContext(ctx, query, record.EventId, record.Lambda).Scan(&existing.Outcome, &existing.Updated, &existing.Expires)
+		if errors.Is(err, sql.ErrNoRows) == true {
+			// released since the insert, try the claim again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	return nil, fmt.Errorf("claiming event %s: record keeps changing", record.EventId)
+}
+
+func (ps *postgresIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	query := fmt.Sprintf("UPDATE %s SET outcome = $3, updated_at = $4, expires_at = $5 WHERE event_id = $1 AND lambda = $2", ps.table)
+	_, err := ps.db.ExecContext(ctx, query, record.EventId, record.Lambda, record.Outcome, record.Updated, record.Expires)
+	return err
+}
+
+func (ps *postgresIdempotencyStore) Release(ctx context.Context, eventId string, lambda string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE event_id = $1 AND lambda = $2", ps.table)
+	_, err := ps.db.ExecContext(ctx, query, eventId, lambda)
+	return err
+}
+
+//
+// end of file
+//
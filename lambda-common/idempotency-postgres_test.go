@@ -0,0 +1,150 @@
+//
+// tests for the postgres idempotency ledger, run against a scripted database driver
+//
+
+package libracommon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// the scripted responses, each insert affects the next count and each select returns the
+// next row or no row when nil
+type scriptedDb struct {
+	mu       sync.Mutex
+	inserted []int64
+	selected [][]driver.Value
+	inserts  int
+	selects  int
+}
+
+type scriptedConn struct{ db *scriptedDb }
+
+func (sc *scriptedConn) Prepare(query string) (driver.Stmt, error) {
+	return &scriptedStmt{db: sc.db}, nil
+}
+func (sc *scriptedConn) Close() error              { return nil }
+func (sc *scriptedConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type scriptedStmt struct{ db *scriptedDb }
+
+func (ss *scriptedStmt) Close() error  { return nil }
+func (ss *scriptedStmt) NumInput() int { return -1 }
+
+func (ss *scriptedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+	n := ss.db.inserted[min(ss.db.inserts, len(ss.db.inserted)-1)]
+	ss.db.inserts++
+	return driver.RowsAffected(n), nil
+}
+
+func (ss *scriptedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+	row := ss.db.selected[min(ss.db.selects, len(ss.db.selected)-1)]
+	ss.db.selects++
+	return &scriptedRows{row: row}, nil
+}
+
+type scriptedRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (sr *scriptedRows) Columns() []string { return []string{"outcome", "updated_at", "expires_at"} }
+func (sr *scriptedRows) Close() error      { return nil }
+
+func (sr *scriptedRows) Next(dest []driver.Value) error {
+	if sr.row == nil || sr.done == true {
+		return io.EOF
+	}
+	copy(dest, sr.row)
+	sr.done = true
+	return nil
+}
+
+var registerScripted sync.Once
+var scriptedDbs sync.Map
+
+// a postgres store backed by the script
+func scriptedStore(t *testing.T, sd *scriptedDb) *postgresIdempotencyStore {
+	registerScripted.Do(func() {
+		sql.Register("scripted", scriptedDriver{})
+	})
+	scriptedDbs.Store(t.Name(), sd)
+	db, err := sql.Open("scripted", t.Name())
+	if err != nil {
+		t.Fatalf("opening scripted database (%s)", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	return &postgresIdempotencyStore{db: db, table: "idempotency"}
+}
+
+// the driver finds the script by name
+type scriptedDriver struct{}
+
+func (scriptedDriver) Open(name string) (driver.Conn, error) {
+	sd, _ := scriptedDbs.Load(name)
+	return &scriptedConn{db: sd.(*scriptedDb)}, nil
+}
+
+func testClaim() IdempotencyRecord {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	return IdempotencyRecord{EventId: "ev-1", Lambda: "libra-test", Outcome: IdempotencyInProgress, Updated: now, Expires: now.Add(IdempotencyClaimExpiry)}
+}
+
+func TestPostgresClaim(t *testing.T) {
+	sd := &scriptedDb{inserted: []int64{1}}
+	existing, err := scriptedStore(t, sd).Claim(context.Background(), testClaim())
+	if err != nil || existing != nil {
+		t.Errorf("expected the claim, got %+v %v", existing, err)
+	}
+	if sd.selects != 0 {
+		t.Errorf("expected no select, got %d", sd.selects)
+	}
+}
+
+func TestPostgresClaimExisting(t *testing.T) {
+	updated := time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC)
+	sd := &scriptedDb{inserted: []int64{0}, selected: [][]driver.Value{{IdempotencyProcessed, updated, updated.Add(IdempotencyRetention)}}}
+
+	existing, err := scriptedStore(t, sd).Claim(context.Background(), testClaim())
+	if err != nil || existing == nil {
+		t.Fatalf("expected the existing record, got %v", err)
+	}
+	if existing.Outcome != IdempotencyProcessed || existing.Updated.Equal(updated) == false || existing.EventId != "ev-1" {
+		t.Errorf("unexpected record %+v", existing)
+	}
+}
+
+func TestPostgresClaimReleasedMeanwhile(t *testing.T) {
+	// the conflicting record is released between the insert and the select
+	sd := &scriptedDb{inserted: []int64{0, 1}, selected: [][]driver.Value{nil}}
+
+	existing, err := scriptedStore(t, sd).Claim(context.Background(), testClaim())
+	if err != nil || existing != nil {
+		t.Errorf("expected the claim on the second attempt, got %+v %v", existing, err)
+	}
+	if sd.inserts != 2 {
+		t.Errorf("expected two inserts, got %d", sd.inserts)
+	}
+
+	// and gives up if it keeps happening
+	sd = &scriptedDb{inserted: []int64{0}, selected: [][]driver.Value{nil}}
+	_, err = scriptedStore(t, sd).Claim(context.Background(), testClaim())
+	if err == nil || strings.Contains(err.Error(), "ev-1") == false || sd.inserts != postgresClaimAttempts {
+		t.Errorf("expected a failure after %d attempts, got %d %v", postgresClaimAttempts, sd.inserts, err)
+	}
+}
+
+//
+// end of file
+//
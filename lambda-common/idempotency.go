@@ -0,0 +1,164 @@
+//
+// idempotency ledger, records which events each lambda has handled so that redelivered
+// events are skipped rather than processed twice
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ledger outcomes
+const IdempotencyInProgress = "in-progress"
+const IdempotencyProcessed = "processed"
+
+// how long a claim protects an event that is being processed, after this it is assumed the
+// invocation died and the event can be claimed again. Matches the maximum lambda duration
+var IdempotencyClaimExpiry = 15 * time.Minute
+
+// how long a processed event is remembered, comfortably longer than any redelivery
+var IdempotencyRetention = 30 * 24 * time.Hour
+
+// IdempotencyRecord is a ledger entry
+type IdempotencyRecord struct {
+	EventId string    // the event bridge event identifier
+	Lambda  string    // the lambda handling the event
+	Outcome string    // IdempotencyInProgress or the final outcome
+	Updated time.Time // when the record was written
+	Expires time.Time // when the record can be claimed again
+}
+
+// IdempotencyStore abstracts where the ledger is kept
+type IdempotencyStore interface {
+	// Claim writes the record unless an unexpired record for the same event and lambda
+	// exists, in which case the existing record is returned
+	Claim(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete replaces the record with the final outcome
+	Complete(ctx context.Context, record IdempotencyRecord) error
+	// Release removes the record so that the event can be processed again
+	Release(ctx context.Context, eventId string, lambda string) error
+}
+
+// the ledger configuration, IDEMPOTENCY_STORE selects the store and leaving it
+// empty disables the ledger
+type idempotencyConfig struct {
+	Store string `env:"IDEMPOTENCY_STORE"`                       // dynamodb, postgres or empty
+	Table string `env:"IDEMPOTENCY_TABLE" default:"idempotency"` // table name
+}
+
+// NewIdempotencyStore creates the configured ledger store
+func NewIdempotencyStore() (IdempotencyStore, error) {
+	var cfg idempotencyConfig
+	err := LoadConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Store {
+	case "":
+		return nil, ErrNoIdempotencyStore
+	case "dynamodb":
+		return newDynamoIdempotencyStore(cfg.Table)
+	case "postgres":
+		return newPostgresIdempotencyStore(cfg.Table)
+	}
+	return nil, Permanent(fmt.Errorf("unsupported idempotency store [%s]", cfg.Store))
+}
+
+// Idempotent wraps an event processor so that events this lambda has already processed are
+// skipped. The event is claimed before processing and the claim is released on failure so
+// that retries are processed as normal
+func Idempotent(deps Deps, process EventProcessor) EventProcessor {
+
+	// the store is created on first use and kept for the life of the lambda
+	var lock sync.Mutex
+	var ledger IdempotencyStore
+
+	getLedger := func() (IdempotencyStore, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if ledger == nil {
+			store, err := deps.Idempotency()
+			if err != nil {
+				return nil, err
+			}
+			ledger = store
+		}
+		return ledger, nil
+	}
+
+	lambda := lambdaName()
+
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+
+		store, err := getLedger()
+		if err != nil {
+			if errors.Is(err, ErrNoIdempotencyStore) == true {
+				return process(ctx, messageId, messageSrc, rawMsg)
+			}
+			LogError("creating idempotency store (%s)", err.Error())
+			return Transient(err)
+		}
+
+		now := deps.Now()
+		existing, err := store.Claim(ctx, IdempotencyRecord{
+			EventId: messageId,
+			Lambda:  lambda,
+			Outcome: IdempotencyInProgress,
+			Updated: now,
+			Expires: now.Add(IdempotencyClaimExpiry),
+		})
+		if err != nil {
+			LogError("claiming event %s (%s)", messageId, err.Error())
+			return Transient(err)
+		}
+
+		if existing != nil {
+			// another invocation is working on it, try again once it is done
+			if existing.Outcome == IdempotencyInProgress {
+				LogWarning("event %s is being processed elsewhere since %s", messageId, existing.Updated.Format(time.RFC3339))
+				return Transient(ErrEventInProgress)
+			}
+			LogInfo("event %s already %s at %s, ignoring", messageId, existing.Outcome, existing.Updated.Format(time.RFC3339))
+			IgnoreEvent(ctx)
+			return nil
+		}
+
+		// the ledger is updated even when processing ran out of time
+		ledgerCtx := context.WithoutCancel(ctx)
+
+		err = process(ctx, messageId, messageSrc, rawMsg)
+		if err != nil {
+			relErr := store.Release(ledgerCtx, messageId, lambda)
+			if relErr != nil {
+				// the claim expires eventually so retries are only delayed
+				LogError("releasing event %s (%s)", messageId, relErr.Error())
+			}
+			return err
+		}
+
+		now = deps.Now()
+		err = store.Complete(ledgerCtx, IdempotencyRecord{
+			EventId: messageId,
+			Lambda:  lambda,
+			Outcome: IdempotencyProcessed,
+			Updated: now,
+			Expires: now.Add(IdempotencyRetention),
+		})
+		if err != nil {
+			// processing succeeded so this is not worth a retry
+			LogError("completing event %s (%s)", messageId, err.Error())
+		}
+		return nil
+	}
+}
+
+//
+// end of file
+//
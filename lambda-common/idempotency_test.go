@@ -0,0 +1,153 @@
+//
+// tests for the idempotency ledger wrapper
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+// a processor counting its invocations and failing with the supplied error
+func countingProcessor(calls *int, err error) libracommon.EventProcessor {
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+		*calls++
+		return err
+	}
+}
+
+func TestIdempotentCompletes(t *testing.T) {
+	fd := fakes.New()
+	calls := 0
+	process := libracommon.Idempotent(fd.Deps(), countingProcessor(&calls, nil))
+
+	// the second delivery is skipped
+	for range 2 {
+		err := process(context.Background(), "ev-1", "libraetd", nil)
+		if err != nil {
+			t.Fatalf("expected success, got %s", err.Error())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected one invocation, got %d", calls)
+	}
+
+	records := fd.Idempotency.Records()
+	if len(records) != 1 || records[0].Outcome != libracommon.IdempotencyProcessed {
+		t.Fatalf("expected a processed record, got %+v", records)
+	}
+	if records[0].Expires.Equal(fd.Clock.Now().Add(libracommon.IdempotencyRetention)) == false {
+		t.Errorf("expected the record to be retained, expires %s", records[0].Expires)
+	}
+}
+
+func TestIdempotentReleasesOnFailure(t *testing.T) {
+	fd := fakes.New()
+	calls := 0
+	failure := libracommon.Transient(errors.New("service down"))
+	process := libracommon.Idempotent(fd.Deps(), countingProcessor(&calls, failure))
+
+	// the failure is returned unchanged and the retry is processed again
+	for range 2 {
+		err := process(context.Background(), "ev-1", "libraetd", nil)
+		if errors.Is(err, failure) == false {
+			t.Errorf("expected the processing error, got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected two invocations, got %d", calls)
+	}
+	if len(fd.Idempotency.Records()) != 0 {
+		t.Errorf("expected the claim to be released, got %+v", fd.Idempotency.Records())
+	}
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	fd := fakes.New()
+
+	// the event is redelivered while the first delivery is still being processed
+	var redelivered error
+	var process libracommon.EventProcessor
+	calls := 0
+	process = libracommon.Idempotent(fd.Deps(), func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+		calls++
+		if calls == 1 {
+			redelivered = process(ctx, messageId, messageSrc, rawMsg)
+		}
+		return nil
+	})
+
+	err := process(context.Background(), "ev-1", "libraetd", nil)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if errors.Is(redelivered, libracommon.ErrEventInProgress) == false || libracommon.IsTransient(redelivered) == false {
+		t.Errorf("expected a transient in progress error, got %v", redelivered)
+	}
+	if calls != 1 {
+		t.Errorf("expected one invocation, got %d", calls)
+	}
+}
+
+func TestIdempotentTakesOverExpiredClaim(t *testing.T) {
+	fd := fakes.New()
+	calls := 0
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "libra-test")
+
+	// an invocation that died holding the claim
+	now := fd.Clock.Now()
+	_, _ = fd.Idempotency.Claim(context.Background(), libracommon.IdempotencyRecord{
+		EventId: "ev-1",
+		Lambda:  "libra-test",
+		Outcome: libracommon.IdempotencyInProgress,
+		Updated: now,
+		Expires: now.Add(libracommon.IdempotencyClaimExpiry),
+	})
+	process := libracommon.Idempotent(fd.Deps(), countingProcessor(&calls, nil))
+
+	err := process(context.Background(), "ev-1", "libraetd", nil)
+	if errors.Is(err, libracommon.ErrEventInProgress) == false {
+		t.Fatalf("expected the claim to be respected, got %v", err)
+	}
+
+	// until it expires
+	fd.Clock.Advance(libracommon.IdempotencyClaimExpiry + 1)
+	err = process(context.Background(), "ev-1", "libraetd", nil)
+	if err != nil || calls != 1 {
+		t.Fatalf("expected the claim to be taken over, got %d invocations %v", calls, err)
+	}
+	records := fd.Idempotency.Records()
+	if len(records) != 1 || records[0].Outcome != libracommon.IdempotencyProcessed {
+		t.Errorf("expected a processed record, got %+v", records)
+	}
+}
+
+func TestIdempotentLedgerErrors(t *testing.T) {
+	calls := 0
+
+	// without a ledger the event is simply processed
+	deps := fakes.New().Deps()
+	deps.Idempotency = func() (libracommon.IdempotencyStore, error) { return nil, libracommon.ErrNoIdempotencyStore }
+	process := libracommon.Idempotent(deps, countingProcessor(&calls, nil))
+	if err := process(context.Background(), "ev-1", "libraetd", nil); err != nil || calls != 1 {
+		t.Errorf("expected the event to be processed, got %d invocations %v", calls, err)
+	}
+
+	// a ledger failure is worth a retry
+	fd := fakes.New()
+	fd.Idempotency.Err = errors.New("table unavailable")
+	process = libracommon.Idempotent(fd.Deps(), countingProcessor(&calls, nil))
+	if err := process(context.Background(), "ev-1", "libraetd", nil); libracommon.IsTransient(err) == false || calls != 1 {
+		t.Errorf("expected a transient failure without processing, got %d invocations %v", calls, err)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,53 @@
+//
+// API gateway lambda entrypoint
+//
+
+package libracommon
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestProcessor processes a single API gateway request
+type RequestProcessor func(ctx context.Context, messageId string, messageSrc string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// StartApiGatewayLambda starts the lambda runtime, each API gateway request is processed
+func StartApiGatewayLambda(process RequestProcessor) {
+
+	initTracing()
+
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx, cancel := withDeadlineMargin(ctx)
+		defer cancel()
+
+		SetLogEvent(request.RequestContext.RequestID, nil)
+		defer ClearLogEvent()
+
+		// continue the trace of the caller, if there is one
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(request.Headers))
+		ctx, span := tracer.Start(ctx, request.HTTPMethod+" "+request.Resource,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRoute(request.Resource), attribute.String(string(semconv.HTTPRequestMethodKey), request.HTTPMethod)))
+		setLogTrace(span.SpanContext())
+
+		response, err := process(ctx, request.RequestContext.RequestID, "", request)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+		endSpan(span, err)
+		flushTracing(ctx)
+		return response, err
+	}
+
+	lambda.Start(handler)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,32 @@
+//
+// eventbridge lambda entrypoint
+//
+
+package libracommon
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// StartEventBridgeLambda starts the lambda runtime, each event bridge event is processed
+func StartEventBridgeLambda(process EventProcessor) {
+
+	process = withLogEvent(withTracing(withMetrics(process)))
+
+	handler := func(ctx context.Context, event events.EventBridgeEvent) error {
+		ctx, cancel := withDeadlineMargin(ctx)
+		defer cancel()
+
+		// process the message
+		return process(ctx, event.ID, event.Source, event.Detail)
+	}
+
+	lambda.Start(handler)
+}
+
+//
+// end of file
+//
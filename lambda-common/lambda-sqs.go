@@ -0,0 +1,87 @@
+//
+// SQS lambda entrypoint
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// StartSqsLambda starts the lambda runtime, each SQS message is expected to contain an
+// event bridge event which is processed. Failures are reported per message so the event
+// source mapping must have ReportBatchItemFailures enabled
+func StartSqsLambda(process EventProcessor) {
+
+	process = withLogEvent(withTracing(withMetrics(process)))
+
+	handler := func(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+		ctx, cancel := withDeadlineMargin(ctx)
+		defer cancel()
+
+		deadLetters, err := DefaultDeps().DeadLetters()
+		if err != nil {
+			LogError("creating dead letter queue (%s)", err.Error())
+		}
+		return handleSqsEvent(ctx, process, deadLetters, sqsEvent), nil
+	}
+
+	lambda.Start(handler)
+}
+
+func handleSqsEvent(ctx context.Context, process EventProcessor, deadLetters DeadLetterQueue, sqsEvent events.SQSEvent) events.SQSEventResponse {
+
+	response := events.SQSEventResponse{BatchItemFailures: make([]events.SQSBatchItemFailure, 0)}
+
+	// loop through possible messages
+	for _, message := range sqsEvent.Records {
+		// out of time, leave the remaining messages for a later invocation
+		if ctx.Err() != nil {
+			LogWarning("deadline approaching, returning message %s to the queue", message.MessageId)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			continue
+		}
+
+		// convert to an eventbus event
+		var mbEvent events.EventBridgeEvent
+		err := json.Unmarshal([]byte(message.Body), &mbEvent)
+		if err != nil {
+			// a poison message will never succeed
+			LogError("unmarshaling event bridge event from message %s (%s)", message.MessageId, err.Error())
+			err = Permanent(err)
+		} else {
+			err = process(ctx, mbEvent.ID, mbEvent.Source, mbEvent.Detail)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		// permanent failures are acknowledged and sent to the dead letter queue
+		if IsPermanent(err) == true && sendDeadLetter(ctx, deadLetters, message, err) == nil {
+			LogError("processing message %s failed permanently (%s), acknowledged", message.MessageId, err.Error())
+			continue
+		}
+
+		// otherwise, only this message is re-queued
+		LogError("processing message %s failed (%s), will retry", message.MessageId, err.Error())
+		response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+	}
+
+	return response
+}
+
+func sendDeadLetter(ctx context.Context, deadLetters DeadLetterQueue, message events.SQSMessage, cause error) error {
+	if deadLetters == nil {
+		return ErrNoDeadLetterQueue
+	}
+	return deadLetters.Send(ctx, DeadLetterRecord{MessageId: message.MessageId, Body: message.Body, Error: cause.Error()})
+}
+
+//
+// end of file
+//
@@ -0,0 +1,154 @@
+//
+// tests for the handling of an SQS batch
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// a dead letter queue recording what it is sent
+type testDeadLetters struct {
+	records []DeadLetterRecord
+	err     error
+}
+
+func (dl *testDeadLetters) Send(ctx context.Context, record DeadLetterRecord) error {
+	if dl.err != nil {
+		return dl.err
+	}
+	dl.records = append(dl.records, record)
+	return nil
+}
+
+// a message carrying an event with the supplied identifier
+func testMessage(messageId string, eventId string) events.SQSMessage {
+	buf, _ := json.Marshal(events.EventBridgeEvent{ID: eventId, Source: "libraetd", Detail: json.RawMessage(`{}`)})
+	return events.SQSMessage{MessageId: messageId, Body: string(buf)}
+}
+
+// a processor failing with the error registered for each event
+func testProcessor(failures map[string]error) (EventProcessor, *[]string) {
+	processed := make([]string, 0)
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+		processed = append(processed, messageId)
+		return failures[messageId]
+	}, &processed
+}
+
+func failedIds(response events.SQSEventResponse) []string {
+	ids := make([]string, 0)
+	for _, failure := range response.BatchItemFailures {
+		ids = append(ids, failure.ItemIdentifier)
+	}
+	return ids
+}
+
+func sameIds(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ix := range a {
+		if a[ix] != b[ix] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSqsBatchFailures(t *testing.T) {
+	process, processed := testProcessor(map[string]error{
+		"ev-permanent": Permanent(errors.New("bad data")),
+		"ev-transient": Transient(errors.New("service down")),
+		"ev-unknown":   errors.New("unclassified"),
+		"ev-throttled": Throttled(errors.New("slow down")),
+	})
+	deadLetters := &testDeadLetters{}
+	sqsEvent := events.SQSEvent{Records: []events.SQSMessage{
+		testMessage("m1", "ev-ok"),
+		testMessage("m2", "ev-permanent"),
+		testMessage("m3", "ev-transient"),
+		testMessage("m4", "ev-unknown"),
+		testMessage("m5", "ev-throttled"),
+		{MessageId: "m6", Body: "not json"},
+	}}
+
+	response := handleSqsEvent(context.Background(), process, deadLetters, sqsEvent)
+
+	// only the retryable messages are returned to the queue
+	if ids := failedIds(response); sameIds(ids, []string{"m3", "m4", "m5"}) == false {
+		t.Errorf("expected m3, m4 and m5 to be retried, got %v", ids)
+	}
+	// the permanent failure and the poison message are dead lettered
+	if len(deadLetters.records) != 2 || deadLetters.records[0].MessageId != "m2" || deadLetters.records[1].MessageId != "m6" {
+		t.Fatalf("expected m2 and m6 to be dead lettered, got %+v", deadLetters.records)
+	}
+	if deadLetters.records[0].Error != "bad data" || deadLetters.records[1].Body != "not json" {
+		t.Errorf("expected the cause and the original body, got %+v", deadLetters.records)
+	}
+	// and the poison message was never processed
+	if len(*processed) != 5 {
+		t.Errorf("expected 5 events processed, got %v", *processed)
+	}
+}
+
+func TestSqsDeadLetterUnavailable(t *testing.T) {
+	process, _ := testProcessor(map[string]error{"ev-permanent": Permanent(errors.New("bad data"))})
+	sqsEvent := events.SQSEvent{Records: []events.SQSMessage{testMessage("m1", "ev-permanent"), testMessage("m2", "ev-ok")}}
+
+	// a permanent failure is retried rather than lost when it cannot be dead lettered
+	for name, deadLetters := range map[string]DeadLetterQueue{
+		"no queue":    nil,
+		"send failed": &testDeadLetters{err: errors.New("queue down")},
+	} {
+		response := handleSqsEvent(context.Background(), process, deadLetters, sqsEvent)
+		if ids := failedIds(response); sameIds(ids, []string{"m1"}) == false {
+			t.Errorf("%s: expected m1 to be retried, got %v", name, ids)
+		}
+	}
+}
+
+func TestSqsDeadlineReturnsRemaining(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the deadline arrives while the second message is processed
+	processed := make([]string, 0)
+	process := func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+		processed = append(processed, messageId)
+		if messageId == "ev-2" {
+			cancel()
+			return Transient(ctx.Err())
+		}
+		return nil
+	}
+	sqsEvent := events.SQSEvent{Records: []events.SQSMessage{testMessage("m1", "ev-1"), testMessage("m2", "ev-2"), testMessage("m3", "ev-3"), testMessage("m4", "ev-4")}}
+
+	response := handleSqsEvent(ctx, process, &testDeadLetters{}, sqsEvent)
+	if ids := failedIds(response); sameIds(ids, []string{"m2", "m3", "m4"}) == false {
+		t.Errorf("expected m2, m3 and m4 to be returned, got %v", ids)
+	}
+	if sameIds(processed, []string{"ev-1", "ev-2"}) == false {
+		t.Errorf("expected nothing processed after the deadline, got %v", processed)
+	}
+}
+
+func TestSqsEmptyBatch(t *testing.T) {
+	process, _ := testProcessor(nil)
+	response := handleSqsEvent(context.Background(), process, nil, events.SQSEvent{})
+
+	// nothing to process, nothing to retry
+	if len(response.BatchItemFailures) != 0 {
+		t.Errorf("expected no failures, got %v", response.BatchItemFailures)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,154 @@
+//
+// structured logging, one JSON object per line. Each line is tagged with the lambda name
+// and the details of the event currently being processed
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the minimum level logged, set from LOG_LEVEL (debug, info, warn or error)
+var logLevel = newLogLevel(os.Getenv("LOG_LEVEL"))
+
+var logger = slog.New(&eventLogHandler{
+	Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}),
+})
+
+// the details of the event being processed
+var logEventLock sync.Mutex
+var logEventAttrs []slog.Attr
+
+func LogDebug(format string, args ...any) {
+	logMessage(slog.LevelDebug, format, args...)
+}
+
+func LogInfo(format string, args ...any) {
+	logMessage(slog.LevelInfo, format, args...)
+}
+
+func LogWarning(format string, args ...any) {
+	logMessage(slog.LevelWarn, format, args...)
+}
+
+func LogError(format string, args ...any) {
+	logMessage(slog.LevelError, format, args...)
+}
+
+// SetLogLevel sets the minimum level logged
+func SetLogLevel(level string) error {
+	return logLevel.UnmarshalText([]byte(level))
+}
+
+// SetLogEvent attaches the message and event details to subsequent log lines, the event may be nil
+func SetLogEvent(messageId string, ev *uvalibrabus.UvaBusEvent) {
+	attrs := []slog.Attr{slog.String("message_id", messageId)}
+	if ev != nil {
+		attrs = append(attrs,
+			slog.String("event", ev.EventName),
+			slog.String("namespace", ev.Namespace),
+			slog.String("id", ev.Identifier))
+	}
+
+	logEventLock.Lock()
+	defer logEventLock.Unlock()
+	logEventAttrs = attrs
+}
+
+// ClearLogEvent removes the event details once processing is complete
+func ClearLogEvent() {
+	logEventLock.Lock()
+	defer logEventLock.Unlock()
+	logEventAttrs = nil
+}
+
+// add the trace identifier to the current event details
+func setLogTrace(sc trace.SpanContext) {
+	if sc.HasTraceID() == false {
+		return
+	}
+	logEventLock.Lock()
+	defer logEventLock.Unlock()
+	logEventAttrs = append(logEventAttrs, slog.String("trace_id", sc.TraceID().String()))
+}
+
+// NewStdLogger returns a standard library logger for packages that require one
+func NewStdLogger() *log.Logger {
+	return slog.NewLogLogger(logger.Handler(), slog.LevelDebug)
+}
+
+// wrap an event processor so that everything logged while processing carries the event details
+func withLogEvent(process EventProcessor) EventProcessor {
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+		// errors are reported by the processor itself
+		ev, _ := uvalibrabus.MakeBusEvent(rawMsg)
+		SetLogEvent(messageId, ev)
+		defer ClearLogEvent()
+		return process(ctx, messageId, messageSrc, rawMsg)
+	}
+}
+
+func logMessage(level slog.Level, format string, args ...any) {
+	if logger.Enabled(context.Background(), level) == false {
+		return
+	}
+	logger.Log(context.Background(), level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// eventLogHandler adds the lambda name and the current event details to each record, the
+// name is looked up each time as the local runner changes it for every lambda it dispatches to
+type eventLogHandler struct {
+	slog.Handler
+}
+
+func (eh *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.String("lambda", lambdaName()))
+	logEventLock.Lock()
+	r.AddAttrs(logEventAttrs...)
+	logEventLock.Unlock()
+	return eh.Handler.Handle(ctx, r)
+}
+
+func (eh *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{Handler: eh.Handler.WithAttrs(attrs)}
+}
+
+func (eh *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{Handler: eh.Handler.WithGroup(name)}
+}
+
+func newLogLevel(level string) *slog.LevelVar {
+	lv := new(slog.LevelVar)
+	if level != "" {
+		err := lv.UnmarshalText([]byte(level))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid LOG_LEVEL [%s], using INFO\n", level)
+		}
+	}
+	return lv
+}
+
+// the deployed function name, or the executable name when run from the commandline
+func lambdaName() string {
+	name, set := os.LookupEnv("AWS_LAMBDA_FUNCTION_NAME")
+	if set == true {
+		return name
+	}
+	return filepath.Base(os.Args[0])
+}
+
+//
+// end of file
+//
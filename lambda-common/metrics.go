@@ -0,0 +1,178 @@
+//
+// metrics, written as CloudWatch embedded metric format (EMF) lines so that they are
+// extracted from the lambda logs. Every metric carries a Lambda dimension
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// metric units
+const MetricUnitCount = "Count"
+const MetricUnitMilliseconds = "Milliseconds"
+
+// Metric is a single measurement
+type Metric struct {
+	Name       string            // metric name
+	Unit       string            // one of the MetricUnit values
+	Value      float64           // the measurement
+	Dimensions map[string]string // additional dimensions, may be nil
+}
+
+// MetricsSink abstracts where metrics are recorded
+type MetricsSink interface {
+	Record(metric Metric)
+}
+
+// the sink used by the metric helpers, replaced using SetMetricsSink
+var metricsLock sync.Mutex
+var metricsSink MetricsSink = NewEmfMetricsSink(os.Stdout, metricsNamespace())
+
+// SetMetricsSink replaces the sink metrics are recorded to
+func SetMetricsSink(sink MetricsSink) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metricsSink = sink
+}
+
+// IncrementCounter adds one to the named counter
+func IncrementCounter(name string, dimensions map[string]string) {
+	recordMetric(Metric{Name: name, Unit: MetricUnitCount, Value: 1, Dimensions: dimensions})
+}
+
+// RecordTiming records a duration in milliseconds
+func RecordTiming(name string, duration time.Duration, dimensions map[string]string) {
+	recordMetric(Metric{Name: name, Unit: MetricUnitMilliseconds, Value: float64(duration.Milliseconds()), Dimensions: dimensions})
+}
+
+func recordMetric(metric Metric) {
+	metricsLock.Lock()
+	sink := metricsSink
+	metricsLock.Unlock()
+	if sink != nil {
+		sink.Record(metric)
+	}
+}
+
+// the metric namespace, set from METRICS_NAMESPACE
+func metricsNamespace() string {
+	namespace, set := os.LookupEnv("METRICS_NAMESPACE")
+	if set == true && len(namespace) != 0 {
+		return namespace
+	}
+	return "LibraLambda"
+}
+
+// our EMF MetricsSink implementation
+type emfMetricsSink struct {
+	mu        sync.Mutex
+	out       io.Writer
+	namespace string
+}
+
+func NewEmfMetricsSink(out io.Writer, namespace string) MetricsSink {
+	return &emfMetricsSink{out: out, namespace: namespace}
+}
+
+func (es *emfMetricsSink) Record(metric Metric) {
+
+	dimensions := map[string]string{"Lambda": lambdaName()}
+	maps.Copy(dimensions, metric.Dimensions)
+
+	type emfMetric struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit"`
+	}
+	type emfDirective struct {
+		Namespace  string      `json:"Namespace"`
+		Dimensions [][]string  `json:"Dimensions"`
+		Metrics    []emfMetric `json:"Metrics"`
+	}
+	type emfMetadata struct {
+		Timestamp         int64          `json:"Timestamp"`
+		CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+	}
+
+	// the dimension and metric values are top level members
+	line := make(map[string]any)
+	for k, v := range dimensions {
+		line[k] = v
+	}
+	line[metric.Name] = metric.Value
+	line["_aws"] = emfMetadata{
+		Timestamp: time.Now().UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  es.namespace,
+			Dimensions: [][]string{slices.Sorted(maps.Keys(dimensions))},
+			Metrics:    []emfMetric{{Name: metric.Name, Unit: metric.Unit}},
+		}},
+	}
+
+	buf, err := json.Marshal(line)
+	if err != nil {
+		LogWarning("encoding metric %s (%s)", metric.Name, err.Error())
+		return
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	_, _ = es.out.Write(append(buf, '\n'))
+}
+
+// event outcomes are recorded per event name
+type eventOutcomeKey struct{}
+
+type eventOutcome struct {
+	ignored bool
+}
+
+// IgnoreEvent notes that the event being processed was of no interest, it is then counted
+// as ignored rather than processed
+func IgnoreEvent(ctx context.Context) {
+	if outcome, ok := ctx.Value(eventOutcomeKey{}).(*eventOutcome); ok == true {
+		outcome.ignored = true
+	}
+}
+
+// wrap an event processor so that the outcome and duration of each event is recorded
+func withMetrics(process EventProcessor) EventProcessor {
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+
+		dimensions := map[string]string{"EventName": "unknown"}
+		// errors are reported by the processor itself
+		ev, _ := uvalibrabus.MakeBusEvent(rawMsg)
+		if ev != nil {
+			dimensions["EventName"] = ev.EventName
+		}
+
+		outcome := &eventOutcome{}
+		start := time.Now()
+		err := process(context.WithValue(ctx, eventOutcomeKey{}, outcome), messageId, messageSrc, rawMsg)
+		RecordTiming("EventDuration", time.Since(start), dimensions)
+
+		switch {
+		case err != nil:
+			IncrementCounter("EventsFailed", dimensions)
+		case outcome.ignored == true:
+			IncrementCounter("EventsIgnored", dimensions)
+		default:
+			IncrementCounter("EventsProcessed", dimensions)
+		}
+		return err
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,78 @@
+//
+// tests for the metric helpers
+//
+
+package libracommon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func TestEmfMetricsSink(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "libra-test")
+	var out bytes.Buffer
+	sink := libracommon.NewEmfMetricsSink(&out, "TestNamespace")
+
+	sink.Record(libracommon.Metric{Name: "HttpLatency", Unit: libracommon.MetricUnitMilliseconds, Value: 42, Dimensions: map[string]string{"Host": "example.edu"}})
+
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("expected one line, got %q", out.String())
+	}
+	var line struct {
+		Lambda      string  `json:"Lambda"`
+		Host        string  `json:"Host"`
+		HttpLatency float64 `json:"HttpLatency"`
+		Aws         struct {
+			Timestamp         int64 `json:"Timestamp"`
+			CloudWatchMetrics []struct {
+				Namespace  string              `json:"Namespace"`
+				Dimensions [][]string          `json:"Dimensions"`
+				Metrics    []map[string]string `json:"Metrics"`
+			} `json:"CloudWatchMetrics"`
+		} `json:"_aws"`
+	}
+	err := json.Unmarshal(out.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("expected a JSON line (%s)", err.Error())
+	}
+
+	if line.Lambda != "libra-test" || line.Host != "example.edu" || line.HttpLatency != 42 || line.Aws.Timestamp == 0 {
+		t.Errorf("unexpected metric values %+v", line)
+	}
+	directive := line.Aws.CloudWatchMetrics[0]
+	if directive.Namespace != "TestNamespace" || strings.Join(directive.Dimensions[0], ",") != "Host,Lambda" {
+		t.Errorf("unexpected directive %+v", directive)
+	}
+	if directive.Metrics[0]["Name"] != "HttpLatency" || directive.Metrics[0]["Unit"] != "Milliseconds" {
+		t.Errorf("unexpected metric definition %+v", directive.Metrics)
+	}
+}
+
+func TestMetricHelpers(t *testing.T) {
+	sink := fakes.NewMetricsSink()
+	libracommon.SetMetricsSink(sink)
+
+	libracommon.IncrementCounter("Things", nil)
+	libracommon.IncrementCounter("Things", map[string]string{"Kind": "other"})
+	libracommon.RecordTiming("Elapsed", 1500*time.Millisecond, nil)
+
+	if sink.Sum("Things") != 2 || sink.Sum("Elapsed") != 1500 {
+		t.Errorf("unexpected metrics %+v", sink.Metrics())
+	}
+	for _, m := range sink.Metrics() {
+		if m.Name == "Elapsed" && m.Unit != libracommon.MetricUnitMilliseconds {
+			t.Errorf("expected a timing in milliseconds, got %+v", m)
+		}
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,4 @@
+BEGIN;
+-- drop the table if it exists
+DROP TABLE IF EXISTS idempotency;
+COMMIT;
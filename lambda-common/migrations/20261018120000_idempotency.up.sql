@@ -0,0 +1,24 @@
+--
+-- the idempotency ledger shared by the side-effecting lambdas
+--
+
+BEGIN;
+
+CREATE TABLE idempotency (
+   event_id   VARCHAR( 64 ) NOT NULL,
+   lambda     VARCHAR( 64 ) NOT NULL,
+   outcome    VARCHAR( 32 ) NOT NULL DEFAULT '',
+   updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+   expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+   PRIMARY KEY (event_id, lambda)
+);
+
+-- for purging expired entries
+CREATE INDEX idempotency_expires_idx ON idempotency(expires_at);
+
+COMMIT;
+
+--
+-- end of file
+--
@@ -0,0 +1,89 @@
+//
+// ORCID lookup cache, concurrent lookups of the same user share a single request
+//
+
+package libracommon
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OrcidLookup looks up the ORCID of a user by computing id
+type OrcidLookup func(ctx context.Context, cid string) (string, error)
+
+// OrcidCache remembers successful lookups, failures are not cached
+type OrcidCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration // zero keeps entries for the life of the cache
+	now     func() time.Time
+	entries map[string]*orcidEntry
+}
+
+type orcidEntry struct {
+	done    chan struct{} // closed once the lookup completes
+	orcid   string
+	err     error
+	expires time.Time
+}
+
+// NewOrcidCache creates a cache whose entries expire after ttl, zero means never
+func NewOrcidCache(ttl time.Duration, now func() time.Time) *OrcidCache {
+	return &OrcidCache{ttl: ttl, now: now, entries: make(map[string]*orcidEntry)}
+}
+
+// Get returns the ORCID of the user, looking it up if it is not cached
+func (oc *OrcidCache) Get(ctx context.Context, cid string, lookup OrcidLookup) (string, error) {
+
+	oc.mu.Lock()
+	entry, found := oc.entries[cid]
+	if found == true && oc.expired(entry) == true {
+		delete(oc.entries, cid)
+		found = false
+	}
+
+	// someone else is looking it up or already has
+	if found == true {
+		oc.mu.Unlock()
+		IncrementCounter("OrcidCacheHits", nil)
+		select {
+		case <-entry.done:
+			return entry.orcid, entry.err
+		case <-ctx.Done():
+			return "", Transient(ctx.Err())
+		}
+	}
+
+	entry = &orcidEntry{done: make(chan struct{})}
+	oc.entries[cid] = entry
+	oc.mu.Unlock()
+
+	IncrementCounter("OrcidCacheMisses", nil)
+	entry.orcid, entry.err = lookup(ctx, cid)
+
+	oc.mu.Lock()
+	if entry.err != nil {
+		delete(oc.entries, cid)
+	} else if oc.ttl != 0 {
+		entry.expires = oc.now().Add(oc.ttl)
+	}
+	oc.mu.Unlock()
+	close(entry.done)
+
+	return entry.orcid, entry.err
+}
+
+// completed entries expire, lookups in progress never do. Called with the lock held
+func (oc *OrcidCache) expired(entry *orcidEntry) bool {
+	select {
+	case <-entry.done:
+		return entry.expires.IsZero() == false && oc.now().After(entry.expires) == true
+	default:
+		return false
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,142 @@
+//
+// tests for the ORCID lookup cache
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+// a lookup that counts its calls
+func countingLookup(calls *atomic.Int32, orcid string, err error) libracommon.OrcidLookup {
+	return func(ctx context.Context, cid string) (string, error) {
+		calls.Add(1)
+		return orcid, err
+	}
+}
+
+func TestOrcidCacheRemembers(t *testing.T) {
+	clock := fakes.NewClock(testNow())
+	cache := libracommon.NewOrcidCache(time.Hour, clock.Now)
+	var calls atomic.Int32
+	lookup := countingLookup(&calls, "0000-0001", nil)
+
+	for range 3 {
+		orcid, err := cache.Get(context.Background(), "ab1c", lookup)
+		if err != nil || orcid != "0000-0001" {
+			t.Fatalf("expected 0000-0001, got [%s] %v", orcid, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one lookup, got %d", calls.Load())
+	}
+
+	// and forgets once the entry expires
+	clock.Advance(time.Hour + time.Second)
+	_, _ = cache.Get(context.Background(), "ab1c", lookup)
+	if calls.Load() != 2 {
+		t.Errorf("expected the expired entry to be looked up again, got %d lookups", calls.Load())
+	}
+}
+
+func TestOrcidCacheWithoutTTL(t *testing.T) {
+	clock := fakes.NewClock(testNow())
+	cache := libracommon.NewOrcidCache(0, clock.Now)
+	var calls atomic.Int32
+	lookup := countingLookup(&calls, "0000-0001", nil)
+
+	_, _ = cache.Get(context.Background(), "ab1c", lookup)
+	clock.Advance(365 * 24 * time.Hour)
+	_, _ = cache.Get(context.Background(), "ab1c", lookup)
+	if calls.Load() != 1 {
+		t.Errorf("expected entries to be kept, got %d lookups", calls.Load())
+	}
+}
+
+func TestOrcidCacheForgetsFailures(t *testing.T) {
+	cache := libracommon.NewOrcidCache(time.Hour, testNow)
+	var calls atomic.Int32
+	lookup := countingLookup(&calls, "", fmt.Errorf("service unavailable"))
+
+	for range 2 {
+		_, err := cache.Get(context.Background(), "ab1c", lookup)
+		if err == nil {
+			t.Fatalf("expected the lookup failure")
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected failures to be looked up again, got %d lookups", calls.Load())
+	}
+}
+
+func TestOrcidCacheSharesLookups(t *testing.T) {
+	cache := libracommon.NewOrcidCache(time.Hour, testNow)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	lookup := func(ctx context.Context, cid string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "0000-0001", nil
+	}
+
+	// the first caller looks it up and the others wait for it
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	wg.Go(func() {
+		results[0], _ = cache.Get(context.Background(), "ab1c", lookup)
+	})
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for ix := 1; ix < len(results); ix++ {
+		wg.Go(func() {
+			results[ix], _ = cache.Get(context.Background(), "ab1c", lookup)
+		})
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected one lookup, got %d", calls.Load())
+	}
+	for ix, orcid := range results {
+		if orcid != "0000-0001" {
+			t.Errorf("caller %d expected 0000-0001, got [%s]", ix, orcid)
+		}
+	}
+}
+
+func TestOrcidCacheWaitStopsWithContext(t *testing.T) {
+	cache := libracommon.NewOrcidCache(time.Hour, testNow)
+	release := make(chan struct{})
+	defer close(release)
+	inLookup := make(chan struct{})
+	go func() {
+		_, _ = cache.Get(context.Background(), "ab1c", func(ctx context.Context, cid string) (string, error) {
+			close(inLookup)
+			<-release
+			return "0000-0001", nil
+		})
+	}()
+	<-inLookup
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.Get(ctx, "ab1c", countingLookup(new(atomic.Int32), "", nil))
+	if err == nil || libracommon.IsPermanent(err) == true {
+		t.Errorf("expected a transient failure, got %v", err)
+	}
+}
+
+//
+// end of file
+//
@@ -2,11 +2,11 @@
 //
 //
 
-package main
+package libracommon
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 )
@@ -24,15 +24,15 @@ type OrcidDetails struct {
 	//URI   string `json:"uri,omitempty"`
 }
 
-func getOrcidDetails(url string, cid string, auth string, client *http.Client) (string, error) {
+func GetOrcidDetails(ctx context.Context, url string, cid string, auth string, client *http.Client) (string, error) {
 
 	// substitute values into url
 	url = strings.Replace(url, "{:id}", cid, 1)
 	url = strings.Replace(url, "{:auth}", auth, 1)
 
-	payload, err := httpGet(client, url)
+	payload, err := HttpGet(ctx, client, url)
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") == true {
+		if IsHttpNotFound(err) == true {
 			return "", nil
 		}
 		return "", err
@@ -41,13 +41,13 @@ func getOrcidDetails(url string, cid string, auth string, client *http.Client) (
 	resp := OrcidDetailsResponse{}
 	err = json.Unmarshal(payload, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of OrcidDetailsResponse (%s)\n", err.Error())
+		LogError("json unmarshal of OrcidDetailsResponse (%s)", err.Error())
 		return "", err
 	}
 
 	// if we have details, return them
 	if len(resp.Details) != 0 {
-		fmt.Printf("INFO: located ORCID [%s] for cid [%s]\n", resp.Details[0].Orcid, cid)
+		LogInfo("located ORCID [%s] for cid [%s]", resp.Details[0].Orcid, cid)
 		return resp.Details[0].Orcid, nil
 	}
 
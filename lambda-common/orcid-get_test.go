@@ -0,0 +1,43 @@
+//
+// tests for the ORCID service helper
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func TestGetOrcidDetails(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/orcid/ab1c", 200, `{"status":200,"results":[{"orcid":"0000-0001"},{"orcid":"0000-0002"}]}`)
+	svc.Respond("GET", "/orcid/none", 200, `{"status":200,"results":[]}`)
+	url := svc.URL + "/orcid/{:id}?auth={:auth}"
+
+	for cid, expected := range map[string]string{"ab1c": "0000-0001", "none": "", "unknown": ""} {
+		orcid, err := libracommon.GetOrcidDetails(context.Background(), url, cid, "a-token", svc.Client())
+		if err != nil || orcid != expected {
+			t.Errorf("%s: expected [%s], got [%s] %v", cid, expected, orcid, err)
+		}
+	}
+}
+
+func TestGetOrcidDetailsFailure(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/orcid/ab1c", 200, `{"results":"garbled"}`)
+
+	_, err := libracommon.GetOrcidDetails(context.Background(), svc.URL+"/orcid/{:id}", "ab1c", "", svc.Client())
+	if err == nil {
+		t.Errorf("expected a failure for an unreadable response")
+	}
+}
+
+//
+// end of file
+//
@@ -2,42 +2,65 @@
 // simple module to get and set parameter values in the ssm
 //
 
-package main
+package libracommon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
 
-func newParameterClient() (*ssm.Client, error) {
+// the parameter does not exist
+var ErrParameterNotFound = fmt.Errorf("parameter not found")
+
+// our ParameterStore implementation
+type ssmParameterStore struct {
+	client *ssm.Client
+}
+
+func NewParameterClient() (ParameterStore, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, err
 	}
-	return ssm.NewFromConfig(cfg), nil
+	return &ssmParameterStore{client: ssm.NewFromConfig(cfg)}, nil
+}
+
+func (ps *ssmParameterStore) GetParameter(ctx context.Context, name string) (string, error) {
+	return ps.getParameter(ctx, name, false)
+}
+
+// GetSecureParameter gets a parameter, decrypting it if it is a SecureString
+func (ps *ssmParameterStore) GetSecureParameter(ctx context.Context, name string) (string, error) {
+	return ps.getParameter(ctx, name, true)
 }
 
-func getParameter(client *ssm.Client, name string) (string, error) {
-	param, err := client.GetParameter(context.Background(),
+func (ps *ssmParameterStore) getParameter(ctx context.Context, name string, decrypt bool) (string, error) {
+	param, err := ps.client.GetParameter(ctx,
 		&ssm.GetParameterInput{
 			Name:           aws.String(name),
-			WithDecryption: aws.Bool(false),
+			WithDecryption: aws.Bool(decrypt),
 		})
 
 	if err != nil {
-		fmt.Printf("ERROR: getting parameter (%s)\n", err.Error())
+		var notFound *types.ParameterNotFound
+		if errors.As(err, &notFound) == true {
+			return "", fmt.Errorf("%w: [%s]", ErrParameterNotFound, name)
+		}
+		LogError("getting parameter (%s)", err.Error())
 		return "", err
 	}
 
 	return *param.Parameter.Value, nil
 }
 
-func setParameter(client *ssm.Client, name string, value string) error {
-	_, err := client.PutParameter(context.Background(),
+func (ps *ssmParameterStore) SetParameter(ctx context.Context, name string, value string) error {
+	_, err := ps.client.PutParameter(ctx,
 		&ssm.PutParameterInput{
 			Name:      aws.String(name),
 			Value:     aws.String(value),
@@ -45,7 +68,7 @@ func setParameter(client *ssm.Client, name string, value string) error {
 		})
 
 	if err != nil {
-		fmt.Printf("ERROR: setting parameter (%s)\n", err.Error())
+		LogError("setting parameter (%s)", err.Error())
 		return err
 	}
 
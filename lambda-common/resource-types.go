@@ -0,0 +1,151 @@
+//
+// resource types, the Datacite types of a work come from data/resourceTypes.json
+//
+
+package libracommon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed data/resourceTypes.json
+var resourceTypesJson []byte
+
+// the value is not a resource type available for the kind of work
+var ErrUnknownResourceType = fmt.Errorf("unknown resource type")
+
+// the degree does not identify an ETD resource type
+var ErrUnknownDegree = fmt.Errorf("unknown degree")
+
+// the resourceTypeGeneral for resource types without a category
+var defaultResourceTypeGeneral = "Text"
+
+// the Datacite resourceTypeGeneral vocabulary, https://datacite-metadata-schema.readthedocs.io
+var dataciteResourceTypesGeneral = []string{
+	"Audiovisual", "Award", "Book", "BookChapter", "Collection", "ComputationalNotebook",
+	"ConferencePaper", "ConferenceProceeding", "DataPaper", "Dataset", "Dissertation", "Event",
+	"Image", "Instrument", "InteractiveResource", "Journal", "JournalArticle", "Model",
+	"OutputManagementPlan", "PeerReview", "PhysicalObject", "Preprint", "Project", "Report",
+	"Service", "Software", "Sound", "Standard", "StudyRegistration", "Text", "Workflow", "Other",
+}
+
+// ResourceType such as book, article, etc
+type ResourceType struct {
+	Value    string      `json:"value"`
+	Label    string      `json:"label"`
+	Category string      `json:"category"` // the resourceTypeGeneral, Text when empty
+	Oa       bool        `json:"oa"`       // available for open access works
+	Etd      bool        `json:"etd"`      // available for ETD works
+	Degrees  DegreeMatch `json:"degrees"`  // the ETD degrees identifying the type
+}
+
+// DegreeMatch identifies the degrees of a resource type. A degree such as
+// "MA (Master of Arts)" matches on its abbreviation or on a keyword in its name
+type DegreeMatch struct {
+	Keywords      []string `json:"keywords"`      // lower case, matched anywhere in the degree
+	Abbreviations []string `json:"abbreviations"` // upper case without punctuation, matched against the leading word
+}
+
+// the table is parsed and validated once
+var loadResourceTypes = sync.OnceValues(func() ([]ResourceType, error) {
+	var types []ResourceType
+	err := json.Unmarshal(resourceTypesJson, &types)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resourceTypes.json (%w)", err)
+	}
+	err = validateResourceTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+})
+
+// ResourceTypes returns the resource type table
+func ResourceTypes() ([]ResourceType, error) {
+	return loadResourceTypes()
+}
+
+// IsResourceTypeGeneral reports whether the value is in the Datacite resourceTypeGeneral vocabulary
+func IsResourceTypeGeneral(value string) bool {
+	return slices.Contains(dataciteResourceTypesGeneral, value)
+}
+
+// DataciteTypes maps the value through the resource type table, types that are not in the
+// table or not available for the kind of work (open access or ETD) are rejected
+func DataciteTypes(value string, oa bool) (TypeData, error) {
+
+	types, err := ResourceTypes()
+	if err != nil {
+		return TypeData{}, err
+	}
+
+	kind := "ETD"
+	if oa == true {
+		kind = "open access"
+	}
+	idx := slices.IndexFunc(types, func(rt ResourceType) bool { return rt.Value == value })
+	if idx == -1 || (oa == true && types[idx].Oa == false) || (oa == false && types[idx].Etd == false) {
+		return TypeData{}, fmt.Errorf("%w: [%s] is not an %s resource type", ErrUnknownResourceType, value, kind)
+	}
+	return types[idx].dataciteTypes(), nil
+}
+
+// EtdResourceType identifies the Datacite types of an ETD from its degree, the first ETD
+// resource type whose degrees match wins. Degrees that are not recognised are rejected
+// rather than guessed at
+func EtdResourceType(degree string) (TypeData, error) {
+
+	types, err := ResourceTypes()
+	if err != nil {
+		return TypeData{}, err
+	}
+
+	name := strings.ToLower(degree)
+	abbreviation, _, _ := strings.Cut(strings.TrimSpace(degree), " ")
+	abbreviation = strings.ToUpper(strings.NewReplacer(".", "", "(", "", ")", "").Replace(abbreviation))
+
+	for _, rt := range types {
+		if rt.Etd == false {
+			continue
+		}
+		if slices.Contains(rt.Degrees.Abbreviations, abbreviation) == true {
+			return rt.dataciteTypes(), nil
+		}
+		for _, keyword := range rt.Degrees.Keywords {
+			if strings.Contains(name, keyword) == true {
+				return rt.dataciteTypes(), nil
+			}
+		}
+	}
+	return TypeData{}, fmt.Errorf("%w: [%s] does not identify an ETD resource type", ErrUnknownDegree, degree)
+}
+
+func (rt ResourceType) dataciteTypes() TypeData {
+	types := TypeData{ResourceTypeGeneral: rt.Category, ResourceType: rt.Label}
+	if len(types.ResourceTypeGeneral) == 0 {
+		types.ResourceTypeGeneral = defaultResourceTypeGeneral
+	}
+	return types
+}
+
+// validateResourceTypes ensures every category is a Datacite resourceTypeGeneral
+func validateResourceTypes(types []ResourceType) error {
+	if len(types) == 0 {
+		return fmt.Errorf("%w: no resource types defined", ErrUnknownResourceType)
+	}
+	for _, rt := range types {
+		if len(rt.Category) != 0 && IsResourceTypeGeneral(rt.Category) == false {
+			return fmt.Errorf("%w: resource type [%s] has unsupported category [%s]", ErrUnknownResourceType, rt.Value, rt.Category)
+		}
+	}
+	return nil
+}
+
+//
+// end of file
+//
@@ -0,0 +1,89 @@
+//
+// tests for the resource type table
+//
+
+package libracommon_test
+
+import (
+	"errors"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func TestResourceTypesTable(t *testing.T) {
+	types, err := libracommon.ResourceTypes()
+	if err != nil || len(types) == 0 {
+		t.Fatalf("expected the table, got %v", err)
+	}
+	for _, rt := range types {
+		if len(rt.Degrees.Keywords)+len(rt.Degrees.Abbreviations) != 0 && rt.Etd == false {
+			t.Errorf("%s identifies degrees but is not an ETD type", rt.Value)
+		}
+	}
+}
+
+func TestEtdResourceType(t *testing.T) {
+	for degree, expected := range map[string]string{
+		"PHD (Doctor of Philosophy)":        "Dissertation",
+		"Ph.D. (Doctor of Philosophy)":      "Dissertation",
+		"EDD (Doctor of Education)":         "Dissertation",
+		"MA (Master of Arts)":               "Masters Thesis",
+		"M.S. (Master of Science)":          "Masters Thesis",
+		"MArch (Master of Architecture)":    "Masters Thesis",
+		"Master of Urban Planning":          "Masters Thesis",
+		"BA (Bachelor of Arts)":             "Bachelors Thesis",
+		"Distinguished Major Thesis":        "Bachelors Thesis",
+		"MPP Capstone Project":              "Capstone Project",
+		"BS (Bachelor of Science) capstone": "Capstone Project",
+	} {
+		types, err := libracommon.EtdResourceType(degree)
+		if err != nil || types.ResourceType != expected || types.ResourceTypeGeneral != "Text" {
+			t.Errorf("%q expected %s, got %+v %v", degree, expected, types, err)
+		}
+	}
+
+	for _, degree := range []string{"", "Certificate of Attendance", "GC (Graduate Certificate)"} {
+		_, err := libracommon.EtdResourceType(degree)
+		if errors.Is(err, libracommon.ErrUnknownDegree) == false || libracommon.IsPermanent(err) == false {
+			t.Errorf("%q expected a permanent ErrUnknownDegree, got %v", degree, err)
+		}
+	}
+}
+
+func TestDataciteTypes(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		oa       bool
+		expected libracommon.TypeData
+	}{
+		{"Article", true, libracommon.TypeData{ResourceType: "Article", ResourceTypeGeneral: "JournalArticle"}},
+		{"Video", false, libracommon.TypeData{ResourceType: "Video", ResourceTypeGeneral: "Audiovisual"}},
+		{"Dataset", false, libracommon.TypeData{ResourceType: "Dataset", ResourceTypeGeneral: "Text"}},
+	} {
+		types, err := libracommon.DataciteTypes(tc.value, tc.oa)
+		if err != nil || types != tc.expected {
+			t.Errorf("%s expected %+v, got %+v %v", tc.value, tc.expected, types, err)
+		}
+	}
+
+	// types missing from the table, or not available for the kind of work
+	for value, oa := range map[string]bool{"Pamphlet": true, "Dissertation": true, "Educational Resource": false} {
+		_, err := libracommon.DataciteTypes(value, oa)
+		if errors.Is(err, libracommon.ErrUnknownResourceType) == false || libracommon.IsPermanent(err) == false {
+			t.Errorf("%s expected a permanent ErrUnknownResourceType, got %v", value, err)
+		}
+	}
+}
+
+func TestIsResourceTypeGeneral(t *testing.T) {
+	for value, expected := range map[string]bool{"Text": true, "JournalArticle": true, "Thesis": false, "": false} {
+		if libracommon.IsResourceTypeGeneral(value) != expected {
+			t.Errorf("IsResourceTypeGeneral(%q) expected %t", value, expected)
+		}
+	}
+}
+
+//
+// end of file
+//
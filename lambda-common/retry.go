@@ -0,0 +1,145 @@
+//
+// the retry policy for outbound HTTP requests
+//
+
+package libracommon
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how HttpSend retries failed requests. The delay doubles with each
+// attempt and is jittered, a Retry-After response header is honoured up to MaxDelay
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, including the first
+	BaseDelay   time.Duration // the delay before the first retry
+	MaxDelay    time.Duration // the upper bound of any single delay
+}
+
+// DefaultRetryPolicy is used by HttpSend
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// can we make another attempt
+func (rp RetryPolicy) canRetry(req *http.Request, attempt int) bool {
+	if attempt >= rp.MaxAttempts {
+		return false
+	}
+	// a body we cannot recreate cannot be resent
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return true
+}
+
+// exponential backoff with equal jitter, half the delay is fixed and half is random
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	delay := rp.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > rp.MaxDelay {
+		delay = rp.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half)
+}
+
+// the delay requested by the server, either delay-seconds or an HTTP date, otherwise
+// the usual backoff
+func (rp RetryPolicy) retryAfter(header string, attempt int, now time.Time) time.Duration {
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if when, err := http.ParseTime(header); err == nil {
+		delay = when.Sub(now)
+	} else {
+		return rp.backoff(attempt)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return min(delay, rp.MaxDelay)
+}
+
+// sleep unless the context is done first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// status codes that indicate a temporary condition
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// examines a transport error and decides if it can be retried
+func retryableError(err error) bool {
+
+	// we were cancelled or ran out of time, retrying will not help
+	if errors.Is(err, context.Canceled) == true || errors.Is(err, context.DeadlineExceeded) == true {
+		return false
+	}
+
+	// a bad URL or certificate will fail every time
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) == true {
+		var certErr *x509.UnknownAuthorityError
+		var hostErr x509.HostnameError
+		var invalidErr x509.CertificateInvalidError
+		if errors.As(urlErr.Err, &certErr) || errors.As(urlErr.Err, &hostErr) || errors.As(urlErr.Err, &invalidErr) {
+			return false
+		}
+	}
+
+	// timeouts, including the client timeout
+	var netErr net.Error
+	if errors.As(err, &netErr) == true && netErr.Timeout() == true {
+		return true
+	}
+
+	// name resolution, connection refused, reset, broken pipe and the like
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &dnsErr) == true || errors.As(err, &opErr) == true {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) == true || errors.Is(err, syscall.EPIPE) == true {
+		return true
+	}
+
+	// the server closed the connection before responding
+	if errors.Is(err, io.EOF) == true || errors.Is(err, io.ErrUnexpectedEOF) == true {
+		return true
+	}
+
+	return false
+}
+
+//
+// end of file
+//
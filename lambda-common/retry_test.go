@@ -0,0 +1,271 @@
+//
+// tests for the retry policy of outbound HTTP requests
+//
+
+package libracommon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a policy that does not slow the tests down
+var fastPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond}
+
+// a server that responds with the statuses in order, repeating the last one, and records
+// the bodies it receives
+type statusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   []string
+}
+
+func newStatusServer(t *testing.T, header http.Header, statuses ...int) *statusServer {
+	ss := &statusServer{statuses: statuses, header: header}
+	ss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ss.mu.Lock()
+		ss.bodies = append(ss.bodies, string(body))
+		status := ss.statuses[0]
+		if len(ss.statuses) > 1 {
+			ss.statuses = ss.statuses[1:]
+		}
+		ss.mu.Unlock()
+		for k, v := range ss.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("response"))
+	}))
+	t.Cleanup(ss.Close)
+	return ss
+}
+
+func (ss *statusServer) requests() []string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return append([]string{}, ss.bodies...)
+}
+
+func TestRetryRecreatesBody(t *testing.T) {
+	for _, method := range []string{"POST", "PUT"} {
+		ss := newStatusServer(t, nil, 503, 200)
+
+		req, _ := http.NewRequestWithContext(context.Background(), method, ss.URL, bytes.NewReader([]byte("payload")))
+		body, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+		if err != nil || string(body) != "response" {
+			t.Fatalf("%s: expected success, got %v", method, err)
+		}
+
+		received := ss.requests()
+		if len(received) != 2 || received[0] != "payload" || received[1] != "payload" {
+			t.Errorf("%s: expected the body to be sent with each attempt, got %q", method, received)
+		}
+	}
+}
+
+func TestNoRetryWithoutGetBody(t *testing.T) {
+	ss := newStatusServer(t, nil, 503, 200)
+
+	// a body that cannot be recreated cannot be resent
+	req, _ := http.NewRequestWithContext(context.Background(), "POST", ss.URL, io.NopCloser(strings.NewReader("payload")))
+	_, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+	if err == nil || len(ss.requests()) != 1 {
+		t.Errorf("expected one attempt and a failure, got %d attempts %v", len(ss.requests()), err)
+	}
+}
+
+func TestRetryableStatuses(t *testing.T) {
+	for _, status := range []int{429, 502, 503, 504} {
+		ss := newStatusServer(t, nil, status, status, 200)
+
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", ss.URL, nil)
+		_, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+		if err != nil || len(ss.requests()) != 3 {
+			t.Errorf("%d: expected success on the third attempt, got %d attempts %v", status, len(ss.requests()), err)
+		}
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	for status, throttled := range map[int]bool{429: true, 503: false} {
+		ss := newStatusServer(t, nil, status)
+
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", ss.URL, nil)
+		_, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+
+		var statusErr *HttpStatusError
+		if errors.As(err, &statusErr) == false || statusErr.StatusCode != status {
+			t.Errorf("%d: expected the status error, got %v", status, err)
+		}
+		if IsTransient(err) == false || IsThrottled(err) != throttled {
+			t.Errorf("%d: unexpected classification of %v", status, err)
+		}
+		if len(ss.requests()) != fastPolicy.MaxAttempts {
+			t.Errorf("%d: expected %d attempts, got %d", status, fastPolicy.MaxAttempts, len(ss.requests()))
+		}
+	}
+}
+
+func TestNoRetryOtherStatuses(t *testing.T) {
+	for _, status := range []int{400, 401, 403, 404, 409, 422} {
+		ss := newStatusServer(t, nil, status, 200)
+
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", ss.URL, nil)
+		body, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+		if IsPermanent(err) == false || len(ss.requests()) != 1 {
+			t.Errorf("%d: expected one attempt and a permanent failure, got %d attempts %v", status, len(ss.requests()), err)
+		}
+		// the error response is returned for logging
+		if string(body) != "response" {
+			t.Errorf("%d: expected the response body, got %q", status, body)
+		}
+	}
+}
+
+func TestRetryAfterHonoured(t *testing.T) {
+	// the requested delay is capped by the policy
+	ss := newStatusServer(t, http.Header{"Retry-After": {"120"}}, 503, 200)
+
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", ss.URL, nil)
+	_, err := HttpSendWithPolicy(ss.Client(), req, fastPolicy)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if elapsed < fastPolicy.MaxDelay || elapsed > time.Second {
+		t.Errorf("expected a delay of %s, took %s", fastPolicy.MaxDelay, elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+	for header, expected := range map[string]time.Duration{
+		"3":                             3 * time.Second,
+		"0":                             0,
+		"60":                            10 * time.Second, // capped
+		"Mon, 01 Jan 2024 12:00:05 GMT": 5 * time.Second,
+		"Mon, 01 Jan 2024 13:00:00 GMT": 10 * time.Second, // capped
+		"Mon, 01 Jan 2024 11:59:00 GMT": 0,                // in the past
+	} {
+		if delay := policy.retryAfter(header, 1, now); delay != expected {
+			t.Errorf("Retry-After %q expected %s, got %s", header, expected, delay)
+		}
+	}
+
+	// anything else uses the backoff, between half and all of the delay for the attempt
+	for _, header := range []string{"", "soon", "-"} {
+		delay := policy.retryAfter(header, 2, now)
+		if delay < 100*time.Millisecond || delay >= 200*time.Millisecond {
+			t.Errorf("Retry-After %q expected the backoff, got %s", header, delay)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 8: time.Second, 70: time.Second} {
+		for range 20 {
+			delay := policy.backoff(attempt)
+			if delay < limit/2 || delay >= limit {
+				t.Errorf("attempt %d expected a delay in [%s, %s), got %s", attempt, limit/2, limit, delay)
+			}
+		}
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	ss := newStatusServer(t, http.Header{"Retry-After": {"10"}}, 503)
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ss.URL, nil)
+	_, err := HttpSendWithPolicy(ss.Client(), req, policy)
+
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the retry to stop at the deadline, took %s", time.Since(start))
+	}
+	if errors.Is(err, context.DeadlineExceeded) == false || IsTransient(err) == false {
+		t.Errorf("expected a transient deadline failure, got %v", err)
+	}
+	if len(ss.requests()) != 1 {
+		t.Errorf("expected one attempt, got %d", len(ss.requests()))
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+
+	// nothing listening, worth a retry
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", closed.URL, nil)
+	_, err := HttpSendWithPolicy(http.DefaultClient, req, fastPolicy)
+	if err == nil || IsTransient(err) == false {
+		t.Errorf("connection refused: expected a transient failure, got %v", err)
+	}
+
+	// a certificate we do not trust fails every time
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", tlsServer.URL, nil)
+	_, err = HttpSendWithPolicy(&http.Client{}, req, fastPolicy)
+	if IsPermanent(err) == false {
+		t.Errorf("untrusted certificate: expected a permanent failure, got %v", err)
+	}
+
+	// as does an unsupported scheme
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "ftp://example.edu/file", nil)
+	_, err = HttpSendWithPolicy(http.DefaultClient, req, fastPolicy)
+	if IsPermanent(err) == false {
+		t.Errorf("unsupported scheme: expected a permanent failure, got %v", err)
+	}
+
+	// a client timeout is worth a retry
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", slow.URL, nil)
+	_, err = HttpSendWithPolicy(&http.Client{Timeout: 10 * time.Millisecond}, req, fastPolicy)
+	if err == nil || IsTransient(err) == false {
+		t.Errorf("client timeout: expected a transient failure, got %v", err)
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	for err, expected := range map[error]bool{
+		context.Canceled:         false,
+		context.DeadlineExceeded: false,
+		io.EOF:                   true,
+		io.ErrUnexpectedEOF:      true,
+		errors.New("unknown"):    false,
+	} {
+		if retryableError(err) != expected {
+			t.Errorf("retryableError(%v) expected %t", err, expected)
+		}
+	}
+}
+
+//
+// end of file
+//
@@ -1,34 +1,41 @@
 //
-// simple module to get and set parameter values in the ssm
+// simple module to put objects into S3
 //
 
-package main
+package libracommon
 
 import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-func newS3Client() (*s3.Client, error) {
+// our ObjectStore implementation
+type s3ObjectStore struct {
+	client *s3.Client
+}
+
+func NewS3Client() (ObjectStore, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, err
 	}
-	return s3.NewFromConfig(cfg), nil
+	return &s3ObjectStore{client: s3.NewFromConfig(cfg)}, nil
 }
 
-func putS3(client *s3.Client, bucket string, key string, buffer []byte) error {
+func (os3 *s3ObjectStore) PutObject(ctx context.Context, bucket string, key string, buffer []byte) error {
 
-	fmt.Printf("DEBUG: uploading s3://%s/%s\n", bucket, key)
+	LogDebug("uploading s3://%s/%s", bucket, key)
 
 	start := time.Now()
-	_, err := client.PutObject(context.TODO(),
+	_, err := os3.client.PutObject(ctx,
 		&s3.PutObjectInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
@@ -40,7 +47,45 @@ func putS3(client *s3.Client, bucket string, key string, buffer []byte) error {
 	}
 
 	duration := time.Since(start)
-	fmt.Printf("DEBUG: upload complete in %d ms\n", duration.Milliseconds())
+	LogDebug("upload complete in %d ms", duration.Milliseconds())
+	return nil
+}
+
+func (os3 *s3ObjectStore) UploadFile(ctx context.Context, bucket string, key string, localName string) error {
+
+	target := fmt.Sprintf("s3://%s/%s", bucket, key)
+	//LogDebug("put from %s to %s", localName, target)
+
+	// open the file
+	file, err := os.Open(localName)
+	if err != nil {
+		// assume the error is file not found... probably reasonable
+		return os.ErrNotExist
+	}
+	defer file.Close()
+
+	// get the filesize
+	s, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := s.Size()
+
+	// Upload the file to S3.
+	uploader := manager.NewUploader(os3.client)
+	start := time.Now()
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+		Body:   file,
+	})
+	if err != nil {
+		LogError("uploading [%s] => [%s] (%s)", localName, target, err.Error())
+		return err
+	}
+
+	duration := time.Since(start)
+	LogDebug("put %s complete in %d ms (%d bytes, %0.2f bytes/sec)", target, duration.Milliseconds(), fileSize, float64(fileSize)/duration.Seconds())
 	return nil
 }
 
@@ -0,0 +1,150 @@
+//
+// resolve configuration values that reference the SSM parameter store or the secrets manager
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// configuration values with these prefixes are resolved rather than used as is
+//
+//	ssm:/path/to/parameter         (SecureString parameters are decrypted)
+//	secretsmanager:name#key        (key selects a field from a JSON secret, optional)
+const ssmReferencePrefix = "ssm:"
+const secretsReferencePrefix = "secretsmanager:"
+
+// our SecretStore implementation
+type secretsManagerStore struct {
+	client *secretsmanager.Client
+}
+
+func NewSecretsClient() (SecretStore, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &secretsManagerStore{client: secretsmanager.NewFromConfig(cfg)}, nil
+}
+
+func (sm *secretsManagerStore) GetSecret(ctx context.Context, name string) (string, error) {
+	secret, err := sm.client.GetSecretValue(ctx,
+		&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(name),
+		})
+
+	if err != nil {
+		LogError("getting secret (%s)", err.Error())
+		return "", err
+	}
+
+	if secret.SecretString == nil {
+		return "", fmt.Errorf("secret is not a string: [%s]", name)
+	}
+	return *secret.SecretString, nil
+}
+
+// isSecretReference reports if the value is resolved from the parameter store or the secrets manager
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, ssmReferencePrefix) || strings.HasPrefix(value, secretsReferencePrefix)
+}
+
+// SecretResolver resolves secret references, caching the results so each one is
+// fetched once for the lifetime of the lambda container
+type SecretResolver struct {
+	deps       Deps
+	mu         sync.Mutex
+	parameters ParameterStore
+	secrets    SecretStore
+	cache      map[string]string
+}
+
+// the resolver used by LoadConfig, only the stores it needs are set as the
+// other dependencies are themselves configured using LoadConfig
+var defaultSecretResolver = NewSecretResolver(Deps{Parameters: NewParameterClient, Secrets: NewSecretsClient})
+
+func NewSecretResolver(deps Deps) *SecretResolver {
+	return &SecretResolver{deps: deps, cache: make(map[string]string)}
+}
+
+// Resolve returns the referenced secret, values that are not references are returned unchanged
+func (sr *SecretResolver) Resolve(value string) (string, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(value, ssmReferencePrefix):
+		return sr.cached(value, func() (string, error) {
+			if sr.parameters == nil {
+				ps, err := sr.deps.Parameters()
+				if err != nil {
+					return "", err
+				}
+				sr.parameters = ps
+			}
+			return sr.parameters.GetSecureParameter(context.Background(), strings.TrimPrefix(value, ssmReferencePrefix))
+		})
+
+	case strings.HasPrefix(value, secretsReferencePrefix):
+		name, key, hasKey := strings.Cut(strings.TrimPrefix(value, secretsReferencePrefix), "#")
+		secret, err := sr.cached(secretsReferencePrefix+name, func() (string, error) {
+			if sr.secrets == nil {
+				ss, err := sr.deps.Secrets()
+				if err != nil {
+					return "", err
+				}
+				sr.secrets = ss
+			}
+			return sr.secrets.GetSecret(context.Background(), name)
+		})
+		if err != nil || hasKey == false {
+			return secret, err
+		}
+		return secretField(name, secret, key)
+	}
+
+	return value, nil
+}
+
+func (sr *SecretResolver) cached(reference string, fetch func() (string, error)) (string, error) {
+	if v, found := sr.cache[reference]; found == true {
+		return v, nil
+	}
+	v, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	sr.cache[reference] = v
+	return v, nil
+}
+
+// extract a single field from a JSON secret
+func secretField(name string, secret string, key string) (string, error) {
+	fields := make(map[string]any)
+	err := json.Unmarshal([]byte(secret), &fields)
+	if err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: [%s] (%s)", name, err.Error())
+	}
+	v, found := fields[key]
+	if found == false {
+		return "", fmt.Errorf("secret key not found: [%s#%s]", name, key)
+	}
+	if str, ok := v.(string); ok == true {
+		return str, nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+//
+// end of file
+//
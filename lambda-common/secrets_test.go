@@ -0,0 +1,78 @@
+//
+// tests for resolving ssm: and secretsmanager: references
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func newTestResolver(fd *fakes.Dependencies) *libracommon.SecretResolver {
+	_ = fd.Parameters.SetParameter(context.Background(), "/libra/password", "s3cret")
+	fd.Secrets.Set("libra/db", `{"user":"libra","port":5432}`)
+	fd.Secrets.Set("libra/plain", `not json`)
+	return libracommon.NewSecretResolver(fd.Deps())
+}
+
+func TestSecretResolver(t *testing.T) {
+	resolver := newTestResolver(fakes.New())
+
+	for value, expected := range map[string]string{
+		"plain value":                  "plain value",
+		"":                             "",
+		"ssm:/libra/password":          "s3cret",
+		"secretsmanager:libra/db#user": "libra",
+		"secretsmanager:libra/db#port": "5432",
+		"secretsmanager:libra/plain":   "not json",
+	} {
+		resolved, err := resolver.Resolve(value)
+		if err != nil || resolved != expected {
+			t.Errorf("Resolve(%q) expected [%s], got [%s] %v", value, expected, resolved, err)
+		}
+	}
+}
+
+func TestSecretResolverFailures(t *testing.T) {
+	resolver := newTestResolver(fakes.New())
+
+	for _, value := range []string{
+		"ssm:/libra/missing",
+		"secretsmanager:libra/missing",
+		"secretsmanager:libra/db#missing",
+		"secretsmanager:libra/plain#user",
+	} {
+		_, err := resolver.Resolve(value)
+		if err == nil {
+			t.Errorf("Resolve(%q) expected a failure", value)
+		}
+	}
+}
+
+func TestSecretResolverCaches(t *testing.T) {
+	fd := fakes.New()
+	resolver := newTestResolver(fd)
+
+	_, _ = resolver.Resolve("ssm:/libra/password")
+	_, _ = resolver.Resolve("secretsmanager:libra/db#user")
+
+	// later changes are not seen by the resolver
+	_ = fd.Parameters.SetParameter(context.Background(), "/libra/password", "changed")
+	fd.Secrets.Set("libra/db", `{"user":"changed","port":1}`)
+
+	if resolved, _ := resolver.Resolve("ssm:/libra/password"); resolved != "s3cret" {
+		t.Errorf("expected the cached parameter, got [%s]", resolved)
+	}
+	// each key is taken from the cached secret
+	if resolved, _ := resolver.Resolve("secretsmanager:libra/db#port"); resolved != "5432" {
+		t.Errorf("expected the cached secret, got [%s]", resolved)
+	}
+}
+
+//
+// end of file
+//
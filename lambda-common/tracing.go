@@ -0,0 +1,168 @@
+//
+// tracing, a span is started for each event processed and each outbound call. The trace
+// context travels between lambdas in the bus event detail and to downstream services in
+// the request headers. OTEL_TRACES_EXPORTER selects the exporter: otlp (configured by the
+// standard OTEL_EXPORTER_OTLP_* variables), console or none (the default)
+//
+
+package libracommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the bus event detail field carrying the trace context
+const traceDetailField = "trace_context"
+
+// how long to wait for spans to be exported at the end of an invocation
+var traceFlushTimeout = 2 * time.Second
+
+// spans go to the global provider which is replaced by initTracing when tracing is enabled
+var tracer = otel.Tracer("github.com/uvalib/libra-lambda/lambda-common")
+
+var tracingOnce sync.Once
+var tracerProvider *sdktrace.TracerProvider
+
+// set up the propagator and, if an exporter is configured, the tracer provider
+func initTracing() {
+	tracingOnce.Do(func() {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		exporter, err := newTraceExporter(os.Getenv("OTEL_TRACES_EXPORTER"))
+		if err != nil {
+			LogError("creating trace exporter, tracing disabled (%s)", err.Error())
+			return
+		}
+		if exporter == nil {
+			return
+		}
+
+		tracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(lambdaName()))),
+		)
+		otel.SetTracerProvider(tracerProvider)
+	})
+}
+
+func newTraceExporter(name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		return otlptracehttp.New(context.Background())
+	case "console", "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	return nil, fmt.Errorf("%w: OTEL_TRACES_EXPORTER [%s]", ErrBadConfigType, name)
+}
+
+// export any outstanding spans, a lambda may be frozen as soon as the invocation returns
+func flushTracing(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), traceFlushTimeout)
+	defer cancel()
+	err := tracerProvider.ForceFlush(ctx)
+	if err != nil {
+		LogWarning("exporting spans (%s)", err.Error())
+	}
+}
+
+// wrap an event processor so that each event is processed within a span, continuing the
+// trace of whoever published the event
+func withTracing(process EventProcessor) EventProcessor {
+
+	initTracing()
+
+	return func(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+
+		name := "process"
+		attrs := []attribute.KeyValue{semconv.MessagingMessageID(messageId), attribute.String("libra.source", messageSrc)}
+
+		// errors are reported by the processor itself
+		ev, _ := uvalibrabus.MakeBusEvent(rawMsg)
+		if ev != nil {
+			ctx = extractTrace(ctx, ev.Detail)
+			name = "process " + ev.EventName
+			attrs = append(attrs,
+				attribute.String("libra.event", ev.EventName),
+				attribute.String("libra.namespace", ev.Namespace),
+				attribute.String("libra.id", ev.Identifier))
+		}
+
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attrs...))
+		setLogTrace(span.SpanContext())
+
+		err := process(ctx, messageId, messageSrc, rawMsg)
+		endSpan(span, err)
+		flushTracing(ctx)
+		return err
+	}
+}
+
+// add the trace context of ctx to a bus event detail, a detail that is not a JSON object
+// is returned unchanged
+func injectTrace(ctx context.Context, detail json.RawMessage) json.RawMessage {
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return detail
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if len(detail) != 0 && json.Unmarshal(detail, &fields) != nil {
+		return detail
+	}
+
+	fields[traceDetailField], _ = json.Marshal(carrier)
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return detail
+	}
+	return buf
+}
+
+// continue the trace recorded in a bus event detail, if there is one
+func extractTrace(ctx context.Context, detail json.RawMessage) context.Context {
+
+	var traced struct {
+		Trace map[string]string `json:"trace_context"`
+	}
+	if len(detail) == 0 || json.Unmarshal(detail, &traced) != nil || len(traced.Trace) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(traced.Trace))
+}
+
+// end a span, recording the error if there is one
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//
+// end of file
+//
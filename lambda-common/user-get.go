@@ -2,11 +2,11 @@
 //
 //
 
-package main
+package libracommon
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 )
@@ -26,15 +26,15 @@ type UserDetails struct {
 	Email       string `json:"email,omitempty"`
 }
 
-func getUserDetails(url string, cid string, auth string, client *http.Client) (*UserDetails, error) {
+func GetUserDetails(ctx context.Context, url string, cid string, auth string, client *http.Client) (*UserDetails, error) {
 
 	// substitute values into url
 	url = strings.Replace(url, "{:id}", cid, 1)
 	url = strings.Replace(url, "{:auth}", auth, 1)
 
-	payload, err := httpGet(client, url)
+	payload, err := HttpGet(ctx, client, url)
 	if err != nil {
-		if strings.Contains(err.Error(), "HTTP 404") == true {
+		if IsHttpNotFound(err) == true {
 			return nil, nil
 		}
 		return nil, err
@@ -43,13 +43,13 @@ func getUserDetails(url string, cid string, auth string, client *http.Client) (*
 	resp := UserDetailsResponse{}
 	err = json.Unmarshal(payload, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of UserDetailsResponse (%s)\n", err.Error())
+		LogError("json unmarshal of UserDetailsResponse (%s)", err.Error())
 		return nil, err
 	}
 
 	// if we have details, return them
 	if resp.Details != nil {
-		fmt.Printf("INFO: located details for cid [%s]\n", cid)
+		LogInfo("located details for cid [%s]", cid)
 		return resp.Details, nil
 	}
 
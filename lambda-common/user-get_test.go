@@ -0,0 +1,61 @@
+//
+// tests for the user service helper
+//
+
+package libracommon_test
+
+import (
+	"context"
+	"testing"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+)
+
+func TestGetUserDetails(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/user/ab1c", 200, `{"status":200,"user":{"cid":"ab1c","display_name":"Ann Bell","email":"ab1c@example.edu"}}`)
+	url := svc.URL + "/user/{:id}?auth={:auth}"
+
+	user, err := libracommon.GetUserDetails(context.Background(), url, "ab1c", "a-token", svc.Client())
+	if err != nil || user == nil {
+		t.Fatalf("expected user details, got %+v %v", user, err)
+	}
+	if user.Email != "ab1c@example.edu" || user.DisplayName != "Ann Bell" {
+		t.Errorf("unexpected user details %+v", user)
+	}
+	if query := svc.Requests()[0].Query; query != "auth=a-token" {
+		t.Errorf("expected the token in the query, got [%s]", query)
+	}
+}
+
+func TestGetUserDetailsNotFound(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/user/nobody", 200, `{"status":200}`)
+	url := svc.URL + "/user/{:id}?auth={:auth}"
+
+	// the service responding 404 or with no details is not an error
+	for _, cid := range []string{"nobody", "unknown"} {
+		user, err := libracommon.GetUserDetails(context.Background(), url, cid, "a-token", svc.Client())
+		if err != nil || user != nil {
+			t.Errorf("%s: expected no details and no error, got %+v %v", cid, user, err)
+		}
+	}
+}
+
+func TestGetUserDetailsFailure(t *testing.T) {
+	svc := fakes.NewHttpService()
+	defer svc.Close()
+	svc.Respond("GET", "/user/ab1c", 401, `{"status":401}`)
+
+	_, err := libracommon.GetUserDetails(context.Background(), svc.URL+"/user/{:id}", "ab1c", "", svc.Client())
+	if libracommon.IsPermanent(err) == false {
+		t.Errorf("expected a permanent failure, got %v", err)
+	}
+}
+
+//
+// end of file
+//
@@ -2,13 +2,15 @@
 //
 //
 
-package main
+package libraaptrust
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 //
@@ -39,7 +41,7 @@ type SubmitInitiateResponse struct {
 	// other stuff
 }
 
-func registerSubmission(cfg *Config, httpClient *http.Client, bagName string) (*SubmitRegisterResponse, error) {
+func registerSubmission(ctx context.Context, cfg *Config, httpClient *http.Client, bagName string) (*SubmitRegisterResponse, error) {
 
 	start := time.Now()
 
@@ -49,12 +51,12 @@ func registerSubmission(cfg *Config, httpClient *http.Client, bagName string) (*
 
 	pl, err := json.Marshal(req)
 	if err != nil {
-		fmt.Printf("ERROR: json marshal of SubmitRegisterRequest (%s)\n", err.Error())
+		libracommon.LogError("json marshal of SubmitRegisterRequest (%s)", err.Error())
 		return nil, err
 	}
 
 	// post the request
-	pl, err = httpPost(httpClient, cfg.APTServiceRegister, pl, "application/json")
+	pl, err = libracommon.HttpPost(ctx, httpClient, cfg.APTServiceRegister, pl, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -63,16 +65,16 @@ func registerSubmission(cfg *Config, httpClient *http.Client, bagName string) (*
 	resp := SubmitRegisterResponse{}
 	err = json.Unmarshal(pl, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of SubmitRegisterResponse (%s)\n", err.Error())
+		libracommon.LogError("json unmarshal of SubmitRegisterResponse (%s)", err.Error())
 		return nil, err
 	}
 
 	duration := time.Since(start)
-	fmt.Printf("INFO: submit register complete in %d ms [%s]\n", duration.Milliseconds(), resp.SubmissionIdentifier)
+	libracommon.LogInfo("submit register complete in %d ms [%s]", duration.Milliseconds(), resp.SubmissionIdentifier)
 	return &resp, nil
 }
 
-func initiateSubmission(cfg *Config, httpClient *http.Client, sid string, bagName string) error {
+func initiateSubmission(ctx context.Context, cfg *Config, httpClient *http.Client, sid string, bagName string) error {
 
 	start := time.Now()
 
@@ -83,12 +85,12 @@ func initiateSubmission(cfg *Config, httpClient *http.Client, sid string, bagNam
 
 	pl, err := json.Marshal(req)
 	if err != nil {
-		fmt.Printf("ERROR: json marshal of SubmitInitiateRequest (%s)\n", err.Error())
+		libracommon.LogError("json marshal of SubmitInitiateRequest (%s)", err.Error())
 		return err
 	}
 
 	// post the request
-	pl, err = httpPost(httpClient, cfg.APTServiceSubmit, pl, "application/json")
+	pl, err = libracommon.HttpPost(ctx, httpClient, cfg.APTServiceSubmit, pl, "application/json")
 	if err != nil {
 		return err
 	}
@@ -97,12 +99,12 @@ func initiateSubmission(cfg *Config, httpClient *http.Client, sid string, bagNam
 	resp := SubmitInitiateResponse{}
 	err = json.Unmarshal(pl, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of SubmitInitiateResponse (%s)\n", err.Error())
+		libracommon.LogError("json unmarshal of SubmitInitiateResponse (%s)", err.Error())
 		return err
 	}
 
 	duration := time.Since(start)
-	fmt.Printf("INFO: submit initiate complete in %d ms\n", duration.Milliseconds())
+	libracommon.LogInfo("submit initiate complete in %d ms", duration.Milliseconds())
 	return nil
 }
 
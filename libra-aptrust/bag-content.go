@@ -2,9 +2,10 @@
 //
 //
 
-package main
+package libraaptrust
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	librametadata "github.com/uvalib/libra-metadata"
 )
 
@@ -24,25 +26,34 @@ var auditFilename = "audit.json"
 var descriptionFileName = "aptrust-description.txt"
 var titleFileName = "aptrust-title.txt"
 var manifestFilename = "manifest-md5.txt"
+var dataciteFilename = "datacite.xml"
 
-func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.EasyStoreObject) (string, []string, error) {
+func createBagContent(ctx context.Context, cfg *Config, httpClient *http.Client, obj uvaeasystore.EasyStoreObject) (string, []string, error) {
 
 	// create the bag name and working directory
 	bagName := strings.Replace(cfg.BagNameTemplate, "{:oid}", obj.Id(), 1)
 	workDir := filepath.Join(cfg.ScratchFilesystem, bagName)
 	files := make([]string, 0)
 
+	// an earlier attempt may have been stopped part way through
+	cp := loadCheckpoint(workDir, obj.VTag())
+	keep := ""
+	if cp.resuming() == true {
+		libracommon.LogInfo("resuming bag [%s], %d file(s) already written", bagName, len(cp.Files))
+		keep = bagName
+	}
+
 	// clean the scratch filesystem (it can persist across lambda executions, who knew)
-	err := cleanScratchFilesystem(cfg.ScratchFilesystem)
+	err := cleanScratchFilesystem(cfg.ScratchFilesystem, keep)
 	if err != nil {
-		fmt.Printf("ERROR: cleaning scratch filesystem [%s] (%s)\n", cfg.ScratchFilesystem, err.Error())
+		libracommon.LogError("cleaning scratch filesystem [%s] (%s)", cfg.ScratchFilesystem, err.Error())
 		return bagName, files, err
 	}
 
 	// create the working directory
 	err = os.MkdirAll(workDir, 0755)
 	if err != nil {
-		fmt.Printf("ERROR: creating work directory [%s] (%s)\n", workDir, err.Error())
+		libracommon.LogError("creating work directory [%s] (%s)", workDir, err.Error())
 		return bagName, files, err
 	}
 
@@ -52,7 +63,7 @@ func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.Eas
 	if obj.Metadata() != nil {
 		buf, err = obj.Metadata().Payload()
 		if err != nil {
-			fmt.Printf("ERROR: getting metadata payload (%s)\n", err.Error())
+			libracommon.LogError("getting metadata payload (%s)", err.Error())
 			return bagName, files, err
 		}
 
@@ -67,8 +78,8 @@ func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.Eas
 		// write the title and description files
 		meta, err := librametadata.ETDWorkFromBytes(buf)
 		if err != nil {
-			fmt.Printf("ERROR: creating libra metadata (%s)\n", err.Error())
-			return bagName, files, err
+			libracommon.LogError("creating libra metadata (%s)", err.Error())
+			return bagName, files, libracommon.Permanent(err)
 		}
 
 		if len(meta.Title) != 0 {
@@ -88,13 +99,29 @@ func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.Eas
 			// add to the bag files list
 			files = append(files, descriptionFileName)
 		}
+
+		// works are preserved with their Datacite description when they have a DOI
+		attributes, err := libracommon.EtdDataciteAttributes(meta, obj.Fields(), nil, deps.Now)
+		if err == nil {
+			buf, err = libracommon.RenderDataciteXML(&attributes)
+		}
+		if err == nil {
+			err = writeFile(filepath.Join(workDir, dataciteFilename), buf)
+			if err != nil {
+				return bagName, files, err
+			}
+			// add to the bag files list
+			files = append(files, dataciteFilename)
+		} else {
+			libracommon.LogWarning("not including Datacite XML (%s)", err.Error())
+		}
 	}
 
 	// if the fields exist, write them
 	if obj.Fields() != nil {
 		buf, err = json.Marshal(obj.Fields())
 		if err != nil {
-			fmt.Printf("ERROR: getting fields payload (%s)\n", err.Error())
+			libracommon.LogError("getting fields payload (%s)", err.Error())
 			return bagName, files, err
 		}
 
@@ -110,13 +137,27 @@ func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.Eas
 	// if the files exist, write them
 	if obj.Files() != nil {
 		for _, f := range obj.Files() {
+			// written by an earlier attempt
+			if cp.done(f.Name()) == true {
+				files = append(files, f.Name())
+				continue
+			}
+
+			// out of time, keep what we have for the next attempt
+			if ctx.Err() != nil {
+				return bagName, files, stopBagContent(ctx, cp, workDir)
+			}
+
 			if len(f.Url()) != 0 {
-				buf, err = httpGet(httpClient, f.Url())
+				buf, err = libracommon.HttpGet(ctx, httpClient, f.Url())
 			} else {
 				buf, err = f.Payload()
 			}
 			if err != nil {
-				fmt.Printf("ERROR: getting file payload (%s)\n", err.Error())
+				libracommon.LogError("getting file payload (%s)", err.Error())
+				if ctx.Err() != nil {
+					return bagName, files, stopBagContent(ctx, cp, workDir)
+				}
 				return bagName, files, err
 			}
 
@@ -127,20 +168,33 @@ func createBagContent(cfg *Config, httpClient *http.Client, obj uvaeasystore.Eas
 
 			// add to the bag files list
 			files = append(files, f.Name())
+			cp.add(f.Name())
 		}
 	}
 
 	// generate the audit
-	files, err = generateAudit(cfg, httpClient, obj, workDir, files)
+	files, err = generateAudit(ctx, cfg, httpClient, obj, workDir, files)
 	if err != nil {
 		return bagName, files, err
 	}
 
 	// generate the manifest
 	files, err = generateManifest(workDir, files)
+	if err != nil {
+		return bagName, files, err
+	}
 
 	// and we are done...
-	return bagName, files, err
+	return bagName, files, removeCheckpoint(workDir)
+}
+
+// save the checkpoint and return a retryable error so the event is redelivered
+func stopBagContent(ctx context.Context, cp *bagCheckpoint, workDir string) error {
+	err := cp.save(workDir)
+	if err != nil {
+		libracommon.LogError("saving checkpoint (%s)", err.Error())
+	}
+	return libracommon.Transient(ctx.Err())
 }
 
 func generateManifest(workDir string, files []string) ([]string, error) {
@@ -162,17 +216,17 @@ func generateManifest(workDir string, files []string) ([]string, error) {
 	return files, os.WriteFile(fname, []byte(md5Data), 0644)
 }
 
-func generateAudit(cfg *Config, httpClient *http.Client, obj uvaeasystore.EasyStoreObject, workDir string, files []string) ([]string, error) {
+func generateAudit(ctx context.Context, cfg *Config, httpClient *http.Client, obj uvaeasystore.EasyStoreObject, workDir string, files []string) ([]string, error) {
 
 	// generate the query URL
 	url := cfg.AuditQuery
 	url = strings.Replace(url, "{:ns}", obj.Namespace(), 1)
 	url = strings.Replace(url, "{:oid}", obj.Id(), 1)
 
-	buf, err := httpGet(httpClient, url)
+	buf, err := libracommon.HttpGet(ctx, httpClient, url)
 	// lets ignore errors for now
 	if err != nil {
-		fmt.Printf("WARNING: getting work audit information (%s)\n", err.Error())
+		libracommon.LogWarning("getting work audit information (%s)", err.Error())
 		//		return files, err
 		return files, nil
 	}
@@ -190,23 +244,30 @@ func generateAudit(cfg *Config, httpClient *http.Client, obj uvaeasystore.EasySt
 func md5Checksum(filename string) (string, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Printf("ERROR: reading [%s] (%s)\n", filename, err.Error())
+		libracommon.LogError("reading [%s] (%s)", filename, err.Error())
 		return "", err
 	}
 	return fmt.Sprintf("%x", md5.Sum(data)), nil
 }
 
+// write via a temporary file so an interrupted write never leaves a partial file behind
 func writeFile(filename string, buffer []byte) error {
 
-	err := os.WriteFile(filename, buffer, 0644)
+	tmpname := filename + ".tmp"
+	err := os.WriteFile(tmpname, buffer, 0644)
+	if err == nil {
+		err = os.Rename(tmpname, filename)
+	}
 	if err != nil {
-		fmt.Printf("ERROR: writing [%s] (%s)\n", filename, err.Error())
+		libracommon.LogError("writing [%s] (%s)", filename, err.Error())
+		_ = os.Remove(tmpname)
 		return err
 	}
 	return nil
 }
 
-func cleanScratchFilesystem(dir string) error {
+// remove everything in the scratch filesystem except the named entry (if any)
+func cleanScratchFilesystem(dir string, keep string) error {
 
 	de, err := os.ReadDir(dir)
 	if err != nil {
@@ -214,12 +275,15 @@ func cleanScratchFilesystem(dir string) error {
 	}
 
 	for _, d := range de {
+		if len(keep) != 0 && d.Name() == keep {
+			continue
+		}
 		err = os.RemoveAll(path.Join(dir, d.Name()))
 		if err != nil {
 			return err
 		}
 	}
-	
+
 	return nil
 }
 
@@ -0,0 +1,84 @@
+//
+// bag checkpoint, records the files already downloaded so that a bag interrupted by the
+// lambda deadline can be resumed when the event is redelivered
+//
+
+package libraaptrust
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+var checkpointFilename = ".checkpoint.json"
+
+type bagCheckpoint struct {
+	VTag  string   `json:"vtag"`  // the object version the files were written from
+	Files []string `json:"files"` // the bag files already written
+}
+
+// load the checkpoint for the bag, a missing checkpoint or one for a different
+// object version returns an empty one
+func loadCheckpoint(workDir string, vtag string) *bagCheckpoint {
+
+	cp := bagCheckpoint{VTag: vtag}
+	buf, err := os.ReadFile(filepath.Join(workDir, checkpointFilename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) == false {
+			libracommon.LogWarning("reading checkpoint, ignoring (%s)", err.Error())
+		}
+		return &cp
+	}
+
+	var existing bagCheckpoint
+	err = json.Unmarshal(buf, &existing)
+	if err != nil {
+		libracommon.LogWarning("decoding checkpoint, ignoring (%s)", err.Error())
+		return &cp
+	}
+
+	if existing.VTag != vtag {
+		libracommon.LogWarning("checkpoint is for object version [%s] not [%s], ignoring", existing.VTag, vtag)
+		return &cp
+	}
+	return &existing
+}
+
+// resuming reports whether any files were written by a previous attempt
+func (cp *bagCheckpoint) resuming() bool {
+	return len(cp.Files) != 0
+}
+
+func (cp *bagCheckpoint) done(name string) bool {
+	return slices.Contains(cp.Files, name)
+}
+
+func (cp *bagCheckpoint) add(name string) {
+	cp.Files = append(cp.Files, name)
+}
+
+func (cp *bagCheckpoint) save(workDir string) error {
+	buf, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	libracommon.LogInfo("checkpointing bag with %d file(s) written", len(cp.Files))
+	return writeFile(filepath.Join(workDir, checkpointFilename), buf)
+}
+
+func removeCheckpoint(workDir string) error {
+	err := os.Remove(filepath.Join(workDir, checkpointFilename))
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return err
+	}
+	return nil
+}
+
+//
+// end of file
+//
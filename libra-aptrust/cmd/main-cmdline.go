@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libraaptrust "github.com/uvalib/libra-aptrust"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.RunCmdline(libraaptrust.Process)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraaptrust "github.com/uvalib/libra-aptrust"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libracommon.Idempotent(libraaptrust.Deps(), libraaptrust.Process))
+}
+
+//
+// end of file
+//
@@ -1,24 +1,24 @@
-package main
+package libraaptrust
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 
 	// APTrust submission service configuration
-	APTServiceRegister string // url for APTrust submission registration
-	APTServiceSubmit   string // url for APTrust submit
-	APTServiceClient   string // client identifier for APTrust submit
+	APTServiceRegister string `env:"APT_REGISTER_URL" required:"true"` // url for APTrust submission registration
+	APTServiceSubmit   string `env:"APT_SUBMIT_URL" required:"true"`   // url for APTrust submit
+	APTServiceClient   string `env:"APT_CLIENT_ID" required:"true"`    // client identifier for APTrust submit
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// other configuration
-	AuditQuery        string // the work audit query URL
-	BagNameTemplate   string // the bag name template
-	ScratchFilesystem string // the scratch filesystem
+	AuditQuery        string `env:"AUDIT_QUERY_TEMPLATE" required:"true"` // the work audit query URL
+	BagNameTemplate   string `env:"BAG_NAME_TEMPLATE" required:"true"`    // the bag name template
+	ScratchFilesystem string `env:"SCRATCH_FS" required:"true"`           // the scratch filesystem
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -27,54 +27,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-
-	// APTrust submission service configuration
-	cfg.APTServiceRegister, err = ensureSetAndNonEmpty("APT_REGISTER_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.APTServiceSubmit, err = ensureSetAndNonEmpty("APT_SUBMIT_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.APTServiceClient, err = ensureSetAndNonEmpty("APT_CLIENT_ID")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	// easystore proxy configuration
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	// other configuration
-	cfg.AuditQuery, err = ensureSetAndNonEmpty("AUDIT_QUERY_TEMPLATE")
-	if err != nil {
-		return nil, err
-	}
-	cfg.BagNameTemplate, err = ensureSetAndNonEmpty("BAG_NAME_TEMPLATE")
-	if err != nil {
-		return nil, err
-	}
-	cfg.ScratchFilesystem, err = ensureSetAndNonEmpty("SCRATCH_FS")
-	if err != nil {
-		return nil, err
-	}
-
-	// APTrust submission service configuration
-	fmt.Printf("[conf] APTServiceRegister = [%s]\n", cfg.APTServiceRegister)
-	fmt.Printf("[conf] APTServiceSubmit   = [%s]\n", cfg.APTServiceSubmit)
-	fmt.Printf("[conf] APTServiceClient   = [%s]\n", cfg.APTServiceClient)
-
-	// easystore proxy configuration
-	fmt.Printf("[conf] EsProxyUrl         = [%s]\n", cfg.EsProxyUrl)
-
-	// other configuration
-	fmt.Printf("[conf] AuditQuery         = [%s]\n", cfg.AuditQuery)
-	fmt.Printf("[conf] BagNameTemplate    = [%s]\n", cfg.BagNameTemplate)
-	fmt.Printf("[conf] ScratchFilesystem  = [%s]\n", cfg.ScratchFilesystem)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
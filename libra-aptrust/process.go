@@ -2,30 +2,41 @@
 // main message processing
 //
 
-package main
+package libraaptrust
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Deps returns the external dependencies, the idempotency ledger wraps the deployed processor
+func Deps() libracommon.Deps {
+	return deps
+}
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -36,68 +47,69 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// easystore access
-	esro, err := newEasystoreReadonlyProxy(cfg)
+	esro, err := deps.EasystoreReadonly(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer esro.Close()
 
-	obj, err := getEasystoreObjectByKey(esro, ev.Namespace, ev.Identifier, uvaeasystore.AllComponents)
+	obj, err := libracommon.GetEasystoreObjectByKey(ctx, esro, ev.Namespace, ev.Identifier, uvaeasystore.AllComponents)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	// only published content to APTrust
 	if obj.Fields()["draft"] == "true" {
-		fmt.Printf("WARNING: object ns/oid [%s/%s] is a draft; not sending to APTrust\n", ev.Namespace, ev.Identifier)
+		libracommon.LogWarning("object ns/oid [%s/%s] is a draft; not sending to APTrust", ev.Namespace, ev.Identifier)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
 	// get a new http client
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
 	// write the content to the local filesystem
-	bagName, files, err := createBagContent(cfg, httpClient, obj)
+	bagName, files, err := createBagContent(ctx, cfg, httpClient, obj)
 	if err != nil {
-		fmt.Printf("ERROR: creating bag content for ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("creating bag content for ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	// register the incoming submission
-	resp, err := registerSubmission(cfg, httpClient, bagName)
+	resp, err := registerSubmission(ctx, cfg, httpClient, bagName)
 	if err != nil {
-		fmt.Printf("ERROR: registering APTrust submission (%s)\n", err.Error())
+		libracommon.LogError("registering APTrust submission (%s)", err.Error())
 		return err
 	}
 
 	// init the S3 client
-	s3, err := newS3Client()
+	s3, err := deps.Objects()
 	if err != nil {
-		fmt.Printf("ERROR: creating S3 client (%s)\n", err.Error())
+		libracommon.LogError("creating S3 client (%s)", err.Error())
 		return err
 	}
 
 	// upload to S3
-	err = uploadContent(cfg, s3, resp.DepositBucket, resp.DepositPath, bagName, files)
+	err = uploadContent(ctx, cfg, s3, resp.DepositBucket, resp.DepositPath, bagName, files)
 	if err != nil {
 		return err
 	}
 
 	// initiate the submission
-	err = initiateSubmission(cfg, httpClient, resp.SubmissionIdentifier, bagName)
+	err = initiateSubmission(ctx, cfg, httpClient, resp.SubmissionIdentifier, bagName)
 	if err != nil {
-		fmt.Printf("ERROR: initiating APTrust submission (%s)\n", err.Error())
+		libracommon.LogError("initiating APTrust submission (%s)", err.Error())
 		return err
 	}
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
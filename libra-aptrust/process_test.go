@@ -0,0 +1,204 @@
+//
+// Process() end to end against the fakes, the APTrust submission and audit services are an
+// httptest service
+//
+
+package libraaptrust
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	librametadata "github.com/uvalib/libra-metadata"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// setupProcess replaces the dependencies with fakes and configures Process() to use the service
+func setupProcess(t *testing.T) (*fakes.Dependencies, *fakes.HttpService) {
+
+	fd := fakes.New()
+	saved := deps
+	deps = fd.Deps()
+	t.Cleanup(func() { deps = saved })
+	libracommon.SetMetricsSink(fakes.NewMetricsSink())
+
+	svc := fakes.NewHttpService()
+	t.Cleanup(svc.Close)
+
+	t.Setenv("APT_REGISTER_URL", svc.URL+"/register")
+	t.Setenv("APT_SUBMIT_URL", svc.URL+"/submit")
+	t.Setenv("APT_CLIENT_ID", "libra")
+	t.Setenv("ES_PROXY_URL", "http://easystore.example.edu")
+	t.Setenv("AUDIT_QUERY_TEMPLATE", svc.URL+"/audits/{:ns}/{:oid}")
+	t.Setenv("BAG_NAME_TEMPLATE", "libra-{:oid}")
+	t.Setenv("SCRATCH_FS", t.TempDir())
+	return fd, svc
+}
+
+// addWork adds a work with a file to the easystore with the extra fields
+func addWork(t *testing.T, fd *fakes.Dependencies, id string, extra map[string]string) {
+
+	work := librametadata.ETDWork{
+		Title:    "A Study of Things",
+		Degree:   "PHD (Doctor of Philosophy)",
+		Abstract: "Things were studied",
+		Author:   librametadata.ContributorData{ComputeID: "ab1c", FirstName: "Ann", LastName: "Bell"},
+	}
+	pl, err := work.Payload()
+	if err != nil {
+		t.Fatalf("serializing work (%s)", err.Error())
+	}
+
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["draft"] = "false"
+	fields["doi"] = "https://doi.org/10.5072/abc"
+	fields["publish-date"] = "2024-05-01T10:00:00Z"
+	for k, v := range extra {
+		fields[k] = v
+	}
+	obj.SetFields(fields)
+	obj.SetMetadata(uvaeasystore.NewEasyStoreMetadata(work.MimeType(), pl))
+	obj.SetFiles([]uvaeasystore.EasyStoreBlob{uvaeasystore.NewEasyStoreBlob("thesis.pdf", "application/pdf", []byte("%PDF-1.4 thesis"))})
+	fd.Easystore.Add(obj)
+}
+
+func busEvent(t *testing.T, name string, id string) json.RawMessage {
+	ev := uvalibrabus.UvaBusEvent{EventName: name, Namespace: libracommon.LibraEtdNamespace, Identifier: id}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	return pl
+}
+
+// requestsTo returns the requests made to the path
+func requestsTo(svc *fakes.HttpService, path string) []fakes.HttpRequest {
+	found := make([]fakes.HttpRequest, 0)
+	for _, r := range svc.Requests() {
+		if r.Path == path {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func TestProcessSubmitsBag(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("POST", "/register", 200, `{"sid":"sub-1","bucket":"deposit","path":"incoming"}`)
+	svc.Respond("POST", "/submit", 200, `{"submission":"sub-1","status":"submitted"}`)
+	svc.Respond("GET", "/audits/libraetd/oid:work1", 200, `[{"field":"title"}]`)
+	addWork(t, fd, "oid:work1", nil)
+
+	err := Process(context.Background(), "msg-1", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work1"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	// the bag contents are uploaded to the deposit location
+	prefix := "incoming/libra-oid:work1/"
+	for _, name := range []string{metadataFilename, titleFileName, descriptionFileName, dataciteFilename, fieldsFilename, "thesis.pdf", auditFilename, manifestFilename} {
+		if fd.Objects.Get("deposit", prefix+name) == nil {
+			t.Errorf("expected %s to be uploaded", name)
+		}
+	}
+	if string(fd.Objects.Get("deposit", prefix+"thesis.pdf")) != "%PDF-1.4 thesis" {
+		t.Errorf("unexpected file content")
+	}
+	xml := string(fd.Objects.Get("deposit", prefix+dataciteFilename))
+	if strings.Contains(xml, `<identifier identifierType="DOI">10.5072/abc</identifier>`) == false {
+		t.Errorf("expected the Datacite XML to identify the DOI, got %s", xml)
+	}
+	if strings.Contains(xml, `<resourceType resourceTypeGeneral="Text">Dissertation</resourceType>`) == false {
+		t.Errorf("expected the resource type from the table, got %s", xml)
+	}
+	manifest := string(fd.Objects.Get("deposit", prefix+manifestFilename))
+	if strings.Contains(manifest, " thesis.pdf\n") == false || strings.Contains(manifest, " "+auditFilename+"\n") == false {
+		t.Errorf("expected the manifest to list the bag files, got %s", manifest)
+	}
+
+	// and the submission names the bag
+	submitted := requestsTo(svc, "/submit")
+	if len(submitted) != 1 {
+		t.Fatalf("expected one submission, got %d", len(submitted))
+	}
+	var req SubmitInitiateRequest
+	_ = json.Unmarshal(submitted[0].Body, &req)
+	if req.ClientIdentifier != "libra" || req.SubmissionIdentifier != "sub-1" || len(req.BagFolders) != 1 || req.BagFolders[0] != "libra-oid:work1" {
+		t.Errorf("unexpected submission %+v", req)
+	}
+}
+
+func TestProcessIgnoresDrafts(t *testing.T) {
+	fd, svc := setupProcess(t)
+	addWork(t, fd, "oid:work2", map[string]string{"draft": "true"})
+
+	err := Process(context.Background(), "msg-2", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work2"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if len(svc.Requests()) != 0 {
+		t.Errorf("expected no service requests, got %+v", svc.Requests())
+	}
+}
+
+func TestProcessMissingWorkIsPermanent(t *testing.T) {
+	_, svc := setupProcess(t)
+
+	err := Process(context.Background(), "msg-3", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:gone"))
+	if err == nil || libracommon.IsPermanent(err) == false {
+		t.Fatalf("expected a permanent failure, got %v", err)
+	}
+	if len(svc.Requests()) != 0 {
+		t.Errorf("expected no service requests, got %+v", svc.Requests())
+	}
+}
+
+func TestProcessRegisterRejectionUploadsNothing(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("POST", "/register", 400, `{"error":"unknown client"}`)
+	addWork(t, fd, "oid:work4", nil)
+
+	err := Process(context.Background(), "msg-4", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work4"))
+	if err == nil || libracommon.IsPermanent(err) == false {
+		t.Fatalf("expected a permanent failure, got %v", err)
+	}
+	if fd.Objects.Get("deposit", "incoming/libra-oid:work4/"+metadataFilename) != nil {
+		t.Errorf("expected nothing to be uploaded")
+	}
+	if len(requestsTo(svc, "/submit")) != 0 {
+		t.Errorf("expected no submission")
+	}
+}
+
+func TestProcessIdempotent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("POST", "/register", 200, `{"sid":"sub-5","bucket":"deposit","path":"incoming"}`)
+	svc.Respond("POST", "/submit", 200, `{"submission":"sub-5","status":"submitted"}`)
+	addWork(t, fd, "oid:work5", nil)
+
+	idempotent := libracommon.Idempotent(deps, Process)
+	for range 2 {
+		err := idempotent(context.Background(), "msg-5", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work5"))
+		if err != nil {
+			t.Fatalf("expected success, got %s", err.Error())
+		}
+	}
+
+	if submitted := requestsTo(svc, "/submit"); len(submitted) != 1 {
+		t.Errorf("expected the repeated event to be skipped, got %d submissions", len(submitted))
+	}
+	records := fd.Idempotency.Records()
+	if len(records) != 1 || records[0].Outcome != libracommon.IdempotencyProcessed {
+		t.Errorf("expected one processed ledger record, got %+v", records)
+	}
+}
+
+//
+// end of file
+//
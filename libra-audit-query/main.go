@@ -8,9 +8,10 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"github.com/aws/aws-lambda-go/events"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"os"
 )
 
@@ -28,7 +29,7 @@ func main() {
 	flag.Parse()
 
 	if (len(namespace) == 0 || len(objectId) == 0) && len(who) == 0 {
-		fmt.Printf("ERROR: incorrect commandline, use --help for details\n")
+		libracommon.LogError("incorrect commandline, use --help for details")
 		os.Exit(1)
 	}
 
@@ -41,14 +42,14 @@ func main() {
 		req.QueryStringParameters["oid"] = objectId
 	}
 
-	resp, err := process(messageId, "api.gateway", req)
+	resp, err := process(context.Background(), messageId, "api.gateway", req)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		libracommon.LogError("%s", err.Error())
 		os.Exit(1)
 	}
 
-	fmt.Printf("INFO: response: %s\n", resp.Body)
-	fmt.Printf("INFO: terminating with HTTP %d\n", resp.StatusCode)
+	libracommon.LogInfo("response: %s", resp.Body)
+	libracommon.LogInfo("terminating with HTTP %d", resp.StatusCode)
 }
 
 //
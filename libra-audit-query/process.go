@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	_ "github.com/lib/pq"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 type QueriedAudit struct {
@@ -25,12 +27,12 @@ type QueriedAudit struct {
 	EventTime *time.Time `json:"eventTime"`
 }
 
-func process(messageId string, messageSrc string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func process(ctx context.Context, messageId string, messageSrc string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
 	query_params := make([]string, 0, len(request.QueryStringParameters))
 	// log inbound query parameters
 	for key, value := range request.QueryStringParameters {
-		fmt.Printf("DEBUG: query param [%s] = [%s]\n", key, value)
+		libracommon.LogDebug("query param [%s] = [%s]", key, value)
 		if key == "objid" {
 			temp_key := "oid"
 			query_params = append(query_params, temp_key, value)
@@ -41,7 +43,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	// log inbound headers
 	for key, value := range request.Headers {
-		fmt.Printf("DEBUG: header [%s] = [%s]\n", key, value)
+		libracommon.LogDebug("header [%s] = [%s]", key, value)
 	}
 
 	// load configuration
@@ -55,7 +57,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	db, err := sql.Open("postgres", connectionStr)
 	if err != nil {
-		fmt.Printf("ERROR: unable to open database (%s)\n", err.Error())
+		libracommon.LogError("unable to open database (%s)", err.Error())
 		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 	}
 
@@ -75,7 +77,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 		rows, err := db.Query("SELECT who, oid, namespace, field_name, before, after, event_time FROM audits where who = $1 ORDER BY event_time desc",
 			query_params[1])
 		if err != nil {
-			fmt.Printf("ERROR: query failed (%s)\n", err.Error())
+			libracommon.LogError("query failed (%s)", err.Error())
 			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 		}
 		defer rows.Close()
@@ -85,21 +87,21 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 			var currentAudit QueriedAudit
 			if err := rows.Scan(&currentAudit.Who, &currentAudit.Oid, &currentAudit.Namespace, &currentAudit.FieldName,
 				&currentAudit.Before, &currentAudit.After, &currentAudit.EventTime); err != nil {
-				fmt.Printf("ERROR: rows.Scan() failed (%s)\n", err.Error())
+				libracommon.LogError("rows.Scan() failed (%s)", err.Error())
 				return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 			}
 			audits = append(audits, currentAudit)
 		}
 		b_response, err := json.Marshal(audits)
 		if err != nil {
-			fmt.Printf("ERROR: json.Marshal() failed (%s)\n", err.Error())
+			libracommon.LogError("json.Marshal() failed (%s)", err.Error())
 			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 		}
 		status := http.StatusOK
 		if len(audits) == 0 {
 			status = http.StatusNotFound
 		}
-		fmt.Printf("INFO: returning %d row(s)\n", len(audits))
+		libracommon.LogInfo("returning %d row(s)", len(audits))
 		return events.APIGatewayProxyResponse{Body: string(b_response), StatusCode: status}, nil
 	}
 
@@ -107,7 +109,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 		rows, err := db.Query("SELECT who, oid, namespace, field_name, before, after, event_time FROM audits where namespace = $1 and oid = $2 ORDER BY event_time desc",
 			query_params[1], query_params[3])
 		if err != nil {
-			fmt.Printf("ERROR: query failed (%s)\n", err.Error())
+			libracommon.LogError("query failed (%s)", err.Error())
 			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 		}
 		defer rows.Close()
@@ -117,21 +119,21 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 			var currentAudit QueriedAudit
 			if err := rows.Scan(&currentAudit.Who, &currentAudit.Oid, &currentAudit.Namespace, &currentAudit.FieldName,
 				&currentAudit.Before, &currentAudit.After, &currentAudit.EventTime); err != nil {
-				fmt.Printf("ERROR: rows.Scan() failed (%s)\n", err.Error())
+				libracommon.LogError("rows.Scan() failed (%s)", err.Error())
 				return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 			}
 			audits = append(audits, currentAudit)
 		}
 		b_response, err := json.Marshal(audits)
 		if err != nil {
-			fmt.Printf("ERROR: json.Marshal() failed (%s)\n", err.Error())
+			libracommon.LogError("json.Marshal() failed (%s)", err.Error())
 			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 		}
 		status := http.StatusOK
 		if len(audits) == 0 {
 			status = http.StatusNotFound
 		}
-		fmt.Printf("INFO: returning %d row(s)\n", len(audits))
+		libracommon.LogInfo("returning %d row(s)", len(audits))
 		return events.APIGatewayProxyResponse{Body: string(b_response), StatusCode: status}, nil
 	}
 
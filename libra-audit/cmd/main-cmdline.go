@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libraaudit "github.com/uvalib/libra-audit"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.RunCmdline(libraaudit.Process)
+}
+
+//
+// end of file
+//
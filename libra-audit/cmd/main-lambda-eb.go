@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an EventBridge triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraaudit "github.com/uvalib/libra-audit"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartEventBridgeLambda(libraaudit.Process)
+}
+
+//
+// end of file
+//
@@ -2,36 +2,39 @@
 // main message processing
 //
 
-package main
+package libraaudit
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	_ "github.com/lib/pq"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	audit, err := uvalibrabus.MakeAuditEvent(ev.Detail)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra audit event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra audit event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: Audit %v\n", audit)
+	libracommon.LogInfo("Audit %v", audit)
 
 	// load configuration
 	cfg, err := loadConfiguration()
@@ -44,7 +47,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 	db, err := sql.Open("postgres", connectionStr)
 	if err != nil {
-		fmt.Printf("ERROR: unable to open database %s\n", err.Error())
+		libracommon.LogError("unable to open database %s", err.Error())
 		return err
 	}
 	// cleanup
@@ -52,7 +55,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 	parsedEventTime, err := time.Parse(time.RFC3339, ev.EventTime)
 	if err != nil {
-		fmt.Printf("ERROR: unable to parse event time %s\n", err.Error())
+		libracommon.LogError("unable to parse event time %s", err.Error())
 		return err
 	}
 
@@ -67,20 +70,20 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	)
 
 	if err != nil {
-		fmt.Printf("ERROR: db insert %s", err)
+		libracommon.LogError("db insert %s", err)
 		return err
 	}
 
 	n, err := result.RowsAffected()
 	if err != nil {
-		fmt.Printf("ERROR: rows affected %s", err)
+		libracommon.LogError("rows affected %s", err)
 		return err
 	}
 
-	fmt.Printf("INFO: Inserted %d row\n", n)
+	libracommon.LogInfo("Inserted %d row", n)
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
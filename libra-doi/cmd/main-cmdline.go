@@ -0,0 +1,23 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	"flag"
+
+	libradoi "github.com/uvalib/libra-doi"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	flag.BoolVar(&libradoi.DryRun, "dryrun", false, "Show what would change in Datacite without sending or saving anything")
+	libracommon.RunCmdline(libradoi.Process)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libradoi "github.com/uvalib/libra-doi"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libracommon.Idempotent(libradoi.Deps(), libradoi.Process))
+}
+
+//
+// end of file
+//
@@ -1,62 +1,72 @@
-package main
+package libradoi
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 	IDService  IDServiceConfig
-	DOIBaseURL string // base url for DOIs
+	DOIBaseURL string `env:"DOI_BASE_URL" required:"true"` // base url for DOIs
 
-	PublicURLBase     string // Base URL for public pages
-	ETDPublicShoulder string
+	PublicURLBase     string `env:"PUBLIC_URL_BASE" required:"true"` // Base URL for public pages
+	ETDPublicShoulder string `env:"ETD_PUBLIC_SHOULDER" required:"true"`
+	OAPublicShoulder  string `env:"OA_PUBLIC_SHOULDER"` // open access works are supported when set
 
 	ETDNamespace Namespace
-
-	ResourceTypes []ResourceType
+	OANamespace  Namespace
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
-	BusName string // name of the bus
+	BusName string `env:"MESSAGE_BUS"` // name of the bus
 
-	OrcidGetDetailsURL string // URL for orcid-ws
+	OrcidGetDetailsURL string `env:"ORCID_GET_DETAILS_URL" required:"true"` // URL for orcid-ws
 	AuthToken          string
-	MintAuthURL        string
+	MintAuthURL        string `env:"MINT_AUTH_URL" required:"true"`
+
+	ReconcileRepair bool `env:"DOI_RECONCILE_REPAIR"` // reconciliation repairs discrepancies rather than only reporting them
 
-	httpClient *http.Client // shared http client
+	// ORCID resolution, lookups are cached across invocations when there is a TTL
+	OrcidConcurrency   int           `env:"ORCID_CONCURRENCY" default:"4"`
+	OrcidCacheTTL      time.Duration `env:"ORCID_CACHE_TTL" default:"0s"`
+	OrcidFailurePolicy string        `env:"ORCID_FAILURE_POLICY" default:"skip"` // skip or fail
+
+	// withdrawal of deleted works, {:id} in the tombstone URL is replaced by the work identifier
+	TombstoneURL     string `env:"TOMBSTONE_URL"` // deleted works cannot be withdrawn without it
+	WithdrawalReason string `env:"DOI_WITHDRAWAL_REASON" default:"This work has been withdrawn"`
+
+	httpClient *http.Client            // shared http client
+	orcidCache *libracommon.OrcidCache // ORCID lookups, created once per invocation
+	fileSizes  map[string]int64        // file sizes by url, created once per invocation
 }
 
 // Namespace such as libraetd
 type Namespace struct {
-	Name string
-	Path string
-}
-
-// ResourceType such as book, article, etc
-type ResourceType struct {
-	Value    string
-	Label    string
-	Category string
-	Oa       bool
-	Etd      bool
+	Name     string
+	Path     string
+	Shoulder string         // the public URL shoulder
+	build    payloadBuilder // builds the payload for works in the namespace
 }
 
 // IDServiceConfig for DOI service
 type IDServiceConfig struct {
-	BaseURL  string
-	Shoulder string
-	User     string
-	Password string
+	BaseURL  string `env:"ID_SERVICE_BASE" required:"true"`
+	Shoulder string `env:"ID_SERVICE_SHOULDER" required:"true"`
+	User     string `env:"ID_SERVICE_USER" required:"true"`
+	Password string `env:"ID_SERVICE_PASSWORD" required:"true" secret:"true"`
 }
 
 var cfg Config
 
+// the configuration is loaded by the first invocation and kept for the life of the lambda,
+// a load that fails is tried again by the next invocation
+var cfgLoaded = false
+
 // Cfg returns the global configuration
 func Cfg() Config {
 	return cfg
@@ -66,89 +76,41 @@ func Cfg() Config {
 // and return a pointer to it. Any failures are fatal.
 func loadConfiguration() (*Config, error) {
 
-	var err error
-
-	cfg.IDService.BaseURL, err = ensureSet("ID_SERVICE_BASE")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.IDService.Shoulder, err = ensureSet("ID_SERVICE_SHOULDER")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.IDService.User, err = ensureSet("ID_SERVICE_USER")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.IDService.Password, err = ensureSet("ID_SERVICE_PASSWORD")
-	if err != nil {
-		return nil, err
+	if cfgLoaded == true {
+		return &cfg, nil
 	}
 
-	cfg.DOIBaseURL, err = ensureSet("DOI_BASE_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	cfg.ETDNamespace = Namespace{
-		Name: libraEtdNamespace,
-		Path: "etd",
+		Name:     libracommon.LibraEtdNamespace,
+		Path:     "etd",
+		Shoulder: cfg.ETDPublicShoulder,
+		build:    buildETDPayload,
 	}
-
-	fmt.Printf("INFO: loading data/resourceTypes.json\n")
-
-	bytes, err := os.ReadFile("./data/resourceTypes.json")
-	if err != nil {
-		fmt.Printf("ERROR: unable to load resourceTypes: %s\n", err.Error())
-	} else {
-		err = json.Unmarshal(bytes, &cfg.ResourceTypes)
-		if err != nil {
-			fmt.Printf("ERROR: unable to parse resourceTypes.json: %s\n", err.Error())
-		}
+	cfg.OANamespace = Namespace{
+		Name:     libracommon.LibraOpenNamespace,
+		Path:     "oa",
+		Shoulder: cfg.OAPublicShoulder,
+		build:    buildOAPayload,
 	}
 
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
+	err = validateOrcidFailurePolicy(cfg.OrcidFailurePolicy)
 	if err != nil {
+		libracommon.LogError("%s", err.Error())
 		return nil, err
 	}
 
-	cfg.PublicURLBase, err = ensureSet("PUBLIC_URL_BASE")
-	if err != nil {
-		return nil, err
+	libracommon.PrintConfig(cfg)
+	libracommon.LogInfo("[conf] Public Libra ETD URL format = [%s/public/%s/id]", cfg.PublicURLBase, cfg.ETDPublicShoulder)
+	if len(cfg.OAPublicShoulder) != 0 {
+		libracommon.LogInfo("[conf] Public Libra Open URL format = [%s/public/%s/id]", cfg.PublicURLBase, cfg.OAPublicShoulder)
 	}
-	// Ensure this is quoted in the env file. Otherwise trailing zeros are lost.
-	cfg.ETDPublicShoulder, err = ensureSet("ETD_PUBLIC_SHOULDER")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.OrcidGetDetailsURL, err = ensureSetAndNonEmpty("ORCID_GET_DETAILS_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.MintAuthURL, err = ensureSetAndNonEmpty("MINT_AUTH_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] EsProxyUrl         = [%s]\n", cfg.EsProxyUrl)
-	fmt.Printf("[conf] DOIBaseURL         = [%s]\n", cfg.DOIBaseURL)
-	fmt.Printf("[conf] IDServiceBase      = [%s]\n", cfg.IDService.BaseURL)
-	fmt.Printf("[conf] IDServiceShoulder  = [%s]\n", cfg.IDService.Shoulder)
-	fmt.Printf("[conf] IDServiceUser      = [%s]\n", cfg.IDService.User)
-	fmt.Printf("[conf] IDServicePassword  = [REDACTED]\n")
-
-	fmt.Printf("[conf] ORCIDGetDetailsURL = [%s]\n", cfg.OrcidGetDetailsURL)
-	fmt.Printf("[conf] MintAuthURL        = [%s]\n", cfg.MintAuthURL)
-	fmt.Printf("[conf] BusName            = [%s]\n", cfg.BusName)
-
-	fmt.Printf("[conf] Public Libra ETD URL format = [%s/public/%s/id]\n", cfg.PublicURLBase, cfg.ETDPublicShoulder)
 
+	cfgLoaded = true
 	return &cfg, nil
 }
 
@@ -1,209 +1,69 @@
-package main
+package libradoi
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
-	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	librametadata "github.com/uvalib/libra-metadata"
 )
 
-// https://support.datacite.org/reference/introduction
+func createETDPayload(ctx context.Context, work *librametadata.ETDWork, fields uvaeasystore.EasyStoreObjectFields, files []uvaeasystore.EasyStoreBlob) (libracommon.DataciteData, error) {
+	var payload = libracommon.DataciteData{}
 
-type AffiliationData struct {
-	Name                        string `json:"name"`
-	SchemeURI                   string `json:"schemeUri,omitempty"`
-	AffiliationIdentifier       string `json:"affiliationIdentifier,omitempty"`
-	AffiliationIdentifierScheme string `json:"affiliationIdentifierScheme,omitempty"`
-}
-
-type TitleData struct {
-	Title string `json:"title"`
-	Lang  string `json:"lang,omitempty"`
-}
-type DescriptionData struct {
-	Description     string `json:"description"`
-	DescriptionType string `json:"descriptionType"`
-}
-type PersonData struct {
-	Name            string               `json:"name,omitempty"`
-	GivenName       string               `json:"givenName"`
-	FamilyName      string               `json:"familyName"`
-	NameType        string               `json:"nameType,omitempty"`
-	ContributorType string               `json:"contributorType,omitempty"`
-	Affiliation     []AffiliationData    `json:"affiliation,omitempty"`
-	NameIdentifiers []NameIdentifierData `json:"nameIdentifiers,omitempty"`
-}
-type NameIdentifierData struct {
-	SchemeURI            string `json:"schemeUri"`
-	NameIdentifier       string `json:"nameIdentifier"`
-	NameIdentifierScheme string `json:"nameIdentifierScheme"`
-}
-type SubjectData struct {
-	Subject string `json:"subject,omitempty"`
-}
-type RightsData struct {
-	Rights string `json:"rights,omitempty"`
-}
-type FundingData struct {
-	FunderName string `json:"funderName,omitempty"`
-}
-type TypeData struct {
-	ResourceType        string `json:"resourceType,omitempty"`
-	ResourceTypeGeneral string `json:"resourceTypeGeneral,omitempty"`
-}
-type DateData struct {
-	Date     string `json:"date"`
-	DateType string `json:"dateType"`
-}
-
-type PublisherData struct {
-	Name                      string `json:"name"`
-	SchemeURI                 string `json:"schemeUri,omitempty"`
-	PublisherIdentifier       string `json:"publisherIdentifier,omitempty"`
-	PublisherIdentifierScheme string `json:"publisherIdentifierScheme,omitempty"`
-}
-
-type AttributesData struct {
-	Event             string            `json:"event,omitempty"` // eg: publish
-	DOI               string            `json:"doi,omitempty"`   // Datacite generates a DOI when empty
-	Prefix            string            `json:"prefix"`
-	URL               string            `json:"url"`
-	Titles            []TitleData       `json:"titles"`
-	Descriptions      []DescriptionData `json:"descriptions,omitempty"`
-	Creators          []PersonData      `json:"creators,omitempty"`
-	Contributors      []PersonData      `json:"contributors,omitempty"`
-	Subjects          []SubjectData     `json:"subjects,omitempty"`
-	RightsList        []RightsData      `json:"rightsList,omitempty"`
-	FundingReferences []FundingData     `json:"fundingReferences,omitempty"`
-	Types             TypeData          `json:"types,omitempty"`
-	Dates             []DateData        `json:"dates,omitempty"`
-	PublicationYear   string            `json:"publicationYear,omitempty"`
-	Publisher         PublisherData     `json:"publisher"`
-}
-
-type DataciteData struct {
-	Data struct {
-		TypeName   string         `json:"type"`
-		Attributes AttributesData `json:"attributes"`
-	} `json:"data"`
-}
-
-// UVAAffiliation with ROR ID
-func UVAAffiliation() AffiliationData {
-	return AffiliationData{
-		Name:                        "University of Virginia",
-		SchemeURI:                   "https://ror.org",
-		AffiliationIdentifier:       "https://ror.org/0153tk833",
-		AffiliationIdentifierScheme: "ROR",
+	contributors := append([]librametadata.ContributorData{work.Author}, work.Advisors...)
+	orcids, err := resolveOrcids(ctx, contributors)
+	if err != nil {
+		return payload, err
 	}
-}
 
-// UVAPublisher with ROR ID
-func UVAPublisher() PublisherData {
-	return PublisherData{
-		Name:                      "University of Virginia",
-		PublisherIdentifier:       "https://ror.org/0153tk833",
-		SchemeURI:                 "https://ror.org",
-		PublisherIdentifierScheme: "ROR",
+	// the mapping is shared with preservation
+	attributes, err := libracommon.EtdDataciteAttributes(work, fields, orcids, deps.Now)
+	if err != nil {
+		return payload, libracommon.Permanent(err)
 	}
-}
+	attributes.DOI = shoulderDOI(fields["doi"])
+	attributes.Prefix = Cfg().IDService.Shoulder
 
-func createETDPayload(work *librametadata.ETDWork, fields uvaeasystore.EasyStoreObjectFields) DataciteData {
-	var payload = DataciteData{}
 	payload.Data.TypeName = "dois"
-	// remove http://doi... prefix
-	lastPath := regexp.MustCompile("[^/]+$")
-	suffix := lastPath.FindString(fields["doi"])
-	doi := ""
-	if len(suffix) > 0 {
-		doi = Cfg().IDService.Shoulder + "/" + suffix
-	}
-
-	payload.Data.Attributes = AttributesData{
-		DOI:               doi,
-		Prefix:            Cfg().IDService.Shoulder,
-		Titles:            []TitleData{{Title: work.Title}},
-		Descriptions:      getDescription(work.Abstract),
-		Creators:          []PersonData{getPerson(work.Author, "")},
-		Contributors:      getPersonList(work.Advisors, "RelatedPerson"),
-		Subjects:          getKeywords(work.Keywords),
-		RightsList:        getRights(work.License),
-		FundingReferences: getSponsors(work.Sponsors),
-
-		Types: TypeData{
-			ResourceTypeGeneral: "Text",
-			ResourceType:        "Dissertation",
-		},
-		Publisher: UVAPublisher(),
-	}
-	addDates(&payload, fields["publish-date"])
-	return payload
+	payload.Data.Attributes = attributes
+	addFiles(ctx, &payload, files)
+	addXML(&payload)
+	return payload, nil
 }
 
-func getDescription(abstract string) []DescriptionData {
-	if abstract == "" {
-		return []DescriptionData{}
-	}
-	return []DescriptionData{{
-		Description:     abstract,
-		DescriptionType: "Abstract",
-	}}
-}
-func getRights(rights string) []RightsData {
-	if rights == "" {
-		return []RightsData{}
+// shoulderDOI is the DOI in the doi field, a url, with our shoulder. Empty when there is none
+func shoulderDOI(doiField string) string {
+	// remove http://doi... prefix
+	lastPath := regexp.MustCompile("[^/]+$")
+	suffix := lastPath.FindString(doiField)
+	if len(suffix) == 0 {
+		return ""
 	}
-	return []RightsData{{Rights: rights}}
+	return Cfg().IDService.Shoulder + "/" + suffix
 }
 
-func addDates(payload *DataciteData, publishDate string) {
-	if len(publishDate) == 0 {
-		currentYear := time.Now().Year()
-		fmt.Printf("INFO: setting empty PublicationYear to: %d\n", currentYear)
-		payload.Data.Attributes.PublicationYear = fmt.Sprintf("%d", currentYear)
-		return
-	}
+func sendToDatacite(ctx context.Context, payload *libracommon.DataciteData) (string, error) {
+	var response []byte
+	var httpMethod, path string
 
-	parsedDate, err := time.Parse(time.RFC3339, publishDate)
+	// invalid payloads are never going to be accepted
+	err := validatePayload(payload)
 	if err != nil {
-		fmt.Printf("WARN: unable to parse publish date %s\n", err.Error())
-		return
-	}
-
-	payload.Data.Attributes.Dates = []DateData{{
-		Date:     parsedDate.Format("2006-01-02"),
-		DateType: "Issued",
-	}}
-	payload.Data.Attributes.PublicationYear = parsedDate.Format("2006")
-}
-
-func getSponsors(s []string) []FundingData {
-	fundingList := []FundingData{}
-	for _, sponsor := range s {
-		fundingList = append(fundingList, FundingData{FunderName: sponsor})
+		return "", err
 	}
-	return fundingList
-}
-
-func getKeywords(keywords []string) []SubjectData {
-	// Keywords are mapped to subjects
-	subjects := []SubjectData{}
-	for _, keyword := range keywords {
-		subjects = append(subjects, SubjectData{Subject: keyword})
+	if DryRun == true {
+		return "", ErrDryRun
 	}
-	return subjects
-
-}
-
-func sendToDatacite(payload *DataciteData) (string, error) {
-	var response []byte
-	var httpMethod, path string
 
 	if len(payload.Data.Attributes.DOI) == 0 {
 		// no DOI
@@ -221,9 +81,9 @@ func sendToDatacite(payload *DataciteData) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("INFO: JSON Payload to Datacite:\n%s\n", jsonPayload)
+	libracommon.LogInfo("JSON Payload to Datacite:\n%s", jsonPayload)
 
-	req, err := http.NewRequest(httpMethod, Cfg().IDService.BaseURL+path, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, httpMethod, Cfg().IDService.BaseURL+path, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return "", err
 	}
@@ -231,7 +91,7 @@ func sendToDatacite(payload *DataciteData) (string, error) {
 	req.Header.Add("accept", "application/json")
 	req.SetBasicAuth(Cfg().IDService.User, Cfg().IDService.Password)
 
-	response, err = httpSend(Cfg().httpClient, req)
+	response, err = libracommon.HttpSend(Cfg().httpClient, req)
 	if err != nil {
 		spew.Dump(req)
 		spew.Dump(response)
@@ -252,47 +112,125 @@ func sendToDatacite(payload *DataciteData) (string, error) {
 	return responseData.Data.ID, nil
 }
 
-func getPersonList(contributors []librametadata.ContributorData, typeName string) []PersonData {
-	var personList []PersonData
-	for _, person := range contributors {
-		if len(person.FirstName) > 0 || len(person.LastName) > 0 {
-			personList = append(personList, getPerson(person, typeName))
-		}
+// DataciteRecord is the part of a registered DOI that we reconcile against
+type DataciteRecord struct {
+	Data DataciteRecordData `json:"data"`
+}
+
+// DataciteSearchResults is a page of DOI search results
+type DataciteSearchResults struct {
+	Data []DataciteRecordData `json:"data"`
+}
+
+type DataciteRecordData struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		State    string                   `json:"state"` // draft, registered or findable
+		URL      string                   `json:"url"`
+		Titles   []libracommon.TitleData  `json:"titles"`
+		Creators []libracommon.PersonData `json:"creators"`
+		Dates    []libracommon.DateData   `json:"dates"`
+	} `json:"attributes"`
+}
+
+// getFromDatacite gets the DOI record, doi format should be: 10.18130/xxxx
+func getFromDatacite(ctx context.Context, doi string) (*DataciteRecord, error) {
+
+	response, err := dataciteRequest(ctx, "GET", fmt.Sprintf("/dois/%s", doi), nil)
+	if err != nil {
+		return nil, err
 	}
-	return personList
+
+	var record DataciteRecord
+	err = json.Unmarshal(response, &record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
 }
 
-func getPerson(contributor librametadata.ContributorData, contribType string) PersonData {
-	var person PersonData
-	person.GivenName = contributor.FirstName
-	person.FamilyName = contributor.LastName
-	if len(person.GivenName) > 0 && len(person.FamilyName) > 0 {
-		person.Name = fmt.Sprintf("%s, %s", person.FamilyName, person.GivenName)
+// findInDatacite finds the DOIs that resolve to the url, in any state
+func findInDatacite(ctx context.Context, doiURL string) ([]DataciteRecordData, error) {
+
+	query := url.Values{}
+	query.Set("query", fmt.Sprintf("url:\"%s\"", doiURL))
+	response, err := dataciteRequest(ctx, "GET", "/dois?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
 	}
-	person.ContributorType = contribType
-	person.NameType = "Personal"
-	if len(contributor.ComputeID) > 0 {
-		person.Affiliation = []AffiliationData{UVAAffiliation()}
-	} else {
-		person.Affiliation = []AffiliationData{{Name: contributor.Institution}}
+
+	var results DataciteSearchResults
+	err = json.Unmarshal(response, &results)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check for ORCID Account
-	if contributor.ComputeID != "" {
-		orcid, err := getOrcidDetails(Cfg().OrcidGetDetailsURL, contributor.ComputeID, Cfg().AuthToken, Cfg().httpClient)
-		if err != nil {
-			fmt.Printf("WARNING: unable to get ORCID details for %s\n", contributor.ComputeID)
+	// the search is not exact so check the matches
+	found := make([]DataciteRecordData, 0)
+	for _, r := range results.Data {
+		if r.Attributes.URL == doiURL {
+			found = append(found, r)
 		}
+	}
+	return found, nil
+}
 
-		if len(orcid) > 0 {
-			person.NameIdentifiers = []NameIdentifierData{{
-				NameIdentifier:       orcid,
-				SchemeURI:            "https://orcid.org",
-				NameIdentifierScheme: "ORCID",
-			}}
+// deleteFromDatacite deletes a DOI, only draft DOIs can be deleted
+func deleteFromDatacite(ctx context.Context, doi string) error {
+	_, err := dataciteRequest(ctx, "DELETE", fmt.Sprintf("/dois/%s", doi), nil)
+	return err
+}
+
+// updateDataciteAttributes updates only the supplied attributes of a DOI
+func updateDataciteAttributes(ctx context.Context, doi string, attributes map[string]any) error {
+
+	payload := map[string]any{
+		"data": map[string]any{
+			"type":       "dois",
+			"attributes": attributes,
+		},
+	}
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	libracommon.LogInfo("JSON Payload to Datacite:\n%s", jsonPayload)
+
+	_, err = dataciteRequest(ctx, "PUT", fmt.Sprintf("/dois/%s", doi), jsonPayload)
+	return err
+}
+
+// issue an authenticated request to the Datacite API
+func dataciteRequest(ctx context.Context, method string, path string, body []byte) ([]byte, error) {
+
+	// a dry run only reads
+	if DryRun == true && method != http.MethodGet {
+		return nil, ErrDryRun
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, Cfg().IDService.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Add("content-type", "application/vnd.api+json")
+	}
+	req.Header.Add("accept", "application/vnd.api+json")
+	req.SetBasicAuth(Cfg().IDService.User, Cfg().IDService.Password)
+
+	return libracommon.HttpSend(Cfg().httpClient, req)
+}
+
+// addXML adds the payload rendered as base64 encoded Datacite XML, none if the work has no DOI yet
+func addXML(payload *libracommon.DataciteData) {
+	buf, err := libracommon.RenderDataciteXML(&payload.Data.Attributes)
+	if err != nil {
+		if errors.Is(err, libracommon.ErrNoDOI) == false {
+			libracommon.LogWarning("unable to render Datacite XML (%s)", err.Error())
 		}
+		return
 	}
-	return person
+	payload.Data.Attributes.XML = base64.StdEncoding.EncodeToString(buf)
 }
 
 //
@@ -0,0 +1,131 @@
+//
+// dry run, shows what a sync would change in Datacite without sending anything
+//
+
+package libradoi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// DryRun is set by the cmdline build, nothing is sent to Datacite or written to easystore
+var DryRun = false
+
+// returned by anything that would change Datacite during a dry run
+var ErrDryRun = fmt.Errorf("dry run, Datacite is not changed")
+
+// dryRunDiff prints the difference between the current Datacite record and the payload
+func dryRunDiff(ctx context.Context, payload *libracommon.DataciteData) error {
+
+	var record *DataciteRecord
+	doi := payload.Data.Attributes.DOI
+	if len(doi) != 0 {
+		var err error
+		record, err = getFromDatacite(ctx, doi)
+		if err != nil {
+			if libracommon.IsHttpNotFound(err) == false {
+				libracommon.LogError("getting DOI %s from Datacite (%s)", doi, err.Error())
+				return err
+			}
+			record = nil
+		}
+	}
+
+	printPayloadDiff(os.Stdout, payload, record)
+	return nil
+}
+
+// printPayloadDiff writes a field level diff, record is nil when the DOI is not registered
+func printPayloadDiff(w io.Writer, payload *libracommon.DataciteData, record *DataciteRecord) {
+
+	expected := payload.Data.Attributes
+	var actual DataciteRecordData
+	if record != nil {
+		actual = record.Data
+	}
+
+	switch {
+	case len(expected.DOI) == 0:
+		fmt.Fprintf(w, "DRY RUN: a new DOI would be minted for %s\n", expected.URL)
+	case record == nil:
+		fmt.Fprintf(w, "DRY RUN: DOI %s is not registered with Datacite and would be created\n", expected.DOI)
+	default:
+		fmt.Fprintf(w, "DRY RUN: changes to DOI %s\n", expected.DOI)
+	}
+
+	event := expected.Event
+	if len(event) == 0 {
+		event = "(none)"
+	}
+	fmt.Fprintf(w, "  %-9s %s\n", "event:", event)
+	printDiffLine(w, "state", []string{actual.Attributes.State}, []string{eventState(expected.Event, actual.Attributes.State)})
+	printDiffLine(w, "url", []string{actual.Attributes.URL}, []string{expected.URL})
+	printDiffLine(w, "titles", titles(actual.Attributes.Titles), titles(expected.Titles))
+	printDiffLine(w, "creators", personNames(actual.Attributes.Creators), personNames(expected.Creators))
+	printDiffLine(w, "dates", dateValues(actual.Attributes.Dates), dateValues(expected.Dates))
+
+	err := validatePayload(payload)
+	if err != nil {
+		fmt.Fprintf(w, "  the payload would be rejected: %s\n", err.Error())
+	}
+}
+
+func printDiffLine(w io.Writer, name string, before []string, after []string) {
+	label := name + ":"
+	if slices.Equal(before, after) == true {
+		fmt.Fprintf(w, "  %-9s unchanged %s\n", label, diffValue(after))
+		return
+	}
+	fmt.Fprintf(w, "  %-9s %s -> %s\n", label, diffValue(before), diffValue(after))
+}
+
+func diffValue(values []string) string {
+	values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return len(v) == 0 })
+	if len(values) == 0 {
+		return "(none)"
+	}
+	return "[" + strings.Join(values, "; ") + "]"
+}
+
+// printWithdrawal shows what withdrawing the DOI would do
+func printWithdrawal(w io.Writer, r DataciteRecordData, tombstone string, reason string) {
+	if r.Attributes.State == doiStateDraft {
+		fmt.Fprintf(w, "DRY RUN: draft DOI %s would be deleted\n", r.ID)
+		return
+	}
+	fmt.Fprintf(w, "DRY RUN: %s DOI %s would be withdrawn (%s)\n", r.Attributes.State, r.ID, reason)
+	printDiffLine(w, "url", []string{r.Attributes.URL}, []string{tombstone})
+}
+
+// eventState is the state the DOI is in after the event, https://support.datacite.org/docs/doi-states
+func eventState(event string, current string) string {
+	switch event {
+	case "publish":
+		return doiStateFindable
+	case "register", "hide":
+		return "registered"
+	}
+	if len(current) == 0 {
+		return doiStateDraft
+	}
+	return current
+}
+
+func dateValues(list []libracommon.DateData) []string {
+	values := make([]string, 0, len(list))
+	for _, d := range list {
+		values = append(values, d.DateType+" "+d.Date)
+	}
+	return values
+}
+
+//
+// end of file
+//
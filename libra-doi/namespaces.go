@@ -0,0 +1,70 @@
+//
+// namespaces, each namespace has its own public URL shoulder and payload builder
+//
+
+package libradoi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librametadata "github.com/uvalib/libra-metadata"
+)
+
+// works without a title are not given a DOI
+var ErrBlankTitle = fmt.Errorf("title is blank")
+
+// payloadBuilder creates the Datacite payload from the metadata of a work in the namespace
+type payloadBuilder func(ctx context.Context, mdBytes []byte, fields uvaeasystore.EasyStoreObjectFields, files []uvaeasystore.EasyStoreBlob) (libracommon.DataciteData, error)
+
+// isDoiNamespace reports whether works in the namespace can have a DOI, before any
+// configuration is needed
+func isDoiNamespace(name string) bool {
+	return name == libracommon.LibraEtdNamespace || name == libracommon.LibraOpenNamespace
+}
+
+// getNamespace returns the configured namespace, namespaces without a public URL shoulder
+// are not supported
+func getNamespace(name string) (Namespace, bool) {
+	for _, ns := range []Namespace{Cfg().ETDNamespace, Cfg().OANamespace} {
+		if ns.Name == name && len(ns.Shoulder) != 0 && ns.build != nil {
+			return ns, true
+		}
+	}
+	return Namespace{}, false
+}
+
+// publicURL is the public page for a work, the URL the DOI resolves to
+func publicURL(ns Namespace, identifier string) string {
+	return fmt.Sprintf("%s/%s/%s", Cfg().PublicURLBase, ns.Shoulder, identifier)
+}
+
+func buildETDPayload(ctx context.Context, mdBytes []byte, fields uvaeasystore.EasyStoreObjectFields, files []uvaeasystore.EasyStoreBlob) (libracommon.DataciteData, error) {
+	work, err := librametadata.ETDWorkFromBytes(mdBytes)
+	if err != nil {
+		libracommon.LogError("unable to process ETD Work %s", err.Error())
+		return libracommon.DataciteData{}, libracommon.Permanent(err)
+	}
+	if len(work.Title) == 0 {
+		return libracommon.DataciteData{}, ErrBlankTitle
+	}
+	return createETDPayload(ctx, work, fields, files)
+}
+
+func buildOAPayload(ctx context.Context, mdBytes []byte, fields uvaeasystore.EasyStoreObjectFields, files []uvaeasystore.EasyStoreBlob) (libracommon.DataciteData, error) {
+	work, err := oaWorkFromBytes(mdBytes)
+	if err != nil {
+		libracommon.LogError("unable to process OA Work %s", err.Error())
+		return libracommon.DataciteData{}, libracommon.Permanent(err)
+	}
+	if len(work.Title) == 0 {
+		return libracommon.DataciteData{}, ErrBlankTitle
+	}
+	return createOAPayload(ctx, work, fields, files)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,124 @@
+//
+// open access (Libra Open) works
+//
+
+package libradoi
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librametadata "github.com/uvalib/libra-metadata"
+)
+
+// OAWork is the Libra Open metadata. The shared metadata package only defines ETD works
+// so the fields we need are declared here
+type OAWork struct {
+	ResourceType    string                          `json:"resourceType"`
+	Title           string                          `json:"title"`
+	Authors         []librametadata.ContributorData `json:"authors"`
+	Abstract        string                          `json:"abstract"`
+	License         string                          `json:"license"`
+	LicenseURL      string                          `json:"licenseURL"`
+	Languages       []string                        `json:"languages"`
+	Keywords        []string                        `json:"keywords"`
+	Contributors    []librametadata.ContributorData `json:"contributors"`
+	Publisher       string                          `json:"publisher"`
+	Citation        string                          `json:"citation"`
+	PublicationDate string                          `json:"pubDate"`
+	RelatedURLs     []string                        `json:"relatedURLs"`
+	Sponsors        []string                        `json:"sponsors"`
+	Notes           string                          `json:"notes"`
+}
+
+func oaWorkFromBytes(buf []byte) (*OAWork, error) {
+	var work OAWork
+	err := json.Unmarshal(buf, &work)
+	if err != nil {
+		return nil, err
+	}
+	return &work, nil
+}
+
+func createOAPayload(ctx context.Context, work *OAWork, fields uvaeasystore.EasyStoreObjectFields, files []uvaeasystore.EasyStoreBlob) (libracommon.DataciteData, error) {
+	var payload = libracommon.DataciteData{}
+
+	types, err := libracommon.DataciteTypes(work.ResourceType, true)
+	if err != nil {
+		return payload, libracommon.Permanent(err)
+	}
+
+	contributors := append(append([]librametadata.ContributorData{}, work.Authors...), work.Contributors...)
+	orcids, err := resolveOrcids(ctx, contributors)
+	if err != nil {
+		return payload, err
+	}
+
+	payload.Data.TypeName = "dois"
+	publisher := libracommon.UVAPublisher()
+	if len(strings.TrimSpace(work.Publisher)) != 0 {
+		publisher = libracommon.PublisherData{Name: strings.TrimSpace(work.Publisher)}
+	}
+
+	language := ""
+	if len(work.Languages) != 0 {
+		language = libracommon.DataciteLanguage(work.Languages[0])
+	}
+
+	descriptions := libracommon.DataciteDescriptions(work.Abstract)
+	if len(work.Citation) != 0 {
+		descriptions = append(descriptions, libracommon.DescriptionData{Description: work.Citation, DescriptionType: "Other"})
+	}
+
+	payload.Data.Attributes = libracommon.AttributesData{
+		DOI:               shoulderDOI(fields["doi"]),
+		Prefix:            Cfg().IDService.Shoulder,
+		Titles:            []libracommon.TitleData{{Title: work.Title}},
+		Descriptions:      descriptions,
+		Creators:          libracommon.DatacitePeople(work.Authors, "", orcids),
+		Contributors:      libracommon.DatacitePeople(work.Contributors, "Other", orcids),
+		Subjects:          libracommon.DataciteSubjects(work.Keywords),
+		RightsList:        libracommon.DataciteRights(work.License, work.LicenseURL),
+		FundingReferences: libracommon.DataciteFunding(work.Sponsors),
+
+		Types:     types,
+		Publisher: publisher,
+
+		Language:             language,
+		RelatedIdentifiers:   libracommon.DataciteRelatedIdentifiers(work.RelatedURLs),
+		AlternateIdentifiers: libracommon.DataciteAlternateIdentifiers(fields["source-id"]),
+	}
+	payload.Data.Attributes.SetPublishDate(fields["publish-date"], deps.Now)
+	addPublicationDate(&payload, work.PublicationDate)
+	addFiles(ctx, &payload, files)
+	addXML(&payload)
+	return payload, nil
+}
+
+// addPublicationDate uses the date the work was originally published (often elsewhere) as the
+// issued date, the Libra publish date becomes the available date
+func addPublicationDate(payload *libracommon.DataciteData, pubDate string) {
+	pubDate = strings.TrimSpace(pubDate)
+	if len(pubDate) == 0 {
+		return
+	}
+	if validDate(pubDate) == false {
+		libracommon.LogWarning("unable to parse publication date %s, ignoring", pubDate)
+		return
+	}
+
+	for i := range payload.Data.Attributes.Dates {
+		if payload.Data.Attributes.Dates[i].DateType == "Issued" {
+			payload.Data.Attributes.Dates[i].DateType = "Available"
+		}
+	}
+	payload.Data.Attributes.Dates = append(payload.Data.Attributes.Dates, libracommon.DateData{Date: pubDate, DateType: "Issued"})
+	payload.Data.Attributes.PublicationYear = pubDate[:4]
+}
+
+//
+// end of file
+//
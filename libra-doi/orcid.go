@@ -0,0 +1,103 @@
+//
+// ORCID resolution, the contributors of a work are resolved concurrently through a cache
+//
+
+package libradoi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librametadata "github.com/uvalib/libra-metadata"
+)
+
+// what happens when an ORCID lookup fails
+const orcidFailureSkip = "skip" // omit the identifier
+const orcidFailureFail = "fail" // fail the event so that it is retried
+
+// the cache kept across invocations, only used when there is a TTL
+var sharedOrcidCache *libracommon.OrcidCache
+
+// newOrcidCache returns the cache for an invocation, every payload built by the invocation
+// shares it. Without a TTL the cache only lasts for the invocation
+func newOrcidCache(ttl time.Duration) *libracommon.OrcidCache {
+	if ttl == 0 {
+		return libracommon.NewOrcidCache(0, deps.Now)
+	}
+	if sharedOrcidCache == nil {
+		sharedOrcidCache = libracommon.NewOrcidCache(ttl, deps.Now)
+	}
+	return sharedOrcidCache
+}
+
+func validateOrcidFailurePolicy(policy string) error {
+	if policy != orcidFailureSkip && policy != orcidFailureFail {
+		return fmt.Errorf("unsupported ORCID failure policy [%s]", policy)
+	}
+	return nil
+}
+
+// resolveOrcids looks up the ORCIDs of the contributors with a computing id, at most
+// OrcidConcurrency at a time. Users without an ORCID are absent from the result
+func resolveOrcids(ctx context.Context, contributors []librametadata.ContributorData) (map[string]string, error) {
+
+	cids := make([]string, 0)
+	for _, c := range contributors {
+		if len(c.ComputeID) != 0 && slices.Contains(cids, c.ComputeID) == false {
+			cids = append(cids, c.ComputeID)
+		}
+	}
+
+	cache := Cfg().orcidCache
+	if cache == nil {
+		cache = newOrcidCache(0)
+	}
+	lookup := func(ctx context.Context, cid string) (string, error) {
+		return libracommon.GetOrcidDetails(ctx, Cfg().OrcidGetDetailsURL, cid, Cfg().AuthToken, Cfg().httpClient)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	orcids := make(map[string]string)
+	slots := make(chan struct{}, max(1, Cfg().OrcidConcurrency))
+
+	for _, cid := range cids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			orcid, err := cache.Get(ctx, cid, lookup)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				libracommon.LogWarning("unable to get ORCID details for %s (%s)", cid, err.Error())
+				libracommon.IncrementCounter("OrcidLookupFailures", nil)
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if len(orcid) != 0 {
+				orcids[cid] = orcid
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil && Cfg().OrcidFailurePolicy == orcidFailureFail {
+		return nil, firstErr
+	}
+	return orcids, nil
+}
+
+//
+// end of file
+//
@@ -0,0 +1,73 @@
+//
+// the file details of the Datacite payload
+//
+
+package libradoi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// the formats and total size of the work's files
+func addFiles(ctx context.Context, payload *libracommon.DataciteData, files []uvaeasystore.EasyStoreBlob) {
+	if len(files) == 0 {
+		return
+	}
+
+	formats := make([]string, 0)
+	total := int64(0)
+	sized := true
+	for _, f := range files {
+		if len(f.MimeType()) != 0 && slices.Contains(formats, f.MimeType()) == false {
+			formats = append(formats, f.MimeType())
+		}
+		size, err := fileSize(ctx, f)
+		if err != nil {
+			libracommon.LogWarning("unable to get the size of %s (%s)", f.Name(), err.Error())
+			sized = false
+			continue
+		}
+		total += size
+	}
+
+	payload.Data.Attributes.Formats = formats
+	payload.Data.Attributes.Sizes = []string{fmt.Sprintf("%d files", len(files))}
+	if sized == true {
+		payload.Data.Attributes.Sizes = append(payload.Data.Attributes.Sizes, fmt.Sprintf("%d bytes", total))
+	}
+}
+
+// the size of a file, sizes fetched from a url are remembered for the invocation
+func fileSize(ctx context.Context, f uvaeasystore.EasyStoreBlob) (int64, error) {
+
+	if len(f.Url()) == 0 {
+		buf, err := f.Payload()
+		return int64(len(buf)), err
+	}
+
+	// the url may be signed, the query changes each time the object is fetched
+	key, _, _ := strings.Cut(f.Url(), "?")
+	sizes := Cfg().fileSizes
+	if size, found := sizes[key]; found == true {
+		return size, nil
+	}
+
+	size, err := libracommon.HttpContentLength(ctx, Cfg().httpClient, f.Url())
+	if err != nil {
+		return 0, err
+	}
+	if sizes != nil {
+		sizes[key] = size
+	}
+	return size, nil
+}
+
+//
+// end of file
+//
@@ -2,33 +2,45 @@
 // main message processing
 //
 
-package main
+package libradoi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/uvalib/easystore/uvaeasystore"
-	librametadata "github.com/uvalib/libra-metadata"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Deps returns the external dependencies, the idempotency ledger wraps the deployed processor
+func Deps() libracommon.Deps {
+	return deps
+}
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if isDoiNamespace(ev.Namespace) == false {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -38,28 +50,54 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		return err
 	}
 
-	cfg.httpClient = newHttpClient(1, 30)
+	// the namespace may not be configured in this environment
+	ns, supported := getNamespace(ev.Namespace)
+	if supported == false {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
+		return nil
+	}
+
+	// enough connections for the concurrent ORCID lookups
+	cfg.httpClient = deps.HttpClient(max(1, cfg.OrcidConcurrency), 30)
 	// important, cleanup properly
 	defer cfg.httpClient.CloseIdleConnections()
 
-	cfg.AuthToken, err = getAuthToken(cfg.httpClient, cfg.MintAuthURL)
+	// one cache for every payload built by this invocation
+	cfg.orcidCache = newOrcidCache(cfg.OrcidCacheTTL)
+	cfg.fileSizes = make(map[string]int64)
+
+	// the object is gone so there is nothing more to get from easystore
+	if ev.EventName == uvalibrabus.EventObjectDelete {
+		return withdrawDOI(ctx, ns, ev)
+	}
+
+	cfg.AuthToken, err = libracommon.GetAuthToken(ctx, cfg.httpClient, cfg.MintAuthURL)
 	if err != nil {
 		return err
 	}
 
 	// easystore access
-	es, err := newEasystoreProxy(cfg)
+	es, err := deps.Easystore(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer es.Close()
 
-	eso, err := getEasystoreObjectByKey(es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
+	// the scheduled reconciliation covers the whole namespace, one work at a time
+	if ev.EventName == libracommon.EventScheduleDoiReconcile {
+		return reconcile(ctx, es, ns)
+	}
+	if ev.EventName == libracommon.EventCommandDoiReconcile {
+		return reconcileWork(ctx, es, ns, ev.Identifier)
+	}
+
+	eso, err := libracommon.GetEasystoreObjectByKey(ctx, es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata+uvaeasystore.Files)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
@@ -68,18 +106,19 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	if len(fields["doi"]) > 0 && strings.HasPrefix(fields["doi"], Cfg().DOIBaseURL) == false {
 		// doi exists but for the wrong environment.
 		// if a production DOI is sent to test
-		fmt.Printf("WARNING: DOI %s has the wrong Datacite hostname for this environment (%s). ", fields["doi"], Cfg().DOIBaseURL)
+		libracommon.LogWarning("DOI %s has the wrong Datacite hostname for this environment (%s). ", fields["doi"], Cfg().DOIBaseURL)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
 	if eso.Metadata() == nil {
-		fmt.Printf("ERROR: unable to get metadata payload for ns/oid [%s/%s]\n", ev.Namespace, ev.Identifier)
-		return ErrNoMetadata
+		libracommon.LogError("unable to get metadata payload for ns/oid [%s/%s]", ev.Namespace, ev.Identifier)
+		return libracommon.ErrNoMetadata
 	}
 
 	mdBytes, err := eso.Metadata().Payload()
 	if err != nil {
-		fmt.Printf("ERROR: unable to get metadata payload from response: %s\n", err.Error())
+		libracommon.LogError("unable to get metadata payload from response: %s", err.Error())
 		return err
 	}
 
@@ -91,71 +130,74 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	case uvalibrabus.EventWorkPublish:
 		if fields["draft"] == "false" {
 			// Publish Event for published work
-			fmt.Printf("INFO: Publish Event for [%s/%s]\n", ev.Namespace, ev.Identifier)
+			libracommon.LogInfo("Publish Event for [%s/%s]", ev.Namespace, ev.Identifier)
 			if len(fields["doi"]) > 0 {
-				fmt.Printf("INFO: Publishing DOI %s \n", fields["doi"])
+				libracommon.LogInfo("Publishing DOI %s ", fields["doi"])
 			} else {
-				fmt.Printf("INFO: Publishing new DOI\n")
+				libracommon.LogInfo("Publishing new DOI")
 			}
 			eventType = "publish"
 
 		} else {
-			fmt.Printf("ERROR: Can't publish a draft %s \n", ev.Identifier)
+			libracommon.LogError("Can't publish a draft %s ", ev.Identifier)
 			return nil
 		}
 
 	case uvalibrabus.EventWorkUnpublish:
-		fmt.Printf("INFO: Unpublish Event for [%s/%s]\n", ev.Namespace, ev.Identifier)
+		libracommon.LogInfo("Unpublish Event for [%s/%s]", ev.Namespace, ev.Identifier)
 		// "registered" is a reserved DOI but not findable
 		eventType = "hide"
 
 	case uvalibrabus.EventObjectCreate:
 		if len(fields["doi"]) == 0 && fields["draft"] == "true" {
-			fmt.Printf("INFO: Registering new DOI for draft work [%s/%s].\n", ev.Namespace, ev.Identifier)
+			libracommon.LogInfo("Registering new DOI for draft work [%s/%s].", ev.Namespace, ev.Identifier)
 			eventType = "register"
 		}
 
 	case uvalibrabus.EventMetadataUpdate, uvalibrabus.EventCommandDoiSync:
 		// No Event change for edits or resyncs
 		if len(fields["doi"]) > 0 {
-			fmt.Printf("INFO: Update Event for [%s/%s] with DOI %s\n", ev.Namespace, ev.Identifier, fields["doi"])
+			libracommon.LogInfo("Update Event for [%s/%s] with DOI %s", ev.Namespace, ev.Identifier, fields["doi"])
 		} else {
-			fmt.Printf("INFO: Update Event for [%s/%s] without DOI. One will be created.\n", ev.Namespace, ev.Identifier)
+			libracommon.LogInfo("Update Event for [%s/%s] without DOI. One will be created.", ev.Namespace, ev.Identifier)
 		}
 		// default: no event type change
 	}
 
-	work, err := librametadata.ETDWorkFromBytes(mdBytes)
+	payload, err := ns.build(ctx, mdBytes, fields, eso.Files())
+	if errors.Is(err, ErrBlankTitle) == true {
+		libracommon.LogWarning("Title is blank. Exiting.")
+		libracommon.IgnoreEvent(ctx)
+		return nil
+	}
 	if err != nil {
-		fmt.Printf("ERROR: unable to process ETD Work %s\n", err.Error())
+		libracommon.LogError("creating Datacite payload for [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
-	if len(work.Title) == 0 {
-		fmt.Printf("WARN: Title is blank. Exiting.\n")
-		return nil
-	}
-
-	payload := createETDPayload(work, fields)
 	payload.Data.Attributes.Event = eventType
-	payload.Data.Attributes.URL =
-		fmt.Sprintf("%s/%s/%s", cfg.PublicURLBase, cfg.ETDPublicShoulder, ev.Identifier)
+	payload.Data.Attributes.URL = publicURL(ns, ev.Identifier)
+
+	// show what would change and stop
+	if DryRun == true {
+		return dryRunDiff(ctx, &payload)
+	}
 
 	// send to Datacite
-	doi, err := sendToDatacite(&payload)
+	doi, err := sendToDatacite(ctx, &payload)
 	if err != nil {
-		fmt.Printf("ERROR: sending to Datacite (%s)\n", err.Error())
+		libracommon.LogError("sending to Datacite (%s)", err.Error())
 		return err
 	}
 
 	// Save the new DOI
 	if !strings.HasSuffix(fields["doi"], doi) {
-		fmt.Printf("INFO: New DOI for [%s/%s] is %s\n", ev.Namespace, ev.Identifier, doi)
+		libracommon.LogInfo("New DOI for [%s/%s] is %s", ev.Namespace, ev.Identifier, doi)
 
 		// Refresh easystore object
-		eso, err = getEasystoreObjectByKey(es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
+		eso, err = libracommon.GetEasystoreObjectByKey(ctx, es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
 		if err != nil {
-			fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
-			fmt.Printf("ERROR: DOI created but not saved for [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, doi)
+			libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
+			libracommon.LogError("DOI created but not saved for [%s/%s] (%s)", ev.Namespace, ev.Identifier, doi)
 			return err
 		}
 		fields = eso.Fields()
@@ -163,22 +205,22 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		doiFieldName := "doi"
 		fields[doiFieldName] = fmt.Sprintf("%s/%s", cfg.DOIBaseURL, doi)
 		eso.SetFields(fields)
-		eso, err = putEasystoreFieldWithRetry(es, eso, uvaeasystore.Fields, doiFieldName, fields[doiFieldName])
+		eso, err = libracommon.PutEasystoreFieldWithRetry(ctx, es, eso, uvaeasystore.Fields, doiFieldName, fields[doiFieldName])
 
 		if err != nil {
-			fmt.Printf("ERROR: unable to update object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
-			fmt.Printf("ERROR: DOI created but not saved for [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, doi)
+			libracommon.LogError("unable to update object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
+			libracommon.LogError("DOI created but not saved for [%s/%s] (%s)", ev.Namespace, ev.Identifier, doi)
 			return err
 		}
 
 		// audit this change
 		who := "libra-doi"
-		bus, _ := NewEventBus(cfg.BusName, who)
-		_ = pubAuditEvent(bus, eso, who, "doi", "", fields["doi"])
+		bus, _ := deps.EventBus(cfg.BusName, who)
+		_ = libracommon.PubAuditEvent(ctx, bus, eso, who, "doi", "", fields["doi"])
 	}
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
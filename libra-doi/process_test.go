@@ -0,0 +1,269 @@
+//
+// Process() end to end against the fakes, Datacite and the auth and ORCID services are
+// an httptest service
+//
+
+package libradoi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	librametadata "github.com/uvalib/libra-metadata"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// setupProcess replaces the dependencies with fakes and configures Process() to use the service
+func setupProcess(t *testing.T) (*fakes.Dependencies, *fakes.HttpService) {
+
+	fd := fakes.New()
+	saved := deps
+	deps = fd.Deps()
+	t.Cleanup(func() { deps = saved })
+	libracommon.SetMetricsSink(fakes.NewMetricsSink())
+
+	// every test loads its own configuration
+	cfgLoaded = false
+	t.Cleanup(func() { cfgLoaded = false })
+
+	svc := fakes.NewHttpService()
+	t.Cleanup(svc.Close)
+	svc.Respond("GET", "/auth", 200, `{"token":"a-token"}`)
+
+	t.Setenv("DOI_BASE_URL", "https://doi.org")
+	t.Setenv("PUBLIC_URL_BASE", "https://libra.example.edu")
+	t.Setenv("ETD_PUBLIC_SHOULDER", "public/etd")
+	t.Setenv("OA_PUBLIC_SHOULDER", "")
+	t.Setenv("ES_PROXY_URL", "http://easystore.example.edu")
+	t.Setenv("MESSAGE_BUS", "test-bus")
+	t.Setenv("ORCID_GET_DETAILS_URL", svc.URL+"/orcid/{:id}?auth={:auth}")
+	t.Setenv("MINT_AUTH_URL", svc.URL+"/auth")
+	t.Setenv("ID_SERVICE_BASE", svc.URL+"/datacite")
+	t.Setenv("ID_SERVICE_SHOULDER", "10.5072")
+	t.Setenv("ID_SERVICE_USER", "user")
+	t.Setenv("ID_SERVICE_PASSWORD", "password")
+	t.Setenv("TOMBSTONE_URL", "")
+	return fd, svc
+}
+
+// addWork adds a published dissertation to the easystore
+func addWork(t *testing.T, fd *fakes.Dependencies, id string, doi string) {
+
+	work := librametadata.ETDWork{
+		Title:    "A Study of Things",
+		Degree:   "PHD (Doctor of Philosophy)",
+		Abstract: "Things were studied",
+		Author:   librametadata.ContributorData{ComputeID: "ab1c", FirstName: "Ann", LastName: "Bell"},
+	}
+	pl, err := work.Payload()
+	if err != nil {
+		t.Fatalf("serializing work (%s)", err.Error())
+	}
+
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["draft"] = "false"
+	fields["publish-date"] = "2024-05-01T10:00:00Z"
+	if len(doi) != 0 {
+		fields["doi"] = "https://doi.org/" + doi
+	}
+	obj.SetFields(fields)
+	obj.SetMetadata(uvaeasystore.NewEasyStoreMetadata(work.MimeType(), pl))
+	fd.Easystore.Add(obj)
+}
+
+func busEvent(t *testing.T, name string, id string) json.RawMessage {
+	ev := uvalibrabus.UvaBusEvent{EventName: name, Namespace: libracommon.LibraEtdNamespace, Identifier: id}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	return pl
+}
+
+// dataciteRequests returns the payloads sent to Datacite with the method
+func dataciteRequests(t *testing.T, svc *fakes.HttpService, method string) []libracommon.DataciteData {
+	payloads := make([]libracommon.DataciteData, 0)
+	for _, r := range svc.Requests() {
+		if r.Method != method || strings.HasPrefix(r.Path, "/datacite/") == false {
+			continue
+		}
+		var payload libracommon.DataciteData
+		err := json.Unmarshal(r.Body, &payload)
+		if err != nil {
+			t.Fatalf("unmarshaling Datacite payload (%s)", err.Error())
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func TestProcessSyncMintsDOI(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("POST", "/datacite/dois", 201, `{"data":{"id":"10.5072/new1"}}`)
+	addWork(t, fd, "oid:work1", "")
+
+	err := Process(context.Background(), "msg-1", "test", busEvent(t, uvalibrabus.EventCommandDoiSync, "oid:work1"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	sent := dataciteRequests(t, svc, "POST")
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 Datacite POST, got %d", len(sent))
+	}
+	attributes := sent[0].Data.Attributes
+	if attributes.URL != "https://libra.example.edu/public/etd/oid:work1" {
+		t.Errorf("unexpected url [%s]", attributes.URL)
+	}
+	if attributes.Types.ResourceType != "Dissertation" || attributes.Titles[0].Title != "A Study of Things" {
+		t.Errorf("unexpected types or title %+v %+v", attributes.Types, attributes.Titles)
+	}
+	if len(attributes.XML) != 0 {
+		t.Errorf("a work without a DOI should not have XML")
+	}
+
+	doi := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work1").Fields()["doi"]
+	if doi != "https://doi.org/10.5072/new1" {
+		t.Errorf("expected the new DOI to be saved, got [%s]", doi)
+	}
+	events := fd.Bus.Events()
+	if len(events) != 1 || events[0].EventName != uvalibrabus.EventFieldUpdate || events[0].Identifier != "oid:work1" {
+		t.Errorf("expected a DOI audit event, got %+v", events)
+	}
+}
+
+func TestProcessUpdateSendsMatchingXML(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("PUT", "/datacite/dois/10.5072/abc", 200, `{"data":{"id":"10.5072/abc"}}`)
+	addWork(t, fd, "oid:work2", "10.5072/abc")
+
+	err := Process(context.Background(), "msg-2", "test", busEvent(t, uvalibrabus.EventMetadataUpdate, "oid:work2"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	sent := dataciteRequests(t, svc, "PUT")
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 Datacite PUT, got %d", len(sent))
+	}
+	xml, err := base64.StdEncoding.DecodeString(sent[0].Data.Attributes.XML)
+	if err != nil {
+		t.Fatalf("decoding XML (%s)", err.Error())
+	}
+	for _, expected := range []string{
+		`<identifier identifierType="DOI">10.5072/abc</identifier>`,
+		`<creatorName nameType="Personal">Bell, Ann</creatorName>`,
+		`>Dissertation</resourceType>`,
+		`<date dateType="Issued">2024-05-01</date>`,
+	} {
+		if strings.Contains(string(xml), expected) == false {
+			t.Errorf("expected the XML to contain %s", expected)
+		}
+	}
+
+	// the DOI is unchanged so there is nothing to save or audit
+	if len(fd.Bus.Events()) != 0 {
+		t.Errorf("expected no audit events, got %+v", fd.Bus.Events())
+	}
+}
+
+func TestProcessDeleteWithoutTombstoneIsPermanent(t *testing.T) {
+	_, svc := setupProcess(t)
+
+	err := Process(context.Background(), "msg-3", "test", busEvent(t, uvalibrabus.EventObjectDelete, "oid:gone"))
+	if err == nil || libracommon.IsPermanent(err) == false {
+		t.Fatalf("expected a permanent failure, got %v", err)
+	}
+	for _, r := range svc.Requests() {
+		if strings.HasPrefix(r.Path, "/datacite/") == true {
+			t.Errorf("expected no Datacite requests, got %s %s", r.Method, r.Path)
+		}
+	}
+}
+
+func TestProcessIgnoresOtherNamespacesWithoutConfig(t *testing.T) {
+	// nothing is configured, other namespaces are ignored before the configuration is needed
+	cfgLoaded = false
+	ev := uvalibrabus.UvaBusEvent{EventName: uvalibrabus.EventWorkPublish, Namespace: "libravideo", Identifier: "oid:video1"}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	err = Process(context.Background(), "msg-6", "test", pl)
+	if err != nil || cfgLoaded == true {
+		t.Errorf("expected the event to be ignored without loading the configuration, got %v", err)
+	}
+}
+
+func TestProcessLoadsConfigOnce(t *testing.T) {
+	setupProcess(t)
+
+	// Libra Open is not configured so neither event goes further than the configuration
+	ev := uvalibrabus.UvaBusEvent{EventName: uvalibrabus.EventWorkPublish, Namespace: libracommon.LibraOpenNamespace, Identifier: "oid:oa1"}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	err = Process(context.Background(), "msg-7", "test", pl)
+	if err != nil {
+		t.Fatalf("expected the event to be ignored, got %v", err)
+	}
+
+	t.Setenv("ID_SERVICE_SHOULDER", "10.9999")
+	err = Process(context.Background(), "msg-8", "test", pl)
+	if err != nil || Cfg().IDService.Shoulder != "10.5072" {
+		t.Errorf("expected the configuration of the first invocation, got %s %v", Cfg().IDService.Shoulder, err)
+	}
+}
+
+func TestProcessReconcileFansOut(t *testing.T) {
+	fd, svc := setupProcess(t)
+	addWork(t, fd, "oid:with-doi", "10.5072/abc")
+	addWork(t, fd, "oid:without-doi", "")
+
+	err := Process(context.Background(), "msg-4", "test", busEvent(t, libracommon.EventScheduleDoiReconcile, "none"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	events := fd.Bus.Events()
+	if len(events) != 1 || events[0].EventName != libracommon.EventCommandDoiReconcile || events[0].Identifier != "oid:with-doi" {
+		t.Errorf("expected one reconcile command, got %+v", events)
+	}
+	if len(dataciteRequests(t, svc, "PUT")) != 0 {
+		t.Errorf("the walk should not reconcile")
+	}
+}
+
+func TestProcessIdempotent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("POST", "/datacite/dois", 201, `{"data":{"id":"10.5072/new2"}}`)
+	addWork(t, fd, "oid:work5", "")
+
+	idempotent := libracommon.Idempotent(deps, Process)
+	for range 2 {
+		err := idempotent(context.Background(), "msg-5", "test", busEvent(t, uvalibrabus.EventCommandDoiSync, "oid:work5"))
+		if err != nil {
+			t.Fatalf("expected success, got %s", err.Error())
+		}
+	}
+
+	if sent := dataciteRequests(t, svc, "POST"); len(sent) != 1 {
+		t.Errorf("expected the repeated event to be skipped, got %d Datacite POSTs", len(sent))
+	}
+	records := fd.Idempotency.Records()
+	if len(records) != 1 || records[0].Outcome != libracommon.IdempotencyProcessed {
+		t.Errorf("expected one processed ledger record, got %+v", records)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,279 @@
+//
+// reconciliation, compares the Datacite record of every work with what we would send and
+// reports (or repairs) any difference. Catches drift caused by lost or failed events. The
+// scheduled walk fans out one reconcile command per work
+//
+
+package libradoi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// Datacite DOI states, https://support.datacite.org/docs/doi-states
+const doiStateDraft = "draft"
+const doiStateFindable = "findable"
+
+// the reconciliation totals
+type reconcileSummary struct {
+	checked    int // objects compared with Datacite
+	consistent int // objects that match
+	drifted    int // objects with discrepancies
+	repaired   int // objects repaired
+	skipped    int // objects that cannot be compared
+	failed     int // objects that could not be checked or repaired
+}
+
+// reconcile every object in the namespace. The walk only publishes a reconcile command for
+// each object with a DOI so it gets through large namespaces, the objects are reconciled by
+// those events. A dry run reconciles them here instead since it cannot publish
+func reconcile(ctx context.Context, es uvaeasystore.EasyStoreReadonly, ns Namespace) error {
+
+	namespace := ns.Name
+	libracommon.LogInfo("reconciling DOIs for %s (repair %t)", namespace, Cfg().ReconcileRepair)
+
+	var bus uvalibrabus.UvaBus
+	what := uvaeasystore.EasyStoreComponents(uvaeasystore.Fields + uvaeasystore.Metadata + uvaeasystore.Files)
+	if DryRun == false {
+		var err error
+		bus, err = deps.EventBus(Cfg().BusName, "libra-doi")
+		if err != nil {
+			libracommon.LogError("creating event bus client (%s)", err.Error())
+			return err
+		}
+		what = uvaeasystore.Fields
+	}
+
+	// no field criteria selects everything in the namespace, objects are fetched as we go
+	objs, err := libracommon.GetEasystoreObjectsByFields(ctx, es, namespace, uvaeasystore.EasyStoreObjectFields{}, what)
+	if err != nil {
+		libracommon.LogError("getting objects for %s (%s)", namespace, err.Error())
+		return err
+	}
+
+	var summary reconcileSummary
+	published := 0
+	for {
+		// a partial run is reported rather than retried, the next run starts over
+		if ctx.Err() != nil {
+			libracommon.LogWarning("deadline approaching, stopping reconciliation after %d of %d objects", published+summary.checked+summary.skipped+summary.failed, objs.Count())
+			break
+		}
+
+		eso, err := objs.Next()
+		if errors.Is(err, io.EOF) == true {
+			break
+		}
+		if err != nil {
+			libracommon.LogError("getting next object (%s)", err.Error())
+			summary.failed++
+			continue
+		}
+
+		if bus == nil {
+			_ = reconcileObject(ctx, ns, eso, &summary)
+			continue
+		}
+
+		// objects without a DOI are skipped by reconcileObject, no need to send them
+		if len(eso.Fields()["doi"]) == 0 {
+			summary.skipped++
+			continue
+		}
+		ev := uvalibrabus.UvaBusEvent{
+			EventName:  libracommon.EventCommandDoiReconcile,
+			Namespace:  namespace,
+			Identifier: eso.Id(),
+		}
+		err = libracommon.PublishEvent(ctx, bus, &ev)
+		if err != nil {
+			libracommon.LogError("[%s/%s] publishing reconcile command (%s)", namespace, eso.Id(), err.Error())
+			summary.failed++
+			continue
+		}
+		published++
+	}
+
+	if bus != nil {
+		libracommon.LogInfo("published reconcile commands for %d of %d objects: %d skipped, %d failed", published, objs.Count(), summary.skipped, summary.failed)
+		return nil
+	}
+	libracommon.LogInfo("reconciled %d of %d objects: %d consistent, %d drifted, %d repaired, %d skipped, %d failed",
+		summary.checked, objs.Count(), summary.consistent, summary.drifted, summary.repaired, summary.skipped, summary.failed)
+	return nil
+}
+
+// reconcileWork reconciles a single object, failures are returned so that the command is retried
+func reconcileWork(ctx context.Context, es uvaeasystore.EasyStoreReadonly, ns Namespace, id string) error {
+
+	eso, err := libracommon.GetEasystoreObjectByKey(ctx, es, ns.Name, id, uvaeasystore.Fields+uvaeasystore.Metadata+uvaeasystore.Files)
+	if err != nil {
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ns.Name, id, err.Error())
+		return err
+	}
+
+	var summary reconcileSummary
+	return reconcileObject(ctx, ns, eso, &summary)
+}
+
+// reconcileObject compares (and repairs) one object, the outcome is counted in the summary.
+// Objects that cannot be compared are skipped, only failures to check or repair are returned
+func reconcileObject(ctx context.Context, ns Namespace, eso uvaeasystore.EasyStoreObject, summary *reconcileSummary) error {
+
+	fields := eso.Fields()
+	key := fmt.Sprintf("%s/%s", eso.Namespace(), eso.Id())
+
+	if len(fields["doi"]) == 0 {
+		// a DOI sync creates one, reconciliation only compares
+		libracommon.LogWarning("[%s] has no DOI, skipping", key)
+		summary.skipped++
+		return nil
+	}
+	if strings.HasPrefix(fields["doi"], Cfg().DOIBaseURL) == false {
+		libracommon.LogWarning("[%s] DOI %s has the wrong Datacite hostname for this environment, skipping", key, fields["doi"])
+		summary.skipped++
+		return nil
+	}
+
+	if eso.Metadata() == nil {
+		libracommon.LogWarning("[%s] has no metadata, skipping", key)
+		summary.skipped++
+		return nil
+	}
+	mdBytes, err := eso.Metadata().Payload()
+	if err != nil {
+		libracommon.LogWarning("[%s] unable to get metadata payload (%s), skipping", key, err.Error())
+		summary.skipped++
+		return nil
+	}
+
+	// what we would send
+	payload, err := ns.build(ctx, mdBytes, fields, eso.Files())
+	if err != nil {
+		libracommon.LogWarning("[%s] cannot build a Datacite payload (%s), skipping", key, err.Error())
+		summary.skipped++
+		return nil
+	}
+	payload.Data.Attributes.URL = publicURL(ns, eso.Id())
+	published := fields["draft"] == "false"
+
+	// what Datacite has
+	summary.checked++
+	discrepancies := make([]string, 0)
+	record, err := getFromDatacite(ctx, payload.Data.Attributes.DOI)
+	if err != nil {
+		if libracommon.IsHttpNotFound(err) == false {
+			libracommon.LogError("[%s] getting DOI %s from Datacite (%s)", key, payload.Data.Attributes.DOI, err.Error())
+			summary.failed++
+			return err
+		}
+		discrepancies = append(discrepancies, "missing")
+	} else {
+		discrepancies = compareRecord(&payload, published, record)
+	}
+
+	if len(discrepancies) == 0 {
+		summary.consistent++
+		return nil
+	}
+
+	summary.drifted++
+	for _, d := range discrepancies {
+		libracommon.IncrementCounter("DoiDiscrepancies", map[string]string{"Field": d})
+	}
+	libracommon.LogWarning("[%s] DOI %s differs from Datacite: %s", key, payload.Data.Attributes.DOI, strings.Join(discrepancies, ", "))
+
+	if Cfg().ReconcileRepair == false {
+		return nil
+	}
+	if DryRun == true {
+		fmt.Printf("DRY RUN: [%s] DOI %s would be repaired with event [%s]\n", key, payload.Data.Attributes.DOI, repairEvent(published, record))
+		return nil
+	}
+
+	payload.Data.Attributes.Event = repairEvent(published, record)
+	_, err = sendToDatacite(ctx, &payload)
+	if err != nil {
+		libracommon.LogError("[%s] repairing DOI %s (%s)", key, payload.Data.Attributes.DOI, err.Error())
+		summary.failed++
+		return err
+	}
+	libracommon.LogInfo("[%s] repaired DOI %s", key, payload.Data.Attributes.DOI)
+	libracommon.IncrementCounter("DoiRepairs", nil)
+	summary.repaired++
+	return nil
+}
+
+// compareRecord returns the names of the attributes that differ
+func compareRecord(expected *libracommon.DataciteData, published bool, record *DataciteRecord) []string {
+
+	discrepancies := make([]string, 0)
+	actual := record.Data.Attributes
+
+	// published works are findable, anything else must not be
+	if published != (actual.State == doiStateFindable) {
+		discrepancies = append(discrepancies, "state")
+	}
+
+	// draft DOIs need not have a URL
+	if actual.State != doiStateDraft && actual.URL != expected.Data.Attributes.URL {
+		discrepancies = append(discrepancies, "url")
+	}
+
+	if slices.Equal(titles(expected.Data.Attributes.Titles), titles(actual.Titles)) == false {
+		discrepancies = append(discrepancies, "title")
+	}
+
+	if slices.Equal(personNames(expected.Data.Attributes.Creators), personNames(actual.Creators)) == false {
+		discrepancies = append(discrepancies, "creators")
+	}
+
+	return discrepancies
+}
+
+// the Datacite event that moves the DOI to the state matching the work, none when the
+// state is already correct. record is nil when the DOI is not registered
+func repairEvent(published bool, record *DataciteRecord) string {
+	state := ""
+	if record != nil {
+		state = record.Data.Attributes.State
+	}
+	switch {
+	case published == true && state != doiStateFindable:
+		return "publish"
+	case published == false && state == doiStateFindable:
+		return "hide"
+	case published == false && record == nil:
+		return "register"
+	}
+	return ""
+}
+
+func titles(list []libracommon.TitleData) []string {
+	values := make([]string, 0, len(list))
+	for _, t := range list {
+		values = append(values, strings.TrimSpace(t.Title))
+	}
+	return values
+}
+
+func personNames(list []libracommon.PersonData) []string {
+	names := make([]string, 0, len(list))
+	for _, p := range list {
+		names = append(names, strings.TrimSpace(p.FamilyName)+"|"+strings.TrimSpace(p.GivenName))
+	}
+	return names
+}
+
+//
+// end of file
+//
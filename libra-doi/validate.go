@@ -0,0 +1,177 @@
+//
+// payload validation against the Datacite 4.x metadata schema rules, so that invalid works
+// fail here rather than with a 422 from Datacite
+//
+
+package libradoi
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+var ErrInvalidPayload = fmt.Errorf("invalid Datacite payload")
+
+// the controlled vocabularies we validate against
+var dataciteNameTypes = []string{"Personal", "Organizational"}
+var dataciteNameIdentifierSchemes = []string{"ORCID", "ISNI", "ROR", "GRID", "Wikidata"}
+var dataciteAffiliationIdentifierSchemes = []string{"ROR", "GRID", "ISNI"}
+var dataciteDescriptionTypes = []string{"Abstract", "Methods", "SeriesInformation", "TableOfContents", "TechnicalInfo", "Other"}
+var dataciteDateTypes = []string{"Accepted", "Available", "Copyrighted", "Collected", "Coverage", "Created",
+	"Issued", "Submitted", "Updated", "Valid", "Withdrawn", "Other"}
+var dataciteContributorTypes = []string{"ContactPerson", "DataCollector", "DataCurator", "DataManager", "Distributor",
+	"Editor", "HostingInstitution", "Producer", "ProjectLeader", "ProjectManager", "ProjectMember",
+	"RegistrationAgency", "RegistrationAuthority", "RelatedPerson", "Researcher", "ResearchGroup",
+	"RightsHolder", "Sponsor", "Supervisor", "Translator", "WorkPackageLeader", "Other"}
+
+var dataciteRelatedIdentifierTypes = []string{"ARK", "arXiv", "bibcode", "DOI", "EAN13", "EISSN", "Handle", "IGSN", "ISBN",
+	"ISSN", "ISTC", "LISSN", "LSID", "PMID", "PURL", "UPC", "URL", "URN", "w3id"}
+var dataciteRelationTypes = []string{"IsCitedBy", "Cites", "IsSupplementTo", "IsSupplementedBy", "IsContinuedBy",
+	"Continues", "IsDescribedBy", "Describes", "HasMetadata", "IsMetadataFor", "HasVersion", "IsVersionOf",
+	"IsNewVersionOf", "IsPreviousVersionOf", "IsPartOf", "HasPart", "IsPublishedIn", "IsReferencedBy",
+	"References", "IsDocumentedBy", "Documents", "IsCompiledBy", "Compiles", "IsVariantFormOf",
+	"IsOriginalFormOf", "IsIdenticalTo", "IsReviewedBy", "Reviews", "IsDerivedFrom", "IsSourceOf",
+	"IsRequiredBy", "Requires", "IsObsoletedBy", "Obsoletes", "IsCollectedBy", "Collects"}
+
+var yearPattern = regexp.MustCompile(`^\d{4}$`)
+var orcidPattern = regexp.MustCompile(`^(https://orcid\.org/)?\d{4}-\d{4}-\d{4}-\d{3}[\dX]$`)
+
+// validatePayload checks the payload and returns a permanent error listing every problem
+func validatePayload(payload *libracommon.DataciteData) error {
+	problems := payloadProblems(payload)
+	if len(problems) == 0 {
+		return nil
+	}
+	for _, p := range problems {
+		libracommon.LogError("Datacite payload: %s", p)
+	}
+	return libracommon.Permanent(fmt.Errorf("%w: %s", ErrInvalidPayload, strings.Join(problems, "; ")))
+}
+
+func payloadProblems(payload *libracommon.DataciteData) []string {
+
+	problems := make([]string, 0)
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	attr := payload.Data.Attributes
+
+	if len(attr.DOI) != 0 && libracommon.IsDOI(attr.DOI) == false {
+		add("doi [%s] is malformed", attr.DOI)
+	}
+
+	// required properties
+	if slices.ContainsFunc(attr.Titles, func(t libracommon.TitleData) bool { return len(strings.TrimSpace(t.Title)) != 0 }) == false {
+		add("a title is required")
+	}
+	if len(attr.Creators) == 0 {
+		add("a creator is required")
+	}
+	if len(strings.TrimSpace(attr.Publisher.Name)) == 0 {
+		add("a publisher is required")
+	}
+	if yearPattern.MatchString(attr.PublicationYear) == false {
+		add("publicationYear [%s] must be YYYY", attr.PublicationYear)
+	}
+	if len(attr.Types.ResourceTypeGeneral) == 0 {
+		add("a resourceTypeGeneral is required")
+	} else if libracommon.IsResourceTypeGeneral(attr.Types.ResourceTypeGeneral) == false {
+		add("resourceTypeGeneral [%s] is not supported", attr.Types.ResourceTypeGeneral)
+	}
+
+	if len(attr.Language) != 0 && libracommon.IsLanguageCode(attr.Language) == false {
+		add("language [%s] is not a valid language code", attr.Language)
+	}
+	for i, t := range attr.Titles {
+		if len(t.Lang) != 0 && libracommon.IsLanguageCode(t.Lang) == false {
+			add("titles[%d] language [%s] is not a valid language code", i, t.Lang)
+		}
+	}
+	for i, d := range attr.Descriptions {
+		if slices.Contains(dataciteDescriptionTypes, d.DescriptionType) == false {
+			add("descriptions[%d] descriptionType [%s] is not supported", i, d.DescriptionType)
+		}
+	}
+	for i, p := range attr.Creators {
+		problems = append(problems, personProblems(fmt.Sprintf("creators[%d]", i), p, false)...)
+	}
+	for i, p := range attr.Contributors {
+		problems = append(problems, personProblems(fmt.Sprintf("contributors[%d]", i), p, true)...)
+	}
+	for i, d := range attr.Dates {
+		if slices.Contains(dataciteDateTypes, d.DateType) == false {
+			add("dates[%d] dateType [%s] is not supported", i, d.DateType)
+		}
+		if validDate(d.Date) == false {
+			add("dates[%d] date [%s] is not YYYY, YYYY-MM-DD or RFC3339", i, d.Date)
+		}
+	}
+	for i, r := range attr.RelatedIdentifiers {
+		if len(strings.TrimSpace(r.RelatedIdentifier)) == 0 {
+			add("relatedIdentifiers[%d] identifier is required", i)
+		}
+		if slices.Contains(dataciteRelatedIdentifierTypes, r.RelatedIdentifierType) == false {
+			add("relatedIdentifiers[%d] relatedIdentifierType [%s] is not supported", i, r.RelatedIdentifierType)
+		}
+		if slices.Contains(dataciteRelationTypes, r.RelationType) == false {
+			add("relatedIdentifiers[%d] relationType [%s] is not supported", i, r.RelationType)
+		}
+	}
+	for i, a := range attr.AlternateIdentifiers {
+		if len(a.AlternateIdentifier) == 0 || len(a.AlternateIdentifierType) == 0 {
+			add("alternateIdentifiers[%d] identifier and type are required", i)
+		}
+	}
+	return problems
+}
+
+func personProblems(where string, p libracommon.PersonData, contributor bool) []string {
+
+	problems := make([]string, 0)
+	if len(strings.TrimSpace(p.Name)) == 0 && len(strings.TrimSpace(p.FamilyName)) == 0 {
+		problems = append(problems, fmt.Sprintf("%s name is required", where))
+	}
+	if len(p.NameType) != 0 && slices.Contains(dataciteNameTypes, p.NameType) == false {
+		problems = append(problems, fmt.Sprintf("%s nameType [%s] is not supported", where, p.NameType))
+	}
+	if contributor == true && slices.Contains(dataciteContributorTypes, p.ContributorType) == false {
+		problems = append(problems, fmt.Sprintf("%s contributorType [%s] is not supported", where, p.ContributorType))
+	}
+	for i, ni := range p.NameIdentifiers {
+		if slices.Contains(dataciteNameIdentifierSchemes, ni.NameIdentifierScheme) == false {
+			problems = append(problems, fmt.Sprintf("%s nameIdentifiers[%d] scheme [%s] is not supported", where, i, ni.NameIdentifierScheme))
+			continue
+		}
+		if ni.NameIdentifierScheme == "ORCID" && orcidPattern.MatchString(ni.NameIdentifier) == false {
+			problems = append(problems, fmt.Sprintf("%s nameIdentifiers[%d] ORCID [%s] is malformed", where, i, ni.NameIdentifier))
+		}
+	}
+	for i, a := range p.Affiliation {
+		if len(strings.TrimSpace(a.Name)) == 0 && len(a.AffiliationIdentifier) == 0 {
+			problems = append(problems, fmt.Sprintf("%s affiliation[%d] is empty", where, i))
+		}
+		if len(a.AffiliationIdentifierScheme) != 0 && slices.Contains(dataciteAffiliationIdentifierSchemes, a.AffiliationIdentifierScheme) == false {
+			problems = append(problems, fmt.Sprintf("%s affiliation[%d] scheme [%s] is not supported", where, i, a.AffiliationIdentifierScheme))
+		}
+	}
+	return problems
+}
+
+// validDate accepts the date forms we send
+func validDate(date string) bool {
+	for _, layout := range []string{"2006", "2006-01-02", time.RFC3339} {
+		if _, err := time.Parse(layout, date); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// end of file
+//
@@ -0,0 +1,104 @@
+//
+// withdrawal of the DOIs of deleted works. Draft DOIs are deleted, registered and findable
+// DOIs cannot be so they are pointed at a tombstone page and the withdrawal recorded
+//
+
+package libradoi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// the optional withdrawal detail of a delete event
+var ErrNoTombstoneURL = fmt.Errorf("tombstone url is not configured")
+
+type withdrawalDetail struct {
+	Reason string `json:"reason"`
+}
+
+// withdrawDOI withdraws every DOI that resolves to the deleted work. The object no longer
+// exists so the DOIs are found through Datacite
+func withdrawDOI(ctx context.Context, ns Namespace, ev *uvalibrabus.UvaBusEvent) error {
+
+	// retrying will not help until the configuration is fixed
+	if len(Cfg().TombstoneURL) == 0 {
+		libracommon.LogError("TOMBSTONE_URL is not configured, cannot withdraw DOIs for [%s/%s]", ev.Namespace, ev.Identifier)
+		return libracommon.Permanent(ErrNoTombstoneURL)
+	}
+
+	reason := Cfg().WithdrawalReason
+	if len(ev.Detail) != 0 {
+		var detail withdrawalDetail
+		if json.Unmarshal(ev.Detail, &detail) == nil && len(detail.Reason) != 0 {
+			reason = detail.Reason
+		}
+	}
+
+	records, err := findInDatacite(ctx, publicURL(ns, ev.Identifier))
+	if err != nil {
+		libracommon.LogError("finding DOIs for [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
+		return err
+	}
+	if len(records) == 0 {
+		libracommon.LogInfo("no DOI for [%s/%s], ignoring", ev.Namespace, ev.Identifier)
+		libracommon.IgnoreEvent(ctx)
+		return nil
+	}
+
+	for _, r := range records {
+		if DryRun == true {
+			printWithdrawal(os.Stdout, r, tombstoneURL(ev.Identifier), reason)
+			continue
+		}
+
+		if r.Attributes.State == doiStateDraft {
+			libracommon.LogInfo("deleting draft DOI %s for [%s/%s]", r.ID, ev.Namespace, ev.Identifier)
+			err = deleteFromDatacite(ctx, r.ID)
+			if err != nil {
+				libracommon.LogError("deleting DOI %s (%s)", r.ID, err.Error())
+				return err
+			}
+			continue
+		}
+
+		libracommon.LogInfo("tombstoning %s DOI %s for [%s/%s] (%s)", r.Attributes.State, r.ID, ev.Namespace, ev.Identifier, reason)
+		// replacing any earlier withdrawal so that a redelivered event changes nothing
+		dates := slices.DeleteFunc(r.Attributes.Dates, func(d libracommon.DateData) bool { return d.DateType == "Withdrawn" })
+		dates = append(dates, libracommon.DateData{
+			Date:            deps.Now().Format("2006-01-02"),
+			DateType:        "Withdrawn",
+			DateInformation: reason,
+		})
+		err = updateDataciteAttributes(ctx, r.ID, map[string]any{
+			"url":   tombstoneURL(ev.Identifier),
+			"dates": dates,
+		})
+		if err != nil {
+			libracommon.LogError("tombstoning DOI %s (%s)", r.ID, err.Error())
+			return err
+		}
+	}
+
+	if DryRun == true {
+		return nil
+	}
+	libracommon.LogInfo("withdrew %d DOI(s) for [%s/%s]", len(records), ev.Namespace, ev.Identifier)
+	return nil
+}
+
+// the tombstone page a withdrawn DOI resolves to
+func tombstoneURL(identifier string) string {
+	return strings.Replace(Cfg().TombstoneURL, "{:id}", identifier, 1)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libraeventaudit "github.com/uvalib/libra-event-audit"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.RunCmdline(libraeventaudit.Process)
+}
+
+//
+// end of file
+//
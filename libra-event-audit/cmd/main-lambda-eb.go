@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an EventBridge triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraeventaudit "github.com/uvalib/libra-event-audit"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartEventBridgeLambda(libraeventaudit.Process)
+}
+
+//
+// end of file
+//
@@ -2,24 +2,26 @@
 // main message processing
 //
 
-package main
+package libraeventaudit
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 	return nil
 }
 
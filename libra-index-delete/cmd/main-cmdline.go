@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libraindexdelete "github.com/uvalib/libra-index-delete"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.RunCmdline(libraindexdelete.Process)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraindexdelete "github.com/uvalib/libra-index-delete"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libraindexdelete.Process)
+}
+
+//
+// end of file
+//
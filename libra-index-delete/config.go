@@ -1,13 +1,13 @@
-package main
+package libraindexdelete
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 	// index endpoint configuration
-	IndexDeleteUrl string // the index update URL
+	IndexDeleteUrl string `env:"INDEX_DELETE_URL" required:"true"` // the index update URL
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -16,13 +16,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.IndexDeleteUrl, err = ensureSetAndNonEmpty("INDEX_DELETE_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Printf("[conf] IndexDeleteUrl = [%s]\n", cfg.IndexDeleteUrl)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,30 +2,36 @@
 // main message processing
 //
 
-package main
+package libraindexdelete
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
 
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -36,19 +42,19 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// get a new http client
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
 	url := strings.Replace(cfg.IndexDeleteUrl, "{:id}", ev.Identifier, 1)
 
-	_, err = httpDelete(httpClient, url)
+	_, err = libracommon.HttpDelete(ctx, httpClient, url)
 	if err == nil {
 		// log the happy news
-		fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+		libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	} else {
 		// log the sad news
-		fmt.Printf("ERROR: EVENT %s from %s FAILED (%s)\n", messageId, messageSrc, err.Error())
+		libracommon.LogError("EVENT %s from %s FAILED (%s)", messageId, messageSrc, err.Error())
 	}
 
 	return err
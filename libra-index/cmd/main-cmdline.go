@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libraindex "github.com/uvalib/libra-index"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.RunCmdline(libraindex.Process)
+}
+
+//
+// end of file
+//
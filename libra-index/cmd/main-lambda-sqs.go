@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraindex "github.com/uvalib/libra-index"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libraindex.Process)
+}
+
+//
+// end of file
+//
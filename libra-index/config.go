@@ -1,19 +1,19 @@
-package main
+package libraindex
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 	// index endpoint configuration
-	IndexUpdateUrl string // the index update URL
+	IndexUpdateUrl string `env:"INDEX_UPDATE_URL" required:"true"` // the index update URL
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
-	BusName string // the message bus name
+	BusName string `env:"MESSAGE_BUS"` // the message bus name
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -22,22 +22,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.IndexUpdateUrl, err = ensureSetAndNonEmpty("INDEX_UPDATE_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] IndexUpdateUrl    = [%s]\n", cfg.IndexUpdateUrl)
-	fmt.Printf("[conf] EsProxyUrl        = [%s]\n", cfg.EsProxyUrl)
-	fmt.Printf("[conf] BusName           = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,12 +2,13 @@
 //
 //
 
-package main
+package libraindex
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"net/http"
 	"time"
 )
@@ -23,7 +24,7 @@ type IndexWork struct {
 	Files        json.RawMessage `json:"files,omitempty"`
 }
 
-func updateIndex(config *Config, eso uvaeasystore.EasyStoreObject, client *http.Client) error {
+func updateIndex(ctx context.Context, config *Config, eso uvaeasystore.EasyStoreObject, client *http.Client) error {
 
 	// create the request payload
 	req := IndexWork{
@@ -67,22 +68,22 @@ func updateIndex(config *Config, eso uvaeasystore.EasyStoreObject, client *http.
 
 	pl, err := json.Marshal(req)
 	if err != nil {
-		fmt.Printf("ERROR: json marshal of IndexWork (%s)\n", err.Error())
+		libracommon.LogError("json marshal of IndexWork (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: payload [%s]\n", string(pl))
+	libracommon.LogInfo("payload [%s]", string(pl))
 
-	buf, err := httpPost(client, config.IndexUpdateUrl, pl, "application/json")
+	buf, err := libracommon.HttpPost(ctx, client, config.IndexUpdateUrl, pl, "application/json")
 	if err != nil {
-		fmt.Printf("ERROR: failed payload [%s]\n", string(pl))
+		libracommon.LogError("failed payload [%s]", string(pl))
 		if buf != nil {
-			fmt.Printf("ERROR: failed response [%s]\n", string(buf))
+			libracommon.LogError("failed response [%s]", string(buf))
 		}
 		return err
 	}
 
-	fmt.Printf("INFO: response [%s]\n", string(buf))
+	libracommon.LogInfo("response [%s]", string(buf))
 
 	// all good
 	return nil
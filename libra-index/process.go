@@ -2,32 +2,38 @@
 // main message processing
 //
 
-package main
+package libraindex
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 	//"strings"
 	//"time"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -38,34 +44,34 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// easystore access
-	esro, err := newEasystoreReadonlyProxy(cfg)
+	esro, err := deps.EasystoreReadonly(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer esro.Close()
 
-	obj, err := getEasystoreObjectByKey(esro, ev.Namespace, ev.Identifier, uvaeasystore.Metadata+uvaeasystore.Fields)
+	obj, err := libracommon.GetEasystoreObjectByKey(ctx, esro, ev.Namespace, ev.Identifier, uvaeasystore.Metadata+uvaeasystore.Fields)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	// get a new http client
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
 	// and update the index
-	err = updateIndex(cfg, obj, httpClient)
+	err = updateIndex(ctx, cfg, obj, httpClient)
 	if err == nil {
 		// log the happy news
-		fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+		libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	} else {
 		// log the sad news
-		fmt.Printf("ERROR: EVENT %s from %s FAILED (%s)\n", messageId, messageSrc, err.Error())
+		libracommon.LogError("EVENT %s from %s FAILED (%s)", messageId, messageSrc, err.Error())
 	}
 
 	return err
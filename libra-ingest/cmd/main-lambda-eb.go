@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an EventBridge triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libraingest "github.com/uvalib/libra-ingest"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartEventBridgeLambda(libraingest.Process)
+}
+
+//
+// end of file
+//
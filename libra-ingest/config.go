@@ -1,23 +1,26 @@
-package main
+package libraingest
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 
 	// service endpoint configuration
-	MintAuthUrl        string // mint auth token endpoint
-	UserInfoUrl        string // the user information service
-	SisIngestUrl       string // the sis ingest service
-	SisIngestStateName string // the sis ingest ssm state name
+	MintAuthUrl        string `env:"MINT_AUTH_URL" required:"true"`         // mint auth token endpoint
+	UserInfoUrl        string `env:"USER_INFO_URL" required:"true"`         // the user information service
+	SisIngestUrl       string `env:"SIS_INGEST_URL" required:"true"`        // the sis ingest service
+	SisIngestStateName string `env:"SIS_INGEST_STATE_NAME" required:"true"` // the sis ingest ssm state name
+
+	// sis item failure handling
+	SisQuarantineStateName string `env:"SIS_INGEST_QUARANTINE_NAME"` // the ssm quarantine state name, defaults to the state name with a -quarantine suffix
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
-	BusName string // the message bus name
+	BusName string `env:"MESSAGE_BUS"` // the message bus name
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -26,38 +29,16 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.MintAuthUrl, err = ensureSetAndNonEmpty("MINT_AUTH_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.UserInfoUrl, err = ensureSetAndNonEmpty("USER_INFO_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.SisIngestUrl, err = ensureSetAndNonEmpty("SIS_INGEST_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.SisIngestStateName, err = ensureSetAndNonEmpty("SIS_INGEST_STATE_NAME")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
+	if len(cfg.SisQuarantineStateName) == 0 {
+		cfg.SisQuarantineStateName = cfg.SisIngestStateName + "-quarantine"
 	}
 
-	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] EsProxyUrl              = [%s]\n", cfg.EsProxyUrl)
-	fmt.Printf("[conf] MintAuthUrl             = [%s]\n", cfg.MintAuthUrl)
-	fmt.Printf("[conf] UserInfoUrl             = [%s]\n", cfg.UserInfoUrl)
-	fmt.Printf("[conf] SisIngestUrl            = [%s]\n", cfg.SisIngestUrl)
-	fmt.Printf("[conf] SisIngestStateName      = [%s]\n", cfg.SisIngestStateName)
-	fmt.Printf("[conf] BusName                 = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,18 +2,23 @@
 //
 //
 
-package main
+package libraingest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	librametadata "github.com/uvalib/libra-metadata"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
 type InboundSisResponse struct {
@@ -33,160 +38,263 @@ type InboundSisItem struct {
 	Degree      string `json:"degree"`
 }
 
-func processSis(cfg *Config, objs []InboundSisItem, es uvaeasystore.EasyStore) error {
-	fmt.Printf("INFO: processing %d SIS item(s)\n", len(objs))
+// who the audit events are from
+var auditWho = "libra-ingest"
 
-	// audit infrastructure
-	auditWho := "libra-ingest"
-	messageBus, _ := NewEventBus(cfg.BusName, auditWho)
+func processSis(ctx context.Context, cfg *Config, objs []InboundSisItem, es uvaeasystore.EasyStore, messageBus uvalibrabus.UvaBus, ssm libracommon.ParameterStore, state *ingestState) error {
+	libracommon.LogInfo("processing %d SIS item(s)", len(objs))
 
-	var returnErr error
 	for _, o := range objs {
-		fmt.Printf("INFO: processing SIS # %s for %s\n", o.InboundId, o.ComputingId)
+		// out of time, the remaining items are picked up next time
+		if ctx.Err() != nil {
+			libracommon.LogWarning("stopping before SIS # %s (%s)", o.InboundId, ctx.Err().Error())
+			return libracommon.Transient(ctx.Err())
+		}
 
-		sourceId := fmt.Sprintf("sis:%s", o.Id)
-		fields := uvaeasystore.DefaultEasyStoreFields()
-		fields["source-id"] = sourceId
+		err := processSisItem(ctx, o, es, messageBus, auditWho)
+		switch {
+		case err == nil:
+			state.succeeded(o.InboundId)
+
+		case ctx.Err() != nil:
+			// not the item's fault, it is retried next time
+			libracommon.LogWarning("stopping at SIS # %s (%s)", o.InboundId, ctx.Err().Error())
+			return libracommon.Transient(ctx.Err())
+
+		case libracommon.IsPermanent(err) == false:
+			// an outage, hold the cursor so the item is retried next time
+			libracommon.LogError("SIS # %s failed, holding the cursor (%s)", o.InboundId, err.Error())
+			return err
+
+		case state.quarantine(o.InboundId, deps.Now()) == false:
+			libracommon.LogError("SIS # %s failed and the quarantine is full (%d items), holding the cursor (%s)", o.InboundId, len(state.Quarantined), err.Error())
+			libracommon.IncrementCounter("SisQuarantineFull", nil)
+			return err
 
-		// try and find an existing object
-		esrs, err := getEasystoreObjectsByFields(es, libraEtdNamespace, fields, uvaeasystore.Fields+uvaeasystore.Metadata)
+		default:
+			libracommon.LogError("SIS # %s failed permanently, quarantining it (%s)", o.InboundId, err.Error())
+			libracommon.IncrementCounter("SisQuarantined", nil)
+		}
+
+		// checkpoint, the cursor moves past the item whether it was processed or quarantined
+		err = state.save(ctx, ssm, cfg.SisQuarantineStateName)
+		if err != nil {
+			return err
+		}
+		err = ssm.SetParameter(ctx, cfg.SisIngestStateName, o.InboundId)
 		if err != nil {
-			fmt.Printf("ERROR: finding easystore object, continuing (%s)\n", err.Error())
-			returnErr = err
-			continue
+			return err
 		}
+		libracommon.LogInfo("last SIS = [%s]", o.InboundId)
+	}
 
-		// did we find an existing object?
-		if esrs.Count() == 1 {
-			eso, err := esrs.Next()
-			if err != nil {
-				fmt.Printf("ERROR: finding easystore object, continuing (%s)\n", err.Error())
-				returnErr = err
-				continue
-			}
+	return nil
+}
 
-			// ensure the work is in draft state
-			if eso.Fields()["draft"] == "true" {
+// retryQuarantined processes the quarantined items that are due again, those that succeed
+// leave the quarantine. Failures are counted and the items dropped once they run out of attempts
+func retryQuarantined(ctx context.Context, cfg *Config, es uvaeasystore.EasyStore, messageBus uvalibrabus.UvaBus, ssm libracommon.ParameterStore, state *ingestState, auth string, client *http.Client) error {
 
-				// if we have a metadata payload
-				if eso.Metadata() != nil {
-					esomd := eso.Metadata()
-					pl, err := esomd.Payload()
-					if err != nil {
-						fmt.Printf("ERROR: getting metadata from easystore object [%s/%s], continuing (%s)\n", eso.Namespace(), eso.Id(), err.Error())
-						returnErr = err
-						continue
-					}
+	now := deps.Now()
+	due := make([]quarantinedItem, 0)
+	for _, qi := range state.Quarantined {
+		if qi.due(now) == true {
+			due = append(due, qi)
+		}
+	}
+	libracommon.LogInfo("retrying %d of %d quarantined SIS item(s)", len(due), len(state.Quarantined))
+
+	for _, qi := range due {
+		if ctx.Err() != nil {
+			break
+		}
+
+		err := retryQuarantinedItem(ctx, cfg, es, messageBus, qi.Id, auth, client)
+		switch {
+		case err == nil:
+			state.succeeded(qi.Id)
+
+		case ctx.Err() != nil, libracommon.IsPermanent(err) == false:
+			// an outage, not the item's fault so the attempt does not count
+			libracommon.LogWarning("retrying quarantined SIS # %s (%s), trying the rest next time", qi.Id, err.Error())
+			return errors.Join(err, state.save(ctx, ssm, cfg.SisQuarantineStateName))
+
+		case state.failed(qi.Id, now) == false:
+			libracommon.LogError("quarantined SIS # %s failed %d times, dropping it (%s)", qi.Id, maxQuarantineAttempts, err.Error())
+			libracommon.IncrementCounter("SisQuarantineDropped", nil)
+
+		default:
+			libracommon.LogWarning("quarantined SIS # %s still fails (%s)", qi.Id, err.Error())
+		}
+	}
+
+	err := state.save(ctx, ssm, cfg.SisQuarantineStateName)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return libracommon.Transient(ctx.Err())
+	}
+	return nil
+}
+
+// retryQuarantinedItem gets the quarantined item from SIS and processes it again
+func retryQuarantinedItem(ctx context.Context, cfg *Config, es uvaeasystore.EasyStore, messageBus uvalibrabus.UvaBus, id string, auth string, client *http.Client) error {
+
+	// the service returns the items after the one given
+	previous, err := strconv.Atoi(id)
+	if err != nil {
+		return libracommon.Permanent(fmt.Errorf("quarantined SIS # %s is not numeric", id))
+	}
+	items, err := inboundSis(ctx, cfg, strconv.Itoa(previous-1), auth, client)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(items, func(o InboundSisItem) bool { return o.InboundId == id })
+	if idx == -1 {
+		return libracommon.Permanent(fmt.Errorf("quarantined SIS # %s is no longer available from SIS", id))
+	}
+	return processSisItem(ctx, items[idx], es, messageBus, auditWho)
+}
+
+// processSisItem creates the work for an inbound item, or updates the title of an existing draft work
+func processSisItem(ctx context.Context, o InboundSisItem, es uvaeasystore.EasyStore, messageBus uvalibrabus.UvaBus, auditWho string) error {
+	libracommon.LogInfo("processing SIS # %s for %s", o.InboundId, o.ComputingId)
+
+	sourceId := fmt.Sprintf("sis:%s", o.Id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["source-id"] = sourceId
+
+	// try and find an existing object
+	esrs, err := libracommon.GetEasystoreObjectsByFields(ctx, es, libracommon.LibraEtdNamespace, fields, uvaeasystore.Fields+uvaeasystore.Metadata)
+	if err != nil {
+		libracommon.LogError("finding easystore object (%s)", err.Error())
+		return err
+	}
+
+	// did we find an existing object?
+	if esrs.Count() == 1 {
+		eso, err := esrs.Next()
+		if err != nil {
+			libracommon.LogError("finding easystore object (%s)", err.Error())
+			return err
+		}
+
+		// ensure the work is in draft state
+		if eso.Fields()["draft"] == "true" {
+
+			// if we have a metadata payload
+			if eso.Metadata() != nil {
+				esomd := eso.Metadata()
+				pl, err := esomd.Payload()
+				if err != nil {
+					libracommon.LogError("getting metadata from easystore object [%s/%s] (%s)", eso.Namespace(), eso.Id(), err.Error())
+					return err
+				}
+
+				md, err := librametadata.ETDWorkFromBytes(pl)
+				if err != nil {
+					libracommon.LogError("unmarshaling metadata from easystore object [%s/%s] (%s)", eso.Namespace(), eso.Id(), err.Error())
+					return libracommon.Permanent(err)
+				}
+
+				// is this a title update
+				if md.Title != o.Title {
+					libracommon.LogInfo("title update for unpublished work [%s/%s]", eso.Namespace(), eso.Id())
+					previous := md.Title
+					md.Title = o.Title
 
-					md, err := librametadata.ETDWorkFromBytes(pl)
+					// An ETDWork does not serialize the same way as an EasyStoreMetadata object
+					// does when being managed by json.Marshal/json.Unmarshal so we wrap it in an object that
+					// behaves appropriately
+					pl, err = md.Payload()
 					if err != nil {
-						fmt.Printf("ERROR: unmarshaling metadata from easystore object [%s/%s], continuing (%s)\n", eso.Namespace(), eso.Id(), err.Error())
-						returnErr = err
-						continue
+						libracommon.LogError("serializing ETDWork: %s", err.Error())
+						return libracommon.Permanent(err)
 					}
 
-					// is this a title update
-					if md.Title != o.Title {
-						fmt.Printf("INFO: title update for unpublished work [%s/%s]\n", eso.Namespace(), eso.Id())
-						previous := md.Title
-						md.Title = o.Title
-
-						// An ETDWork does not serialize the same way as an EasyStoreMetadata object
-						// does when being managed by json.Marshal/json.Unmarshal so we wrap it in an object that
-						// behaves appropriately
-						pl, err = md.Payload()
-						if err != nil {
-							fmt.Printf("ERROR: serializing ETDWork: %s, continuing\n", err.Error())
-							returnErr = err
-							continue
-						}
-
-						eso.SetMetadata(uvaeasystore.NewEasyStoreMetadata(md.MimeType(), pl))
-						err = putEasystoreObject(es, eso, uvaeasystore.Metadata)
-						if err != nil {
-							fmt.Printf("ERROR: updating easystore object [%s/%s], continuing (%s)\n", eso.Namespace(), eso.Id(), err.Error())
-							returnErr = err
-							continue
-						}
-						// audit this change
-						_ = pubAuditEvent(messageBus, eso, auditWho, "title", previous, o.Title)
+					eso.SetMetadata(uvaeasystore.NewEasyStoreMetadata(md.MimeType(), pl))
+					err = libracommon.PutEasystoreObject(ctx, es, eso, uvaeasystore.Metadata)
+					if err != nil {
+						libracommon.LogError("updating easystore object [%s/%s] (%s)", eso.Namespace(), eso.Id(), err.Error())
+						return err
 					}
-				} else {
-					fmt.Printf("ERROR: sis update but work has missing metadata [%s/%s], ignoring\n", eso.Namespace(), eso.Id())
+					// audit this change
+					_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "title", previous, o.Title)
 				}
 			} else {
-				fmt.Printf("WARNING: sis update for published work [%s/%s], ignoring\n", eso.Namespace(), eso.Id())
+				libracommon.LogError("sis update but work has missing metadata [%s/%s], ignoring", eso.Namespace(), eso.Id())
 			}
 		} else {
-			// we did not find an existing one, create a new easystore object
-			eso := uvaeasystore.NewEasyStoreObject(libraEtdNamespace, "")
-
-			// add some fields
-			fields["author"] = o.ComputingId
-			fields["depositor"] = o.ComputingId
-			fields["create-date"] = time.Now().UTC().Format(time.RFC3339)
-			fields["source-id"] = sourceId
-			fields["source"] = "sis"
-			fields["draft"] = "true"
-			eso.SetFields(fields)
-
-			meta := librametadata.ETDWork{}
-			meta.Program = o.Department
-			meta.Degree = o.Degree
-			meta.Title = o.Title
-			meta.Author = librametadata.ContributorData{
-				ComputeID:   o.ComputingId,
-				FirstName:   o.FirstName,
-				LastName:    o.LastName,
-				Department:  o.Department,
-				Institution: "University of Virginia",
-			}
+			libracommon.LogWarning("sis update for published work [%s/%s], ignoring", eso.Namespace(), eso.Id())
+		}
+	} else {
+		// we did not find an existing one, create a new easystore object
+		eso := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, "")
 
-			// An ETDWork does not serialize the same way as an EasyStoreMetadata object
-			// does when being managed by json.Marshal/json.Unmarshal so we wrap it in an object that
-			// behaves appropriately
-			pl, err := meta.Payload()
-			if err != nil {
-				fmt.Printf("ERROR: serializing ETDWork: %s, continuing\n", err.Error())
-				returnErr = err
-				continue
-			}
-			eso.SetMetadata(uvaeasystore.NewEasyStoreMetadata(meta.MimeType(), pl))
-
-			// create the new object
-			err = createEasystoreObject(es, eso)
-			if err != nil {
-				fmt.Printf("ERROR: creating easystore object, continuing (%s)\n", err.Error())
-				returnErr = err
-				continue
-			}
+		// add some fields
+		fields["author"] = o.ComputingId
+		fields["depositor"] = o.ComputingId
+		fields["create-date"] = deps.Now().UTC().Format(time.RFC3339)
+		fields["source-id"] = sourceId
+		fields["source"] = "sis"
+		fields["draft"] = "true"
+		eso.SetFields(fields)
+
+		meta := librametadata.ETDWork{}
+		meta.Program = o.Department
+		meta.Degree = o.Degree
+		meta.Title = o.Title
+		meta.Author = librametadata.ContributorData{
+			ComputeID:   o.ComputingId,
+			FirstName:   o.FirstName,
+			LastName:    o.LastName,
+			Department:  o.Department,
+			Institution: "University of Virginia",
+		}
 
-			// audit this set of changes
-			_ = pubAuditEvent(messageBus, eso, auditWho, "create-date", "", fields["create-date"])
-			_ = pubAuditEvent(messageBus, eso, auditWho, "program", "", meta.Program)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "degree", "", meta.Degree)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "title", "", meta.Title)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "author.cid", "", meta.Author.ComputeID)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "author.firstname", "", meta.Author.FirstName)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "author.lastname", "", meta.Author.LastName)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "author.department", "", meta.Author.Department)
-			_ = pubAuditEvent(messageBus, eso, auditWho, "author.institution", "", meta.Author.Institution)
+		// An ETDWork does not serialize the same way as an EasyStoreMetadata object
+		// does when being managed by json.Marshal/json.Unmarshal so we wrap it in an object that
+		// behaves appropriately
+		pl, err := meta.Payload()
+		if err != nil {
+			libracommon.LogError("serializing ETDWork: %s", err.Error())
+			return libracommon.Permanent(err)
 		}
+		eso.SetMetadata(uvaeasystore.NewEasyStoreMetadata(meta.MimeType(), pl))
+
+		// create the new object
+		err = libracommon.CreateEasystoreObject(ctx, es, eso)
+		if err != nil {
+			libracommon.LogError("creating easystore object (%s)", err.Error())
+			return err
+		}
+
+		// audit this set of changes
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "create-date", "", fields["create-date"])
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "program", "", meta.Program)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "degree", "", meta.Degree)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "title", "", meta.Title)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "author.cid", "", meta.Author.ComputeID)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "author.firstname", "", meta.Author.FirstName)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "author.lastname", "", meta.Author.LastName)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "author.department", "", meta.Author.Department)
+		_ = libracommon.PubAuditEvent(ctx, messageBus, eso, auditWho, "author.institution", "", meta.Author.Institution)
 	}
 
-	return returnErr
+	return nil
 }
 
-func inboundSis(config *Config, last string, auth string, client *http.Client) ([]InboundSisItem, error) {
+func inboundSis(ctx context.Context, config *Config, last string, auth string, client *http.Client) ([]InboundSisItem, error) {
 
 	// substitute values into url
 	url := strings.Replace(config.SisIngestUrl, "{:last}", last, 1)
 	url = strings.Replace(url, "{:auth}", auth, 1)
 
-	payload, err := httpGet(client, url)
+	payload, err := libracommon.HttpGet(ctx, client, url)
 	if err != nil {
 		// special case of no items
-		if strings.Contains(err.Error(), "HTTP 404") == true {
+		if libracommon.IsHttpNotFound(err) == true {
 			return make([]InboundSisItem, 0), nil
 		}
 		return nil, err
@@ -195,26 +303,14 @@ func inboundSis(config *Config, last string, auth string, client *http.Client) (
 	resp := InboundSisResponse{}
 	err = json.Unmarshal(payload, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of InboundSisResponse (%s)\n", err.Error())
+		libracommon.LogError("json unmarshal of InboundSisResponse (%s)", err.Error())
 		return nil, err
 	}
 
-	fmt.Printf("INFO: received %d SIS item(s)\n", len(resp.Details))
+	libracommon.LogInfo("received %d SIS item(s)", len(resp.Details))
 	return resp.Details, nil
 }
 
-func lastSisId(objs []InboundSisItem) string {
-	last := "0"
-	for _, in := range objs {
-		l, _ := strconv.Atoi(last)
-		c, _ := strconv.Atoi(in.InboundId)
-		if c > l {
-			last = in.InboundId
-		}
-	}
-	return last
-}
-
 //
 // end of file
 //
@@ -0,0 +1,195 @@
+//
+// ingest state, the quarantine list of inbound SIS items. An item that fails permanently is
+// quarantined so that it no longer blocks the items behind it, quarantined items are retried
+// with a growing delay until they succeed or run out of attempts and are dropped
+//
+
+package libraingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// the most quarantined items we keep, each takes about 70 bytes and parameter values are
+// limited to 4KB
+var maxQuarantined = 50
+
+// how often a quarantined item is attempted before it is dropped, the first attempt is the
+// failure that quarantined it
+var maxQuarantineAttempts = 10
+
+// the delay before the first retry, doubling with each attempt up to the maximum
+var quarantineRetryDelay = 15 * time.Minute
+var quarantineMaxDelay = 24 * time.Hour
+
+type ingestState struct {
+	Quarantined []quarantinedItem `json:"quarantined,omitempty"` // inbound items needing attention
+	changed     bool
+}
+
+// quarantinedItem is an inbound item that failed permanently
+type quarantinedItem struct {
+	Id          string    `json:"id"`           // the inbound id
+	Attempts    int       `json:"attempts"`     // how often it has failed
+	LastAttempt time.Time `json:"last_attempt"` // when it last failed
+}
+
+// UnmarshalJSON also accepts the bare ids of earlier states, they are retried on the next run
+func (qi *quarantinedItem) UnmarshalJSON(buf []byte) error {
+	var id string
+	if json.Unmarshal(buf, &id) == nil {
+		*qi = quarantinedItem{Id: id}
+		return nil
+	}
+	type item quarantinedItem
+	return json.Unmarshal(buf, (*item)(qi))
+}
+
+// due reports whether the item is ready to be retried, the delay doubles with each attempt
+func (qi quarantinedItem) due(now time.Time) bool {
+	delay := quarantineRetryDelay
+	for n := 1; n < qi.Attempts && delay < quarantineMaxDelay; n++ {
+		delay *= 2
+	}
+	return now.Before(qi.LastAttempt.Add(min(delay, quarantineMaxDelay))) == false
+}
+
+// loadIngestState gets the state, a state that has never been saved is empty
+func loadIngestState(ctx context.Context, ssm libracommon.ParameterStore, name string) (*ingestState, error) {
+
+	state := ingestState{}
+	value, err := ssm.GetParameter(ctx, name)
+	if err != nil {
+		if errors.Is(err, libracommon.ErrParameterNotFound) == true {
+			return &state, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(value), &state)
+	if err != nil {
+		libracommon.LogError("unmarshaling ingest state (%s)", err.Error())
+		return nil, libracommon.Permanent(err)
+	}
+	return &state, nil
+}
+
+// save the state if it has changed
+func (is *ingestState) save(ctx context.Context, ssm libracommon.ParameterStore, name string) error {
+	if is.changed == false {
+		return nil
+	}
+	buf, err := json.Marshal(is)
+	if err != nil {
+		return err
+	}
+	err = ssm.SetParameter(ctx, name, string(buf))
+	if err != nil {
+		return err
+	}
+	is.changed = false
+	return nil
+}
+
+// quarantine the item after its first failure, false when the quarantine is full. A full
+// quarantine holds the cursor until some items are dealt with
+func (is *ingestState) quarantine(inboundId string, now time.Time) bool {
+	if is.find(inboundId) != -1 {
+		return true
+	}
+	if len(is.Quarantined) >= maxQuarantined {
+		return false
+	}
+	is.Quarantined = append(is.Quarantined, quarantinedItem{Id: inboundId, Attempts: 1, LastAttempt: now})
+	is.changed = true
+	return true
+}
+
+// failed records another failed attempt, false when the item has run out of attempts and is
+// dropped from the quarantine
+func (is *ingestState) failed(inboundId string, now time.Time) bool {
+	idx := is.find(inboundId)
+	if idx == -1 {
+		return true
+	}
+	is.changed = true
+	is.Quarantined[idx].Attempts++
+	is.Quarantined[idx].LastAttempt = now
+	if is.Quarantined[idx].Attempts < maxQuarantineAttempts {
+		return true
+	}
+	is.drop(inboundId)
+	return false
+}
+
+// succeeded removes the item from quarantine
+func (is *ingestState) succeeded(inboundId string) {
+	if is.find(inboundId) != -1 {
+		libracommon.LogInfo("SIS # %s processed, removing it from quarantine", inboundId)
+		is.drop(inboundId)
+	}
+}
+
+// ids returns the quarantined inbound ids
+func (is *ingestState) ids() []string {
+	ids := make([]string, 0, len(is.Quarantined))
+	for _, qi := range is.Quarantined {
+		ids = append(ids, qi.Id)
+	}
+	return ids
+}
+
+func (is *ingestState) find(inboundId string) int {
+	return slices.IndexFunc(is.Quarantined, func(qi quarantinedItem) bool { return qi.Id == inboundId })
+}
+
+func (is *ingestState) drop(inboundId string) {
+	is.Quarantined = slices.DeleteFunc(is.Quarantined, func(qi quarantinedItem) bool { return qi.Id == inboundId })
+	is.changed = true
+}
+
+// sortSis orders the items by inbound id, items are processed (and checkpointed) in order
+func sortSis(objs []InboundSisItem) {
+	slices.SortStableFunc(objs, func(a, b InboundSisItem) int {
+		ai, _ := strconv.Atoi(a.InboundId)
+		bi, _ := strconv.Atoi(b.InboundId)
+		return ai - bi
+	})
+}
+
+// sisGaps returns the ranges of inbound ids missing between the cursor and the sorted items,
+// the ids are expected to be consecutive
+func sisGaps(last string, objs []InboundSisItem) []string {
+
+	gaps := make([]string, 0)
+	previous, err := strconv.Atoi(last)
+	if err != nil {
+		return gaps
+	}
+	for _, o := range objs {
+		current, err := strconv.Atoi(o.InboundId)
+		if err != nil {
+			continue
+		}
+		switch {
+		case current == previous+2:
+			gaps = append(gaps, strconv.Itoa(previous+1))
+		case current > previous+2:
+			gaps = append(gaps, fmt.Sprintf("%d-%d", previous+1, current-1))
+		}
+		previous = max(previous, current)
+	}
+	return gaps
+}
+
+//
+// end of file
+//
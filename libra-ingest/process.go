@@ -2,29 +2,36 @@
 // main message processing
 //
 
-package main
+package libraingest
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"strings"
 
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -35,71 +42,95 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// init the parameter client
-	ssm, err := newParameterClient()
+	ssm, err := deps.Parameters()
 	if err != nil {
-		fmt.Printf("ERROR: creating ssm client (%s)\n", err.Error())
+		libracommon.LogError("creating ssm client (%s)", err.Error())
 		return err
 	}
 
 	// get our state information
-	sisLastProcessed, err := getParameter(ssm, cfg.SisIngestStateName)
+	sisLastProcessed, err := ssm.GetParameter(ctx, cfg.SisIngestStateName)
+	if err != nil {
+		return err
+	}
+	libracommon.LogInfo("last SIS = [%s]", sisLastProcessed)
+
+	// and the item failures
+	state, err := loadIngestState(ctx, ssm, cfg.SisQuarantineStateName)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("INFO: last SIS = [%s]\n", sisLastProcessed)
+	if len(state.Quarantined) != 0 {
+		libracommon.LogWarning("%d SIS item(s) in quarantine %v", len(state.Quarantined), state.ids())
+	}
 
 	// get a new http client and get an auth token
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
-	token, err := getAuthToken(httpClient, cfg.MintAuthUrl)
+	token, err := libracommon.GetAuthToken(ctx, httpClient, cfg.MintAuthUrl)
 	if err != nil {
 		return err
 	}
 
 	// get inbound SIS items
-	sisList, err := inboundSis(cfg, sisLastProcessed, token, httpClient)
+	sisList, err := inboundSis(ctx, cfg, sisLastProcessed, token, httpClient)
 	if err != nil {
 		return err
 	}
 
 	// bail out if nothing to do
-	if len(sisList) == 0 {
-		fmt.Printf("INFO: nothing to do, terminating early\n")
+	if len(sisList) == 0 && len(state.Quarantined) == 0 {
+		libracommon.LogInfo("nothing to do, terminating early")
 		return nil
 	}
 
 	// easystore access
-	es, err := newEasystoreProxy(cfg)
+	es, err := deps.Easystore(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer es.Close()
 
-	// process inbound SIS items
-	err = processSis(cfg, sisList, es)
+	// audit events for the changes
+	messageBus, err := deps.EventBus(cfg.BusName, auditWho)
 	if err != nil {
+		libracommon.LogError("creating event bus client (%s)", err.Error())
 		return err
 	}
 
-	// get the last one processed and update the state if necessary
-	if len(sisList) != 0 {
-		sisLast := lastSisId(sisList)
-		if sisLastProcessed != sisLast {
-			fmt.Printf("INFO: last SIS = [%s]\n", sisLast)
-			err = setParameter(ssm, cfg.SisIngestStateName, sisLast)
-			if err != nil {
-				return err
-			}
+	// give the quarantined items another chance, they may have been fixed
+	if len(state.Quarantined) != 0 {
+		err = retryQuarantined(ctx, cfg, es, messageBus, ssm, state, token, httpClient)
+		if err != nil {
+			return err
 		}
 	}
+	if len(sisList) == 0 {
+		libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
+		return nil
+	}
+
+	// items are checkpointed in order, missing ids are reported but do not stop the ingest
+	sortSis(sisList)
+	gaps := sisGaps(sisLastProcessed, sisList)
+	if len(gaps) != 0 {
+		libracommon.LogWarning("inbound SIS ids missing after [%s]: %s", sisLastProcessed, strings.Join(gaps, ", "))
+		libracommon.IncrementCounter("SisInboundGaps", nil)
+	}
+
+	// process inbound SIS items, the state is updated as each is processed
+	err = processSis(ctx, cfg, sisList, es, messageBus, ssm, state)
+	if err != nil {
+		return err
+	}
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
@@ -0,0 +1,307 @@
+//
+// Process() end to end against the fakes, the auth and SIS services are an httptest service
+//
+
+package libraingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+var stateName = "/test/sis-ingest"
+var quarantineName = stateName + "-quarantine"
+
+// setupProcess replaces the dependencies with fakes and configures Process() to use the
+// service, the cursor starts at the supplied id
+func setupProcess(t *testing.T, cursor string) (*fakes.Dependencies, *fakes.HttpService) {
+
+	fd := fakes.New()
+	saved := deps
+	deps = fd.Deps()
+	t.Cleanup(func() { deps = saved })
+	libracommon.SetMetricsSink(fakes.NewMetricsSink())
+
+	svc := fakes.NewHttpService()
+	t.Cleanup(svc.Close)
+	svc.Respond("GET", "/auth", 200, `{"token":"a-token"}`)
+
+	t.Setenv("MINT_AUTH_URL", svc.URL+"/auth")
+	t.Setenv("USER_INFO_URL", svc.URL+"/user/{:id}?auth={:auth}")
+	t.Setenv("SIS_INGEST_URL", svc.URL+"/sis/{:last}?auth={:auth}")
+	t.Setenv("SIS_INGEST_STATE_NAME", stateName)
+	t.Setenv("SIS_INGEST_QUARANTINE_NAME", "")
+	t.Setenv("ES_PROXY_URL", "http://easystore.example.edu")
+	t.Setenv("MESSAGE_BUS", "test-bus")
+
+	_ = fd.Parameters.SetParameter(context.Background(), stateName, cursor)
+	return fd, svc
+}
+
+// sisResponse is the SIS service response for the items with the inbound ids
+func sisResponse(inboundIds ...int) string {
+	resp := InboundSisResponse{Status: 200}
+	for _, id := range inboundIds {
+		resp.Details = append(resp.Details, InboundSisItem{
+			InboundId:   fmt.Sprintf("%d", id),
+			Id:          fmt.Sprintf("%d", 1000+id),
+			ComputingId: fmt.Sprintf("st%dz", id),
+			FirstName:   "Sam",
+			LastName:    "Student",
+			Title:       fmt.Sprintf("Thesis %d", id),
+			Department:  "History",
+			Degree:      "MA (Master of Arts)",
+		})
+	}
+	buf, _ := json.Marshal(resp)
+	return string(buf)
+}
+
+func scheduleEvent(t *testing.T) json.RawMessage {
+	ev := uvalibrabus.UvaBusEvent{EventName: "schedule.sis.ingest", Namespace: libracommon.LibraEtdNamespace, Identifier: "none"}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	return pl
+}
+
+// works returns the source id of each work in the easystore
+func works(t *testing.T, fd *fakes.Dependencies) map[string]uvaeasystore.EasyStoreObjectFields {
+	objs, err := fd.Easystore.ObjectGetByFields(libracommon.LibraEtdNamespace, nil, uvaeasystore.Fields)
+	if err != nil {
+		t.Fatalf("listing works (%s)", err.Error())
+	}
+	found := make(map[string]uvaeasystore.EasyStoreObjectFields)
+	for {
+		obj, err := objs.Next()
+		if err != nil {
+			break
+		}
+		found[obj.Fields()["source-id"]] = obj.Fields()
+	}
+	return found
+}
+
+func parameter(fd *fakes.Dependencies, name string) string {
+	value, _ := fd.Parameters.GetParameter(context.Background(), name)
+	return value
+}
+
+// addDraft adds a draft work for the SIS id with the metadata payload
+func addDraft(fd *fakes.Dependencies, sisId string, metadata string) {
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, "oid:draft-"+sisId)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["source-id"] = "sis:" + sisId
+	fields["draft"] = "true"
+	obj.SetFields(fields)
+	obj.SetMetadata(uvaeasystore.NewEasyStoreMetadata("application/json", []byte(metadata)))
+	fd.Easystore.Add(obj)
+}
+
+// easystore whose object creation fails, as it does during an outage
+type unavailableEasystore struct {
+	*fakes.Easystore
+}
+
+func (ue unavailableEasystore) ObjectCreate(obj uvaeasystore.EasyStoreObject) (uvaeasystore.EasyStoreObject, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestProcessCreatesWorks(t *testing.T) {
+	fd, svc := setupProcess(t, "10")
+	svc.Respond("GET", "/sis/10", 200, sisResponse(12, 11))
+
+	err := Process(context.Background(), "msg-1", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	found := works(t, fd)
+	for _, sourceId := range []string{"sis:1011", "sis:1012"} {
+		fields, exists := found[sourceId]
+		if exists == false {
+			t.Errorf("expected a work for %s", sourceId)
+			continue
+		}
+		if fields["draft"] != "true" || fields["source"] != "sis" {
+			t.Errorf("unexpected fields for %s: %v", sourceId, fields)
+		}
+	}
+	if cursor := parameter(fd, stateName); cursor != "12" {
+		t.Errorf("expected the cursor to be 12, got [%s]", cursor)
+	}
+	if len(fd.Bus.Events()) == 0 {
+		t.Errorf("expected audit events")
+	}
+}
+
+func TestProcessQuarantinesPermanentFailures(t *testing.T) {
+	fd, svc := setupProcess(t, "10")
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11, 12))
+	// the existing draft for item 11 cannot be read
+	addDraft(fd, "1011", "not json")
+
+	err := Process(context.Background(), "msg-2", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	if _, exists := works(t, fd)["sis:1012"]; exists == false {
+		t.Errorf("expected the item after the failure to be processed")
+	}
+	if cursor := parameter(fd, stateName); cursor != "12" {
+		t.Errorf("expected the cursor to be 12, got [%s]", cursor)
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != `{"quarantined":[{"id":"11","attempts":1,"last_attempt":"2024-01-01T12:00:00Z"}]}` {
+		t.Errorf("expected item 11 to be quarantined, got [%s]", quarantine)
+	}
+}
+
+func TestProcessHoldsCursorOnTransientFailures(t *testing.T) {
+	fd, svc := setupProcess(t, "10")
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11, 12))
+	deps.Easystore = func(endpoint string) (uvaeasystore.EasyStore, error) {
+		return unavailableEasystore{fd.Easystore}, nil
+	}
+
+	err := Process(context.Background(), "msg-3", "test", scheduleEvent(t))
+	if err == nil || libracommon.IsPermanent(err) == true {
+		t.Fatalf("expected a transient failure, got %v", err)
+	}
+	if cursor := parameter(fd, stateName); cursor != "10" {
+		t.Errorf("expected the cursor to stay at 10, got [%s]", cursor)
+	}
+	if quarantine := parameter(fd, quarantineName); len(quarantine) != 0 {
+		t.Errorf("expected nothing to be quarantined, got [%s]", quarantine)
+	}
+}
+
+func TestProcessRetriesQuarantinedItems(t *testing.T) {
+	fd, svc := setupProcess(t, "12")
+	// a quarantine saved before attempts were recorded
+	_ = fd.Parameters.SetParameter(context.Background(), quarantineName, `{"quarantined":["11"]}`)
+	// nothing new, item 11 is still available from SIS
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11, 12))
+
+	err := Process(context.Background(), "msg-4", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	if _, exists := works(t, fd)["sis:1011"]; exists == false {
+		t.Errorf("expected the quarantined item to be processed")
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != `{}` {
+		t.Errorf("expected the quarantine to be empty, got [%s]", quarantine)
+	}
+	if cursor := parameter(fd, stateName); cursor != "12" {
+		t.Errorf("expected the cursor to stay at 12, got [%s]", cursor)
+	}
+}
+
+func TestProcessKeepsQuarantineWhenFull(t *testing.T) {
+	fd, svc := setupProcess(t, "10")
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11))
+	addDraft(fd, "1011", "not json")
+
+	saved := maxQuarantined
+	maxQuarantined = 1
+	t.Cleanup(func() { maxQuarantined = saved })
+	// item 5 is not due for another attempt
+	quarantined := `{"quarantined":[{"id":"5","attempts":1,"last_attempt":"2024-01-01T11:59:00Z"}]}`
+	_ = fd.Parameters.SetParameter(context.Background(), quarantineName, quarantined)
+
+	err := Process(context.Background(), "msg-5", "test", scheduleEvent(t))
+	if err == nil {
+		t.Fatalf("expected a failure when the quarantine is full")
+	}
+	if cursor := parameter(fd, stateName); cursor != "10" {
+		t.Errorf("expected the cursor to stay at 10, got [%s]", cursor)
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != quarantined {
+		t.Errorf("expected the quarantine to be unchanged, got [%s]", quarantine)
+	}
+}
+
+func TestProcessBacksOffQuarantinedItems(t *testing.T) {
+	fd, svc := setupProcess(t, "12")
+	// item 11 failed three times, the last half an hour ago, it is due an hour after that
+	quarantined := `{"quarantined":[{"id":"11","attempts":3,"last_attempt":"2024-01-01T11:30:00Z"}]}`
+	_ = fd.Parameters.SetParameter(context.Background(), quarantineName, quarantined)
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11, 12))
+
+	err := Process(context.Background(), "msg-6", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	for _, r := range svc.Requests() {
+		if r.Path == "/sis/10" {
+			t.Errorf("expected item 11 not to be retried yet")
+		}
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != quarantined {
+		t.Errorf("expected the quarantine to be unchanged, got [%s]", quarantine)
+	}
+
+	// once due it is retried, and the failure recorded
+	addDraft(fd, "1011", "not json")
+	fd.Clock.Advance(time.Hour)
+	err = Process(context.Background(), "msg-7", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != `{"quarantined":[{"id":"11","attempts":4,"last_attempt":"2024-01-01T13:00:00Z"}]}` {
+		t.Errorf("expected the attempt to be recorded, got [%s]", quarantine)
+	}
+}
+
+func TestProcessDropsExhaustedQuarantinedItems(t *testing.T) {
+	fd, svc := setupProcess(t, "12")
+	sink := fakes.NewMetricsSink()
+	libracommon.SetMetricsSink(sink)
+	// item 11 is on its last attempt and still fails, item 7 is no longer available
+	_ = fd.Parameters.SetParameter(context.Background(), quarantineName, fmt.Sprintf(
+		`{"quarantined":[{"id":"11","attempts":%d,"last_attempt":"2023-12-01T00:00:00Z"},{"id":"7","attempts":1,"last_attempt":"2023-12-01T00:00:00Z"}]}`, maxQuarantineAttempts-1))
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11, 12))
+	addDraft(fd, "1011", "not json")
+
+	err := Process(context.Background(), "msg-8", "test", scheduleEvent(t))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if quarantine := parameter(fd, quarantineName); quarantine != `{"quarantined":[{"id":"7","attempts":2,"last_attempt":"2024-01-01T12:00:00Z"}]}` {
+		t.Errorf("expected item 11 to be dropped, got [%s]", quarantine)
+	}
+	if sink.Sum("SisQuarantineDropped") != 1 {
+		t.Errorf("expected the drop to be counted")
+	}
+}
+
+func TestProcessEventBusFailure(t *testing.T) {
+	fd, svc := setupProcess(t, "10")
+	svc.Respond("GET", "/sis/10", 200, sisResponse(11))
+	deps.EventBus = func(eventBus string, eventSource string) (uvalibrabus.UvaBus, error) {
+		return nil, fmt.Errorf("no bus")
+	}
+
+	err := Process(context.Background(), "msg-9", "test", scheduleEvent(t))
+	if err == nil {
+		t.Fatalf("expected the event bus failure to be returned")
+	}
+	if cursor := parameter(fd, stateName); cursor != "10" {
+		t.Errorf("expected the cursor to stay at 10, got [%s]", cursor)
+	}
+}
+
+//
+// end of file
+//
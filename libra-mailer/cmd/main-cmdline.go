@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libramailer "github.com/uvalib/libra-mailer"
+)
+
+func main() {
+	libracommon.RunCmdline(libramailer.Process)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libramailer "github.com/uvalib/libra-mailer"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libracommon.Idempotent(libramailer.Deps(), libramailer.Process))
+}
+
+//
+// end of file
+//
@@ -1,36 +1,36 @@
-package main
+package libramailer
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 
 	// service endpoint configuration
-	MintAuthUrl string // mint auth token endpoint
-	UserInfoUrl string // the user information service
+	MintAuthUrl string `env:"MINT_AUTH_URL" required:"true"` // mint auth token endpoint
+	UserInfoUrl string `env:"USER_INFO_URL" required:"true"` // the user information service
 
 	// configuration needed for mail content
-	EtdBaseUrl  string // etd application base URL
+	EtdBaseUrl  string `env:"ETD_BASE_URL" required:"true"` // etd application base URL
 	OpenBaseUrl string // open application base URL
 
 	// mailer configuration
-	EmailSender    string // the email sender
-	SendEmail      bool   // do we send or just log
-	DebugRecipient string // the debug recipient
+	EmailSender    string `env:"EMAIL_SENDER" required:"true"` // the email sender
+	SendEmail      bool   `env:"EMAIL_SEND" required:"true"`   // do we send or just log
+	DebugRecipient string `env:"DEBUG_RECIPIENT"`              // the debug recipient
 
 	// SMTP configuration
-	SMTPHost string // SMTP hostname
-	SMTPPort int    // SMTP port number
-	SMTPUser string // SMTP username
-	SMTPPass string // SMTP password
+	SMTPHost string `env:"SMTP_HOST" required:"true"`   // SMTP hostname
+	SMTPPort int    `env:"SMTP_PORT" required:"true"`   // SMTP port number
+	SMTPUser string `env:"SMTP_USER"`                   // SMTP username
+	SMTPPass string `env:"SMTP_PASSWORD" secret:"true"` // SMTP password
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
-	BusName string // the message bus name
+	BusName string `env:"MESSAGE_BUS"` // the message bus name
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -39,66 +39,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.MintAuthUrl, err = ensureSetAndNonEmpty("MINT_AUTH_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
-	cfg.UserInfoUrl, err = ensureSetAndNonEmpty("USER_INFO_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.EtdBaseUrl, err = ensureSetAndNonEmpty("ETD_BASE_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.SMTPHost, err = ensureSetAndNonEmpty("SMTP_HOST")
-	if err != nil {
-		return nil, err
-	}
-	cfg.SMTPPort, err = envToInt("SMTP_PORT")
-	if err != nil {
-		return nil, err
-	}
-	cfg.SMTPUser = envWithDefault("SMTP_USER", "")
-	cfg.SMTPPass = envWithDefault("SMTP_PASSWORD", "")
-
-	cfg.EmailSender, err = ensureSetAndNonEmpty("EMAIL_SENDER")
-	if err != nil {
-		return nil, err
-	}
-	cfg.SendEmail, err = envToBool("EMAIL_SEND")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.DebugRecipient = envWithDefault("DEBUG_RECIPIENT", "")
-
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] MintAuthUrl    = [%s]\n", cfg.MintAuthUrl)
-	fmt.Printf("[conf] UserInfoUrl    = [%s]\n", cfg.UserInfoUrl)
-
-	fmt.Printf("[conf] EtdBaseUrl     = [%s]\n", cfg.EtdBaseUrl)
-
-	fmt.Printf("[conf] SMTPHost       = [%s]\n", cfg.SMTPHost)
-	fmt.Printf("[conf] SMTPPort       = [%d]\n", cfg.SMTPPort)
-	fmt.Printf("[conf] SMTPUser       = [%s]\n", cfg.SMTPUser)
-	fmt.Printf("[conf] SMTPPass       = [%s]\n", cfg.SMTPPass)
-
-	fmt.Printf("[conf] EmailSender    = [%s]\n", cfg.EmailSender)
-	fmt.Printf("[conf] SendEmail      = [%t]\n", cfg.SendEmail)
-	fmt.Printf("[conf] DebugRecipient = [%s]\n", cfg.DebugRecipient)
 
-	fmt.Printf("[conf] EsProxyUrl     = [%s]\n", cfg.EsProxyUrl)
-	fmt.Printf("[conf] BusName        = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,13 +2,14 @@
 //
 //
 
-package main
+package libramailer
 
 import (
 	"bytes"
 	"embed"
 	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	librametadata "github.com/uvalib/libra-metadata"
 	"text/template"
 	"time"
@@ -36,7 +37,7 @@ type Work struct {
 	Title   string // work title
 }
 
-func renderEmailSubjectAndBody(cfg *Config, theType emailType, recipient *UserDetails, obj uvaeasystore.EasyStoreObject) (string, string, error) {
+func renderEmailSubjectAndBody(cfg *Config, theType emailType, recipient *libracommon.UserDetails, obj uvaeasystore.EasyStoreObject) (string, string, error) {
 
 	var templateFile string
 	var subject string
@@ -58,7 +59,7 @@ func renderEmailSubjectAndBody(cfg *Config, theType emailType, recipient *UserDe
 		subject = "Successful deposit of your student's thesis"
 
 	default:
-		return "", "", fmt.Errorf("unsupported email type")
+		return "", "", libracommon.Permanent(fmt.Errorf("unsupported email type"))
 	}
 
 	// read the template
@@ -135,8 +136,8 @@ func extractEtdAtributes(obj uvaeasystore.EasyStoreObject) (*Work, error) {
 
 	// extract the metadata
 	if obj.Metadata() == nil {
-		fmt.Printf("ERROR: unable to get metadata payload for ns/oid [%s/%s]\n", obj.Namespace(), obj.Id())
-		return nil, ErrNoMetadata
+		libracommon.LogError("unable to get metadata payload for ns/oid [%s/%s]", obj.Namespace(), obj.Id())
+		return nil, libracommon.ErrNoMetadata
 	}
 
 	md := obj.Metadata()
@@ -146,7 +147,7 @@ func extractEtdAtributes(obj uvaeasystore.EasyStoreObject) (*Work, error) {
 	}
 	meta, err := librametadata.ETDWorkFromBytes(pl)
 	if err != nil {
-		return nil, err
+		return nil, libracommon.Permanent(err)
 	}
 
 	// populate the work
@@ -169,12 +170,12 @@ func determineAvailability(fields uvaeasystore.EasyStoreObjectFields) string {
 		format := "2006-01-02T15:04:05Z"
 		dt, err := time.Parse(format, fields["embargo-release"])
 		if err != nil {
-			fmt.Printf("ERROR: cannot decode embargo release date (%s)\n", fields["embargo-release"])
+			libracommon.LogError("cannot decode embargo release date (%s)", fields["embargo-release"])
 			return ava + " (cannot decode embargo release date)"
 		}
 
 		// are we still under embargo
-		if dt.After(time.Now()) {
+		if dt.After(deps.Now()) {
 			ava = fmt.Sprintf("public access on %s", dt.Format("January 02, 2006"))
 		}
 	}
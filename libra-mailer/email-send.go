@@ -1,10 +1,11 @@
-package main
+package libramailer
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"gopkg.in/gomail.v2"
-	"log"
 	"time"
 )
 
@@ -30,24 +31,28 @@ func sendEmail(cfg *Config, subject string, recipient string, cc []string, body
 	mail.SetBody("text/html", body)
 
 	if cfg.SendEmail == false {
-		fmt.Printf("INFO: Email is in debug mode. Logging message instead of sending\n")
-		fmt.Printf("INFO: ==========================================================\n")
-		_, _ = mail.WriteTo(log.Writer())
-		fmt.Printf("\nINFO: ==========================================================\n")
+		libracommon.LogInfo("Email is in debug mode. Logging message instead of sending")
+		var buf bytes.Buffer
+		_, _ = mail.WriteTo(&buf)
+		libracommon.LogInfo("%s", buf.String())
 		return nil
 	}
 
 	var dialer gomail.Dialer
-	fmt.Printf("INFO: sending email to %s (%s)\n", recipient, subject)
+	libracommon.LogInfo("sending email to %s (%s)", recipient, subject)
 	if cfg.SMTPPass != "" {
-		fmt.Printf("INFO: sending email with auth\n")
+		libracommon.LogInfo("sending email with auth")
 		dialer = gomail.Dialer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUser, Password: cfg.SMTPPass}
 	} else {
-		fmt.Printf("INFO: sending email with no auth\n")
+		libracommon.LogInfo("sending email with no auth")
 		dialer = gomail.Dialer{Host: cfg.SMTPHost, Port: cfg.SMTPPort}
 	}
 	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-	return dialAndSend(dialer, mail)
+	err := dialAndSend(dialer, mail)
+	if err == nil {
+		libracommon.IncrementCounter("EmailsSent", nil)
+	}
+	return err
 }
 
 func dialAndSend(dialer gomail.Dialer, mail *gomail.Message) error {
@@ -65,12 +70,12 @@ func dialAndSend(dialer gomail.Dialer, mail *gomail.Message) error {
 
 		// break when tried too many times
 		if currentCount >= retryCount {
-			fmt.Printf("ERROR: email send failed with error (%s), giving up\n", err)
+			libracommon.LogError("email send failed with error (%s), giving up", err)
 			err = fmt.Errorf("email send failed with error (%s), giving up", err)
 			return err
 		}
 
-		fmt.Printf("WARNING: email send failed with error (%s), retrying...\n", err)
+		libracommon.LogWarning("email send failed with error (%s), retrying...", err)
 
 		// sleep for a bit before retrying
 		time.Sleep(retrySleepTime)
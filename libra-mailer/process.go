@@ -2,31 +2,42 @@
 // main message processing
 //
 
-package main
+package libramailer
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 	"net/http"
 	"time"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Deps returns the external dependencies, the idempotency ledger wraps the deployed processor
+func Deps() libracommon.Deps {
+	return deps
+}
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -37,18 +48,18 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// easystore access
-	es, err := newEasystoreProxy(cfg)
+	es, err := deps.Easystore(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer es.Close()
 
-	obj, err := getEasystoreObjectByKey(es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
+	obj, err := libracommon.GetEasystoreObjectByKey(ctx, es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
@@ -58,7 +69,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	// ensure we have the depositor field else we have no-one to email
 	v, f := fields["depositor"]
 	if f == false || len(v) == 0 {
-		fmt.Printf("ERROR: missing depositor field for object ns/oid [%s/%s]\n", ev.Namespace, ev.Identifier)
+		libracommon.LogError("missing depositor field for object ns/oid [%s/%s]", ev.Namespace, ev.Identifier)
 		return uvaeasystore.ErrBadParameter
 	}
 
@@ -81,30 +92,32 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		emailSentFieldName = "submitted-sent"
 
 	default:
-		fmt.Printf("INFO: uninteresting event, ignoring\n")
+		libracommon.LogInfo("uninteresting event, ignoring")
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
 	// check to make sure we do not resend an email unless commanded to do so
 	if ev.EventName == uvalibrabus.EventObjectCreate || ev.EventName == uvalibrabus.EventWorkPublish {
 		if len(fields[emailSentFieldName]) != 0 {
-			fmt.Printf("INFO: email already sent, ignoring\n")
+			libracommon.LogInfo("email already sent, ignoring")
+			libracommon.IgnoreEvent(ctx)
 			return nil
 		}
 	}
 
 	// get a new http client and get an auth token
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
-	token, err := getAuthToken(httpClient, cfg.MintAuthUrl)
+	token, err := libracommon.GetAuthToken(ctx, httpClient, cfg.MintAuthUrl)
 	if err != nil {
 		return err
 	}
 
 	// lookup the depositor
-	depositor, err := getUser(fields["depositor"], cfg.UserInfoUrl, token, httpClient)
+	depositor, err := getUser(ctx, fields["depositor"], cfg.UserInfoUrl, token, httpClient)
 	if err != nil {
 		return err
 	}
@@ -112,7 +125,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	// render the email body and bail out in the event of an error
 	mailSubject, mailBody, err := renderEmailSubjectAndBody(cfg, mailType, depositor, obj)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		libracommon.LogError("%s", err.Error())
 		return err
 	}
 
@@ -128,7 +141,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		if len(fields["registrar"]) != 0 {
 
 			// lookup the registrar
-			registrar, err := getUser(fields["registrar"], cfg.UserInfoUrl, token, httpClient)
+			registrar, err := getUser(ctx, fields["registrar"], cfg.UserInfoUrl, token, httpClient)
 			if err != nil {
 				return err
 			}
@@ -138,7 +151,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 			mailSubject, mailBody, err = renderEmailSubjectAndBody(cfg, mailType, registrar, obj)
 			if err != nil {
-				fmt.Printf("ERROR: %s\n", err.Error())
+				libracommon.LogError("%s", err.Error())
 				return err
 			}
 			err = sendEmail(cfg, mailSubject, registrar.Email, []string{}, mailBody)
@@ -149,42 +162,42 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// update the field to note that we have sent the email(s)
-	fields[emailSentFieldName] = time.Now().UTC().Format(time.RFC3339)
+	fields[emailSentFieldName] = deps.Now().UTC().Format(time.RFC3339)
 	obj.SetFields(fields)
-	obj, err = putEasystoreFieldWithRetry(es, obj, uvaeasystore.Fields, emailSentFieldName, fields[emailSentFieldName])
+	obj, err = libracommon.PutEasystoreFieldWithRetry(ctx, es, obj, uvaeasystore.Fields, emailSentFieldName, fields[emailSentFieldName])
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		libracommon.LogError("%s", err.Error())
 		return err
 	}
 
 	// audit this change
 	who := "libra-mailer"
-	bus, _ := NewEventBus(cfg.BusName, who)
-	_ = pubAuditEvent(bus, obj, who, emailSentFieldName, "", fields[emailSentFieldName])
+	bus, _ := deps.EventBus(cfg.BusName, who)
+	_ = libracommon.PubAuditEvent(ctx, bus, obj, who, emailSentFieldName, "", fields[emailSentFieldName])
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
-func getUser(userId string, serviceUrl string, authToken string, client *http.Client) (*UserDetails, error) {
+func getUser(ctx context.Context, userId string, serviceUrl string, authToken string, client *http.Client) (*libracommon.UserDetails, error) {
 
 	// lookup the user
-	user, err := getUserDetails(serviceUrl, userId, authToken, client)
+	user, err := libracommon.GetUserDetails(ctx, serviceUrl, userId, authToken, client)
 	if err != nil {
 		return nil, err
 	}
 
 	// if we did not find the user...
 	if user == nil {
-		fmt.Printf("ERROR: cannot find user details for [%s]\n", userId)
-		return nil, ErrUserNotFound
+		libracommon.LogError("cannot find user details for [%s]", userId)
+		return nil, libracommon.ErrUserNotFound
 	}
 
 	// if the user does not have an email
 	if len(user.Email) == 0 {
-		fmt.Printf("ERROR: cannot find email for [%s]\n", userId)
-		return nil, ErrEmailNotFound
+		libracommon.LogError("cannot find email for [%s]", userId)
+		return nil, libracommon.ErrEmailNotFound
 	}
 	// all good
 	return user, nil
@@ -0,0 +1,181 @@
+//
+// Process() end to end against the fakes, the auth and user services are an httptest service.
+// Mail is logged rather than sent
+//
+
+package libramailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	librametadata "github.com/uvalib/libra-metadata"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// setupProcess replaces the dependencies with fakes and configures Process() to use the service
+func setupProcess(t *testing.T) (*fakes.Dependencies, *fakes.HttpService) {
+
+	fd := fakes.New()
+	saved := deps
+	deps = fd.Deps()
+	t.Cleanup(func() { deps = saved })
+	libracommon.SetMetricsSink(fakes.NewMetricsSink())
+
+	svc := fakes.NewHttpService()
+	t.Cleanup(svc.Close)
+	svc.Respond("GET", "/auth", 200, `{"token":"a-token"}`)
+
+	t.Setenv("MINT_AUTH_URL", svc.URL+"/auth")
+	t.Setenv("USER_INFO_URL", svc.URL+"/user/{:id}?auth={:auth}")
+	t.Setenv("ETD_BASE_URL", "https://libra.example.edu")
+	t.Setenv("EMAIL_SENDER", "libra@example.edu")
+	t.Setenv("EMAIL_SEND", "false")
+	t.Setenv("DEBUG_RECIPIENT", "")
+	t.Setenv("SMTP_HOST", "localhost")
+	t.Setenv("SMTP_PORT", "25")
+	t.Setenv("ES_PROXY_URL", "http://easystore.example.edu")
+	t.Setenv("MESSAGE_BUS", "test-bus")
+	return fd, svc
+}
+
+// addWork adds a work to the easystore with the extra fields
+func addWork(t *testing.T, fd *fakes.Dependencies, id string, extra map[string]string) {
+
+	work := librametadata.ETDWork{Title: "A Study of Things", Degree: "MA (Master of Arts)"}
+	pl, err := work.Payload()
+	if err != nil {
+		t.Fatalf("serializing work (%s)", err.Error())
+	}
+
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	fields["depositor"] = "ab1c"
+	for k, v := range extra {
+		fields[k] = v
+	}
+	obj.SetFields(fields)
+	obj.SetMetadata(uvaeasystore.NewEasyStoreMetadata(work.MimeType(), pl))
+	fd.Easystore.Add(obj)
+}
+
+func busEvent(t *testing.T, name string, id string) json.RawMessage {
+	ev := uvalibrabus.UvaBusEvent{EventName: name, Namespace: libracommon.LibraEtdNamespace, Identifier: id}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	return pl
+}
+
+// userLookups returns the paths of the user service requests
+func userLookups(svc *fakes.HttpService) []string {
+	paths := make([]string, 0)
+	for _, r := range svc.Requests() {
+		if r.Path != "/auth" {
+			paths = append(paths, r.Path)
+		}
+	}
+	return paths
+}
+
+func TestProcessInvitationRecordsSent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("GET", "/user/ab1c", 200, `{"status":200,"user":{"cid":"ab1c","display_name":"Ann Bell","email":"ab1c@example.edu"}}`)
+	addWork(t, fd, "oid:work1", map[string]string{"source": "sis"})
+
+	err := Process(context.Background(), "msg-1", "test", busEvent(t, uvalibrabus.EventObjectCreate, "oid:work1"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	sent := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work1").Fields()["invitation-sent"]
+	if sent != "2024-01-01T12:00:00Z" {
+		t.Errorf("expected invitation-sent to be the current time, got [%s]", sent)
+	}
+	events := fd.Bus.Events()
+	if len(events) != 1 || events[0].EventName != uvalibrabus.EventFieldUpdate {
+		t.Errorf("expected an audit event, got %+v", events)
+	}
+}
+
+func TestProcessInvitationAlreadySent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	addWork(t, fd, "oid:work2", map[string]string{"invitation-sent": "2023-12-01T00:00:00Z"})
+
+	err := Process(context.Background(), "msg-2", "test", busEvent(t, uvalibrabus.EventObjectCreate, "oid:work2"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if len(svc.Requests()) != 0 {
+		t.Errorf("expected no service requests, got %+v", svc.Requests())
+	}
+	if len(fd.Bus.Events()) != 0 {
+		t.Errorf("expected no audit events, got %+v", fd.Bus.Events())
+	}
+}
+
+func TestProcessPublishMailsRegistrar(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("GET", "/user/ab1c", 200, `{"status":200,"user":{"cid":"ab1c","display_name":"Ann Bell","email":"ab1c@example.edu"}}`)
+	svc.Respond("GET", "/user/reg9z", 200, `{"status":200,"user":{"cid":"reg9z","display_name":"Reg Istrar","email":"reg9z@example.edu"}}`)
+	addWork(t, fd, "oid:work3", map[string]string{"draft": "false", "registrar": "reg9z"})
+
+	err := Process(context.Background(), "msg-3", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work3"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	lookups := userLookups(svc)
+	if len(lookups) != 2 || lookups[0] != "/user/ab1c" || lookups[1] != "/user/reg9z" {
+		t.Errorf("expected the author and registrar to be looked up, got %v", lookups)
+	}
+	if len(fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work3").Fields()["submitted-sent"]) == 0 {
+		t.Errorf("expected submitted-sent to be set")
+	}
+}
+
+func TestProcessDepositorWithoutEmail(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("GET", "/user/ab1c", 200, `{"status":200,"user":{"cid":"ab1c","display_name":"Ann Bell"}}`)
+	addWork(t, fd, "oid:work4", nil)
+
+	err := Process(context.Background(), "msg-4", "test", busEvent(t, uvalibrabus.EventCommandMailInvite, "oid:work4"))
+	if errors.Is(err, libracommon.ErrEmailNotFound) == false {
+		t.Fatalf("expected ErrEmailNotFound, got %v", err)
+	}
+	if len(fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work4").Fields()["invitation-sent"]) != 0 {
+		t.Errorf("expected invitation-sent to be left unset")
+	}
+}
+
+func TestProcessIdempotent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("GET", "/user/ab1c", 200, `{"status":200,"user":{"cid":"ab1c","display_name":"Ann Bell","email":"ab1c@example.edu"}}`)
+	addWork(t, fd, "oid:work5", nil)
+
+	// a mail command is sent every time it is processed, the ledger stops the redelivery
+	idempotent := libracommon.Idempotent(deps, Process)
+	for range 2 {
+		err := idempotent(context.Background(), "msg-5", "test", busEvent(t, uvalibrabus.EventCommandMailInvite, "oid:work5"))
+		if err != nil {
+			t.Fatalf("expected success, got %s", err.Error())
+		}
+	}
+
+	if lookups := userLookups(svc); len(lookups) != 1 {
+		t.Errorf("expected the repeated event to be skipped, got %d user lookups", len(lookups))
+	}
+	if len(fd.Idempotency.Records()) != 1 {
+		t.Errorf("expected one ledger record, got %+v", fd.Idempotency.Records())
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libraorcid "github.com/uvalib/libra-orcid"
+)
+
+func main() {
+	libracommon.RunCmdline(libraorcid.Process)
+}
+
+//
+// end of file
+//
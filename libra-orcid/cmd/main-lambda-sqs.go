@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libraorcid "github.com/uvalib/libra-orcid"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libraorcid.Process)
+}
+
+//
+// end of file
+//
@@ -1,19 +1,19 @@
-package main
+package libraorcid
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 
 	// service endpoint configuration
-	MintAuthUrl         string // mint auth token endpoint
-	OrcidGetDetailsUrl  string // get orcid get details endpoint
-	OrcidSetActivityUrl string // get orcid set activity endpoint
+	MintAuthUrl         string `env:"MINT_AUTH_URL" required:"true"`          // mint auth token endpoint
+	OrcidGetDetailsUrl  string `env:"ORCID_GET_DETAILS_URL" required:"true"`  // get orcid get details endpoint
+	OrcidSetActivityUrl string `env:"ORCID_SET_ACTIVITY_URL" required:"true"` // get orcid set activity endpoint
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
 	BusName string // the message bus name
@@ -25,33 +25,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.MintAuthUrl, err = ensureSetAndNonEmpty("MINT_AUTH_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
-	cfg.OrcidGetDetailsUrl, err = ensureSetAndNonEmpty("ORCID_GET_DETAILS_URL")
-	if err != nil {
-		return nil, err
-	}
-	cfg.OrcidSetActivityUrl, err = ensureSetAndNonEmpty("ORCID_SET_ACTIVITY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	//cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] MintAuthUrl         = [%s]\n", cfg.MintAuthUrl)
-	fmt.Printf("[conf] OrcidGetDetailsUrl  = [%s]\n", cfg.OrcidGetDetailsUrl)
-	fmt.Printf("[conf] OrcidSetActivityUrl = [%s]\n", cfg.OrcidSetActivityUrl)
 
-	fmt.Printf("[conf] EsProxyUrl          = [%s]\n", cfg.EsProxyUrl)
-	//fmt.Printf("[conf] BusName             = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,9 +2,10 @@
 //
 //
 
-package main
+package libraorcid
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	librametadata "github.com/uvalib/libra-metadata"
 )
 
@@ -41,9 +43,9 @@ type Person struct {
 	LastName  string `json:"last_name,omitempty"`
 }
 
-var ErrIncompleteData = fmt.Errorf("incomplete data")
+var ErrIncompleteData = libracommon.Permanent(fmt.Errorf("incomplete data"))
 
-func updateAuthorOrcidActivity(config *Config, eso uvaeasystore.EasyStoreObject, authorId string, updateCode string, auth string, client *http.Client) (string, error) {
+func updateAuthorOrcidActivity(ctx context.Context, config *Config, eso uvaeasystore.EasyStoreObject, authorId string, updateCode string, auth string, client *http.Client) (string, error) {
 
 	// create the update schema
 	schema, err := createUpdateSchema(eso)
@@ -67,13 +69,13 @@ func updateAuthorOrcidActivity(config *Config, eso uvaeasystore.EasyStoreObject,
 
 	pl, err := json.Marshal(req)
 	if err != nil {
-		fmt.Printf("ERROR: json marshal of OrcidActivityUpdate (%s)\n", err.Error())
+		libracommon.LogError("json marshal of OrcidActivityUpdate (%s)", err.Error())
 		return "", err
 	}
 
-	buf, err := httpPut(client, url, pl, "application/json")
+	buf, err := libracommon.HttpPut(ctx, client, url, pl, "application/json")
 	if err != nil {
-		fmt.Printf("ERROR: failed payload [%s]\n", string(pl))
+		libracommon.LogError("failed payload [%s]", string(pl))
 		if buf != nil {
 			// let's try and unmarshal the response anyway
 			resp := OrcidActivityUpdateResponse{}
@@ -81,12 +83,12 @@ func updateAuthorOrcidActivity(config *Config, eso uvaeasystore.EasyStoreObject,
 			if err == nil {
 				// this is a special case
 				if resp.Status == http.StatusConflict {
-					fmt.Printf("INFO: reports update already applied [%s]\n", resp.Message)
+					libracommon.LogInfo("reports update already applied [%s]", resp.Message)
 					// assume all is well and we can ignore this
 					return resp.UpdateCode, nil
 				}
 			}
-			fmt.Printf("ERROR: failed response [%s]\n", string(buf))
+			libracommon.LogError("failed response [%s]", string(buf))
 		}
 		return "", err
 	}
@@ -96,7 +98,7 @@ func updateAuthorOrcidActivity(config *Config, eso uvaeasystore.EasyStoreObject,
 	resp := OrcidActivityUpdateResponse{}
 	err = json.Unmarshal(buf, &resp)
 	if err != nil {
-		fmt.Printf("ERROR: json unmarshal of OrcidActivityUpdateResponse (%s)\n", err.Error())
+		libracommon.LogError("json unmarshal of OrcidActivityUpdateResponse (%s)", err.Error())
 		return "", err
 	}
 
@@ -109,7 +111,7 @@ func createUpdateSchema(eso uvaeasystore.EasyStoreObject) (*WorkSchema, error) {
 	// check we have metadata
 	md := eso.Metadata()
 	if md == nil {
-		return nil, ErrNoMetadata
+		return nil, libracommon.ErrNoMetadata
 	}
 	pl, err := md.Payload()
 	if err != nil {
@@ -123,7 +125,7 @@ func createUpdateSchema(eso uvaeasystore.EasyStoreObject) (*WorkSchema, error) {
 
 	meta, err := librametadata.ETDWorkFromBytes(pl)
 	if err != nil {
-		return nil, err
+		return nil, libracommon.Permanent(err)
 	}
 
 	schema.Authors = getEtdPersons(meta)
@@ -144,7 +146,7 @@ func getWorkAuthor(eso uvaeasystore.EasyStoreObject) (string, error) {
 	// check we have metadata
 	md := eso.Metadata()
 	if md == nil {
-		return "", ErrNoMetadata
+		return "", libracommon.ErrNoMetadata
 	}
 	pl, err := md.Payload()
 	if err != nil {
@@ -153,7 +155,7 @@ func getWorkAuthor(eso uvaeasystore.EasyStoreObject) (string, error) {
 
 	meta, err := librametadata.ETDWorkFromBytes(pl)
 	if err != nil {
-		return "", err
+		return "", libracommon.Permanent(err)
 	}
 	if len(meta.Author.ComputeID) != 0 {
 		return meta.Author.ComputeID, nil
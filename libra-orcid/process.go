@@ -2,30 +2,36 @@
 // main message processing
 //
 
-package main
+package libraorcid
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -36,54 +42,56 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// easystore access
-	es, err := newEasystoreProxy(cfg)
+	es, err := deps.Easystore(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer es.Close()
 
-	eso, err := getEasystoreObjectByKey(es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
+	eso, err := libracommon.GetEasystoreObjectByKey(ctx, es, ev.Namespace, ev.Identifier, uvaeasystore.Fields+uvaeasystore.Metadata)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	// get author details
 	authorId, err := getWorkAuthor(eso)
 	if err != nil {
-		fmt.Printf("ERROR: cannot locate author ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("cannot locate author ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	if len(authorId) == 0 {
-		fmt.Printf("WARNING: cannot locate author ns/oid [%s/%s]\n", ev.Namespace, ev.Identifier)
+		libracommon.LogWarning("cannot locate author ns/oid [%s/%s]", ev.Namespace, ev.Identifier)
+		libracommon.IgnoreEvent(ctx)
 		// noting to do
 		return nil
 	}
 
 	// get a new http client and get an auth token
-	httpClient := newHttpClient(1, 30)
+	httpClient := deps.HttpClient(1, 30)
 	// important, cleanup properly
 	defer httpClient.CloseIdleConnections()
 
-	token, err := getAuthToken(httpClient, cfg.MintAuthUrl)
+	token, err := libracommon.GetAuthToken(ctx, httpClient, cfg.MintAuthUrl)
 	if err != nil {
 		return err
 	}
 
 	// attempt to get ORCID for the author
-	orcid, err := getOrcidDetails(cfg.OrcidGetDetailsUrl, authorId, token, httpClient)
+	orcid, err := libracommon.GetOrcidDetails(ctx, cfg.OrcidGetDetailsUrl, authorId, token, httpClient)
 	if err != nil {
-		fmt.Printf("ERROR: getting %s ORCID details ns/oid [%s/%s] (%s)\n", authorId, ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting %s ORCID details ns/oid [%s/%s] (%s)", authorId, ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
 	// if author does not have ORCID details, it's all over
 	if len(orcid) == 0 {
-		fmt.Printf("INFO: no ORCID details for %s ns/oid [%s/%s]\n", authorId, ev.Namespace, ev.Identifier)
+		libracommon.LogInfo("no ORCID details for %s ns/oid [%s/%s]", authorId, ev.Namespace, ev.Identifier)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -91,13 +99,14 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	fields := eso.Fields()
 	updateCode := fields["orcid-update-code"]
 
-	newCode, err := updateAuthorOrcidActivity(cfg, eso, authorId, updateCode, token, httpClient)
+	newCode, err := updateAuthorOrcidActivity(ctx, cfg, eso, authorId, updateCode, token, httpClient)
 	if err != nil {
 		if errors.Is(err, ErrIncompleteData) == true {
-			fmt.Printf("WARNING: incomplete data for ORCID activity ns/oid [%s/%s]\n", ev.Namespace, ev.Identifier)
+			libracommon.LogWarning("incomplete data for ORCID activity ns/oid [%s/%s]", ev.Namespace, ev.Identifier)
+			libracommon.IgnoreEvent(ctx)
 			return nil
 		} else {
-			fmt.Printf("ERROR: updating %s ORCID activity ns/oid [%s/%s] (%s)\n", authorId, ev.Namespace, ev.Identifier, err.Error())
+			libracommon.LogError("updating %s ORCID activity ns/oid [%s/%s] (%s)", authorId, ev.Namespace, ev.Identifier, err.Error())
 			return err
 		}
 	}
@@ -107,15 +116,15 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		fieldName := "orcid-update-code"
 		fields[fieldName] = newCode
 		eso.SetFields(fields)
-		eso, err = putEasystoreFieldWithRetry(es, eso, uvaeasystore.Fields, fieldName, fields[fieldName])
+		eso, err = libracommon.PutEasystoreFieldWithRetry(ctx, es, eso, uvaeasystore.Fields, fieldName, fields[fieldName])
 		if err != nil {
-			fmt.Printf("ERROR: %s\n", err.Error())
+			libracommon.LogError("%s", err.Error())
 			return err
 		}
 	}
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
@@ -0,0 +1,19 @@
+//
+// main entry point when deployed as an API gateway triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartApiGatewayLambda(process)
+}
+
+//
+// end of file
+//
@@ -5,11 +5,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	_ "github.com/lib/pq"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"net/http"
 )
 
@@ -25,14 +27,14 @@ type BlobMetrics struct {
 	DownloadCount int    `json:"downloads"`
 }
 
-func process(messageId string, messageSrc string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func process(ctx context.Context, messageId string, messageSrc string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
 	var namespace string
 	var oid string
 
 	// log inbound query parameters
 	for key, value := range request.QueryStringParameters {
-		fmt.Printf("DEBUG: query param [%s] = [%s]\n", key, value)
+		libracommon.LogDebug("query param [%s] = [%s]", key, value)
 		switch key {
 		case "namespace":
 			namespace = value
@@ -43,7 +45,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	// log inbound headers
 	for key, value := range request.Headers {
-		fmt.Printf("DEBUG: header [%s] = [%s]\n", key, value)
+		libracommon.LogDebug("header [%s] = [%s]", key, value)
 	}
 
 	// ensure we have the parameters we need
@@ -63,7 +65,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	db, err := sql.Open("postgres", connectionStr)
 	if err != nil {
-		fmt.Printf("ERROR: unable to open database (%s)\n", err.Error())
+		libracommon.LogError("unable to open database (%s)", err.Error())
 		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 	}
 
@@ -75,7 +77,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 	// select sum(rollup_count) from page_metrics where namespace = ns and oid = oid and metric_type = 'view'
 	err = db.QueryRow("SELECT COALESCE(SUM(rollup_count), 0) FROM page_metrics WHERE namespace = $1 and oid = $2 and metric_type = 'view'", namespace, oid).Scan(&viewCount)
 	if err != nil {
-		fmt.Printf("ERROR: view metrics query failed (%s)\n", err.Error())
+		libracommon.LogError("view metrics query failed (%s)", err.Error())
 		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 	}
 
@@ -84,7 +86,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 	// select target_id, sum(rollup_count) from page_metrics where namespace = ns and oid = oid and metric_type = 'download' group by 1
 	rows, err := db.Query("SELECT target_id, COALESCE(SUM(rollup_count), 0) FROM page_metrics WHERE namespace = $1 and oid = $2 and metric_type = 'download' GROUP BY 1", namespace, oid)
 	if err != nil {
-		fmt.Printf("ERROR: download metrics query failed (%s)\n", err.Error())
+		libracommon.LogError("download metrics query failed (%s)", err.Error())
 		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 	}
 	defer rows.Close()
@@ -92,7 +94,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 	for rows.Next() {
 		var fileMetrics BlobMetrics
 		if err := rows.Scan(&fileMetrics.TargetId, &fileMetrics.DownloadCount); err != nil {
-			fmt.Printf("ERROR: rows.Scan() failed (%s)\n", err.Error())
+			libracommon.LogError("rows.Scan() failed (%s)", err.Error())
 			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 		}
 		blobMetrics = append(blobMetrics, fileMetrics)
@@ -100,7 +102,7 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	// check to see if we have results
 	if viewCount == 0 && len(blobMetrics) == 0 {
-		fmt.Printf("INFO: no metrics for [%s/%s]\n", namespace, oid)
+		libracommon.LogInfo("no metrics for [%s/%s]", namespace, oid)
 		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
 	}
 
@@ -113,10 +115,10 @@ func process(messageId string, messageSrc string, request events.APIGatewayProxy
 
 	buf, err := json.Marshal(response)
 	if err != nil {
-		fmt.Printf("ERROR: json.Marshal() failed (%s)\n", err.Error())
+		libracommon.LogError("json.Marshal() failed (%s)", err.Error())
 		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusInternalServerError}, err
 	}
-	fmt.Printf("DEBUG: response [%s]\n", string(buf))
+	libracommon.LogDebug("response [%s]", string(buf))
 	return events.APIGatewayProxyResponse{Body: string(buf), StatusCode: http.StatusOK}, nil
 }
 
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librapagemetrics "github.com/uvalib/libra-page-metrics"
+)
+
+func main() {
+	libracommon.RunCmdline(librapagemetrics.Process)
+}
+
+//
+// end of file
+//
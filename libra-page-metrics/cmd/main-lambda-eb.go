@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an EventBridge triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librapagemetrics "github.com/uvalib/libra-page-metrics"
+)
+
+func main() {
+	libracommon.StartEventBridgeLambda(librapagemetrics.Process)
+}
+
+//
+// end of file
+//
@@ -1,18 +1,18 @@
-package main
+package librapagemetrics
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // DBConf holds the database connection info
 // Config defines all of the service configuration parameters
 type Config struct {
 	// database configuration
-	DbHost     string // database host
-	DbPort     int    // database port
-	DbName     string // database name
-	DbUser     string // database user
-	DbPassword string // database password
+	DbHost     string `env:"DB_HOST" required:"true"`                   // database host
+	DbPort     int    `env:"DB_PORT" required:"true"`                   // database port
+	DbName     string `env:"DB_NAME" required:"true"`                   // database name
+	DbUser     string `env:"DB_USER" required:"true"`                   // database user
+	DbPassword string `env:"DB_PASSWORD" required:"true" secret:"true"` // database password
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -21,33 +21,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.DbHost, err = ensureSetAndNonEmpty("DB_HOST")
-	if err != nil {
-		return nil, err
-	}
-	cfg.DbPort, err = envToInt("DB_PORT")
-	if err != nil {
-		return nil, err
-	}
-	cfg.DbName, err = ensureSetAndNonEmpty("DB_NAME")
-	if err != nil {
-		return nil, err
-	}
-	cfg.DbUser, err = ensureSetAndNonEmpty("DB_USER")
-	if err != nil {
-		return nil, err
-	}
-	cfg.DbPassword, err = ensureSetAndNonEmpty("DB_PASSWORD")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Printf("[conf] DbHost          = [%s]\n", cfg.DbHost)
-	fmt.Printf("[conf] DbPort          = [%d]\n", cfg.DbPort)
-	fmt.Printf("[conf] DbName          = [%s]\n", cfg.DbName)
-	fmt.Printf("[conf] DbUser          = [%s]\n", cfg.DbUser)
-	fmt.Printf("[conf] DbPassword      = [REDACTED]\n")
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
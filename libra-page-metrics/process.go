@@ -2,9 +2,10 @@
 // main message processing
 //
 
-package main
+package librapagemetrics
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"github.com/aquilax/truncate"
 
 	_ "github.com/lib/pq"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
@@ -23,24 +25,25 @@ var maxReferrerSize = 255
 var maxUserAgentSize = 255
 var maxAcceptLanguageSize = 32
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	content, err := uvalibrabus.MakeContentEvent(ev.Detail)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra content event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra content event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: Content %v\n", content)
+	libracommon.LogInfo("Content %v", content)
 
 	// load configuration
 	cfg, err := loadConfiguration()
@@ -53,7 +56,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 	db, err := sql.Open("postgres", connectionStr)
 	if err != nil {
-		fmt.Printf("ERROR: unable to open database %s\n", err.Error())
+		libracommon.LogError("unable to open database %s", err.Error())
 		return err
 	}
 	// cleanup
@@ -61,7 +64,7 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 	parsedEventTime, err := time.Parse(time.RFC3339, ev.EventTime)
 	if err != nil {
-		fmt.Printf("ERROR: unable to parse event time %s\n", err.Error())
+		libracommon.LogError("unable to parse event time %s", err.Error())
 		return err
 	}
 
@@ -74,7 +77,8 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		metricType = "download"
 
 	default:
-		fmt.Printf("INFO: uninteresting event, ignoring\n")
+		libracommon.LogInfo("uninteresting event, ignoring")
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -99,20 +103,20 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	)
 
 	if err != nil {
-		fmt.Printf("ERROR: db insert %s", err)
+		libracommon.LogError("db insert %s", err)
 		return err
 	}
 
 	n, err := result.RowsAffected()
 	if err != nil {
-		fmt.Printf("ERROR: rows affected %s", err)
+		libracommon.LogError("rows affected %s", err)
 		return err
 	}
 
-	fmt.Printf("INFO: Inserted %d row\n", n)
+	libracommon.LogInfo("Inserted %d row", n)
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
-	BusName    string // message bus name
-	SourceName string // message source name
+	BusName    string `env:"MESSAGE_BUS" required:"true"`                       // message bus name
+	SourceName string `env:"MESSAGE_SOURCE" required:"true"`                    // message source name
+	EventName  string `env:"SCHEDULE_EVENT_NAME" default:"schedule.etd.ingest"` // the event published on each run
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -16,19 +17,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.BusName, err = ensureSetAndNonEmpty("MESSAGE_BUS")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.SourceName, err = ensureSetAndNonEmpty("MESSAGE_SOURCE")
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Printf("[conf] BusName    = [%s]\n", cfg.BusName)
-	fmt.Printf("[conf] SourceName = [%s]\n", cfg.SourceName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -8,8 +8,9 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"os"
 )
 
@@ -23,13 +24,13 @@ func main() {
 	flag.Parse()
 
 	pl := []byte("{}")
-	err := process(messageId, source, pl)
+	err := process(context.Background(), messageId, source, pl)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		libracommon.LogError("%s", err.Error())
 		os.Exit(1)
 	}
 
-	fmt.Printf("INFO: terminating normally\n")
+	libracommon.LogInfo("terminating normally")
 }
 
 //
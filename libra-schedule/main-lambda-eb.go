@@ -0,0 +1,19 @@
+//
+// main entry point when deployed as an EventBridge triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+func main() {
+	libracommon.StartEventBridgeLambda(process)
+}
+
+//
+// end of file
+//
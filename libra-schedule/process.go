@@ -5,14 +5,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, string(rawMsg))
+func process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
+
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, string(rawMsg))
 
 	// load configuration
 	cfg, err := loadConfiguration()
@@ -20,29 +24,24 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		return err
 	}
 
-	busCfg := uvalibrabus.UvaBusConfig{
-		Source:  cfg.SourceName,
-		BusName: cfg.BusName,
-	}
-
 	// create message bus client
-	bus, err := uvalibrabus.NewUvaBus(busCfg)
+	bus, err := deps.EventBus(cfg.BusName, cfg.SourceName)
 	if err != nil {
-		fmt.Printf("ERROR: creating event bus client (%s)\n", err.Error())
+		libracommon.LogError("creating event bus client (%s)", err.Error())
 		return err
 	}
-	fmt.Printf("Using: %s@%s\n", cfg.SourceName, cfg.BusName)
+	libracommon.LogInfo("using: %s@%s", cfg.SourceName, cfg.BusName)
 
 	// create event
 	ev := uvalibrabus.UvaBusEvent{}
-	ev.EventName = uvalibrabus.EventScheduleEtdIngest
+	ev.EventName = cfg.EventName
 	ev.Identifier = "none"
 
 	// publish ETD namespace event
-	ev.Namespace = libraEtdNamespace
-	err = bus.PublishEvent(&ev)
+	ev.Namespace = libracommon.LibraEtdNamespace
+	err = libracommon.PublishEvent(ctx, bus, &ev)
 	if err != nil {
-		fmt.Printf("ERROR: publishing event (%s)\n", err.Error())
+		libracommon.LogError("publishing event (%s)", err.Error())
 		return err
 	}
 
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librasisnotify "github.com/uvalib/libra-sis-notify"
+)
+
+func main() {
+	libracommon.RunCmdline(librasisnotify.Process)
+}
+
+//
+// end of file
+//
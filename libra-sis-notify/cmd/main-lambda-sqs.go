@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	librasisnotify "github.com/uvalib/libra-sis-notify"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libracommon.Idempotent(librasisnotify.Deps(), librasisnotify.Process))
+}
+
+//
+// end of file
+//
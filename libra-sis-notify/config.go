@@ -1,21 +1,21 @@
-package main
+package librasisnotify
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 
 	// service endpoint configuration
-	MintAuthUrl  string // mint auth token endpoint
-	SisNotifyUrl string // the sis notify service
+	MintAuthUrl  string `env:"MINT_AUTH_URL" required:"true"`  // mint auth token endpoint
+	SisNotifyUrl string `env:"SIS_NOTIFY_URL" required:"true"` // the sis notify service
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
-	BusName string // the message bus name
+	BusName string `env:"MESSAGE_BUS"` // the message bus name
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -24,27 +24,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.MintAuthUrl, err = ensureSetAndNonEmpty("MINT_AUTH_URL")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
-	cfg.SisNotifyUrl, err = ensureSetAndNonEmpty("SIS_NOTIFY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
 
-	fmt.Printf("[conf] MintAuthUrl  = [%s]\n", cfg.MintAuthUrl)
-	fmt.Printf("[conf] SisNotifyUrl = [%s]\n", cfg.SisNotifyUrl)
-	fmt.Printf("[conf] EsProxyUrl   = [%s]\n", cfg.EsProxyUrl)
-	fmt.Printf("[conf] BusName      = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,16 +2,17 @@
 //
 //
 
-package main
+package librasisnotify
 
 import (
-	"fmt"
+	"context"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"net/http"
 	"strings"
 )
 
-func notifySis(config *Config, fields uvaeasystore.EasyStoreObjectFields, auth string, client *http.Client) error {
+func notifySis(ctx context.Context, config *Config, fields uvaeasystore.EasyStoreObjectFields, auth string, client *http.Client) error {
 
 	sisId := strings.Replace(fields["source-id"], "sis:", "", 1)
 	doi := fields["doi"]
@@ -21,10 +22,10 @@ func notifySis(config *Config, fields uvaeasystore.EasyStoreObjectFields, auth s
 	url = strings.Replace(url, "{:auth}", auth, 1)
 	url = strings.Replace(url, "{:doi}", doi, 1)
 
-	buf, err := httpPut(client, url, nil, "")
+	buf, err := libracommon.HttpPut(ctx, client, url, nil, "")
 	if err != nil {
 		if buf != nil {
-			fmt.Printf("ERROR: failed response [%s]\n", string(buf))
+			libracommon.LogError("failed response [%s]", string(buf))
 		}
 		return err
 	}
@@ -2,35 +2,46 @@
 // main message processing
 //
 
-package main
+package librasisnotify
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 )
 
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Deps returns the external dependencies, the idempotency ledger wraps the deployed processor
+func Deps() libracommon.Deps {
+	return deps
+}
+
 // field name indicating sis notified
 var sisNotifiedFieldName = "sis-sent"
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -41,18 +52,18 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// easystore access
-	es, err := newEasystoreProxy(cfg)
+	es, err := deps.Easystore(cfg.EsProxyUrl)
 	if err != nil {
-		fmt.Printf("ERROR: creating easystore proxy (%s)\n", err.Error())
+		libracommon.LogError("creating easystore proxy (%s)", err.Error())
 		return err
 	}
 
 	// important, cleanup properly
 	defer es.Close()
 
-	obj, err := getEasystoreObjectByKey(es, ev.Namespace, ev.Identifier, uvaeasystore.Fields)
+	obj, err := libracommon.GetEasystoreObjectByKey(ctx, es, ev.Namespace, ev.Identifier, uvaeasystore.Fields)
 	if err != nil {
-		fmt.Printf("ERROR: getting object ns/oid [%s/%s] (%s)\n", ev.Namespace, ev.Identifier, err.Error())
+		libracommon.LogError("getting object ns/oid [%s/%s] (%s)", ev.Namespace, ev.Identifier, err.Error())
 		return err
 	}
 
@@ -60,35 +71,36 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 
 	// we have already notified SIS, bail out unless this is a command event
 	if len(fields[sisNotifiedFieldName]) != 0 && ev.EventName != uvalibrabus.EventCommandSisNotify {
-		fmt.Printf("INFO: SIS already notified, ignoring\n")
+		libracommon.LogInfo("SIS already notified, ignoring")
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
 	if strings.HasPrefix(fields["source-id"], "sis:") == true {
 
 		// get a new http client and get an auth token
-		httpClient := newHttpClient(1, 30)
+		httpClient := deps.HttpClient(1, 30)
 		// important, cleanup properly
 		defer httpClient.CloseIdleConnections()
 
-		token, err := getAuthToken(httpClient, cfg.MintAuthUrl)
+		token, err := libracommon.GetAuthToken(ctx, httpClient, cfg.MintAuthUrl)
 		if err != nil {
 			return err
 		}
 
 		// notify SIS of the activity
-		err = notifySis(cfg, fields, token, httpClient)
+		err = notifySis(ctx, cfg, fields, token, httpClient)
 		if err != nil {
-			fmt.Printf("ERROR: notifying SIS (%s)\n", err.Error())
+			libracommon.LogError("notifying SIS (%s)", err.Error())
 			return err
 		}
 
 		// update the field to note that we have notified SIS
-		fields[sisNotifiedFieldName] = time.Now().UTC().Format(time.RFC3339)
+		fields[sisNotifiedFieldName] = deps.Now().UTC().Format(time.RFC3339)
 		obj.SetFields(fields)
-		obj, err = putEasystoreFieldWithRetry(es, obj, uvaeasystore.Fields, sisNotifiedFieldName, fields[sisNotifiedFieldName])
+		obj, err = libracommon.PutEasystoreFieldWithRetry(ctx, es, obj, uvaeasystore.Fields, sisNotifiedFieldName, fields[sisNotifiedFieldName])
 		if err != nil {
-			fmt.Printf("ERROR: %s\n", err.Error())
+			libracommon.LogError("%s", err.Error())
 			return err
 		}
 	} else {
@@ -96,17 +108,18 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 		if len(fields["source-id"]) != 0 {
 			src = fields["source-id"]
 		}
-		fmt.Printf("INFO: not a SIS work (source %s), ignoring\n", src)
+		libracommon.LogInfo("not a SIS work (source %s), ignoring", src)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
 	// audit this change
 	who := "libra-sis-notify"
-	bus, _ := NewEventBus(cfg.BusName, who)
-	_ = pubAuditEvent(bus, obj, who, sisNotifiedFieldName, "", fields[sisNotifiedFieldName])
+	bus, _ := deps.EventBus(cfg.BusName, who)
+	_ = libracommon.PubAuditEvent(ctx, bus, obj, who, sisNotifiedFieldName, "", fields[sisNotifiedFieldName])
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
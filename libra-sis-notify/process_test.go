@@ -0,0 +1,188 @@
+//
+// Process() end to end against the fakes, the auth and SIS services are an httptest service
+//
+
+package librasisnotify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/libra-lambda/lambda-common/fakes"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+// setupProcess replaces the dependencies with fakes and configures Process() to use the service
+func setupProcess(t *testing.T) (*fakes.Dependencies, *fakes.HttpService) {
+
+	fd := fakes.New()
+	saved := deps
+	deps = fd.Deps()
+	t.Cleanup(func() { deps = saved })
+	libracommon.SetMetricsSink(fakes.NewMetricsSink())
+
+	svc := fakes.NewHttpService()
+	t.Cleanup(svc.Close)
+	svc.Respond("GET", "/auth", 200, `{"token":"a-token"}`)
+
+	t.Setenv("MINT_AUTH_URL", svc.URL+"/auth")
+	t.Setenv("SIS_NOTIFY_URL", svc.URL+"/sis/{:id}?auth={:auth}&doi={:doi}")
+	t.Setenv("ES_PROXY_URL", "http://easystore.example.edu")
+	t.Setenv("MESSAGE_BUS", "test-bus")
+	return fd, svc
+}
+
+// addWork adds a work to the easystore with the fields
+func addWork(fd *fakes.Dependencies, id string, extra map[string]string) {
+	obj := uvaeasystore.NewEasyStoreObject(libracommon.LibraEtdNamespace, id)
+	fields := uvaeasystore.DefaultEasyStoreFields()
+	for k, v := range extra {
+		fields[k] = v
+	}
+	obj.SetFields(fields)
+	fd.Easystore.Add(obj)
+}
+
+func busEvent(t *testing.T, name string, id string) json.RawMessage {
+	ev := uvalibrabus.UvaBusEvent{EventName: name, Namespace: libracommon.LibraEtdNamespace, Identifier: id}
+	pl, err := ev.Serialize()
+	if err != nil {
+		t.Fatalf("serializing event (%s)", err.Error())
+	}
+	return pl
+}
+
+// sisRequests returns the requests made to the SIS service
+func sisRequests(svc *fakes.HttpService) []fakes.HttpRequest {
+	found := make([]fakes.HttpRequest, 0)
+	for _, r := range svc.Requests() {
+		if r.Path != "/auth" {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func TestProcessNotifiesSis(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("PUT", "/sis/12345", 200, `{}`)
+	addWork(fd, "oid:work1", map[string]string{"source-id": "sis:12345", "doi": "https://doi.org/10.5072/abc"})
+
+	err := Process(context.Background(), "msg-1", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work1"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+
+	sent := sisRequests(svc)
+	if len(sent) != 1 || sent[0].Method != "PUT" {
+		t.Fatalf("expected one SIS PUT, got %+v", sent)
+	}
+	if sent[0].Query != "auth=a-token&doi=https://doi.org/10.5072/abc" {
+		t.Errorf("unexpected SIS query [%s]", sent[0].Query)
+	}
+	notified := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work1").Fields()[sisNotifiedFieldName]
+	if notified != "2024-01-01T12:00:00Z" {
+		t.Errorf("expected %s to be the current time, got [%s]", sisNotifiedFieldName, notified)
+	}
+	events := fd.Bus.Events()
+	if len(events) != 1 || events[0].EventName != uvalibrabus.EventFieldUpdate || events[0].Identifier != "oid:work1" {
+		t.Errorf("expected an audit event, got %+v", events)
+	}
+}
+
+func TestProcessAlreadyNotified(t *testing.T) {
+	fd, svc := setupProcess(t)
+	addWork(fd, "oid:work2", map[string]string{"source-id": "sis:12345", sisNotifiedFieldName: "2023-12-01T00:00:00Z"})
+
+	err := Process(context.Background(), "msg-2", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work2"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if len(svc.Requests()) != 0 {
+		t.Errorf("expected no service requests, got %+v", svc.Requests())
+	}
+	if len(fd.Bus.Events()) != 0 {
+		t.Errorf("expected no audit events, got %+v", fd.Bus.Events())
+	}
+}
+
+func TestProcessCommandNotifiesAgain(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("PUT", "/sis/12345", 200, `{}`)
+	addWork(fd, "oid:work3", map[string]string{"source-id": "sis:12345", sisNotifiedFieldName: "2023-12-01T00:00:00Z"})
+
+	err := Process(context.Background(), "msg-3", "test", busEvent(t, uvalibrabus.EventCommandSisNotify, "oid:work3"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if len(sisRequests(svc)) != 1 {
+		t.Errorf("expected the command to notify SIS, got %+v", svc.Requests())
+	}
+	notified := fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work3").Fields()[sisNotifiedFieldName]
+	if notified != "2024-01-01T12:00:00Z" {
+		t.Errorf("expected %s to be updated, got [%s]", sisNotifiedFieldName, notified)
+	}
+}
+
+func TestProcessIgnoresOtherSources(t *testing.T) {
+	fd, svc := setupProcess(t)
+	addWork(fd, "oid:work4", map[string]string{"source-id": "optional:1"})
+
+	err := Process(context.Background(), "msg-4", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work4"))
+	if err != nil {
+		t.Fatalf("expected success, got %s", err.Error())
+	}
+	if len(svc.Requests()) != 0 {
+		t.Errorf("expected no service requests, got %+v", svc.Requests())
+	}
+	if len(fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work4").Fields()[sisNotifiedFieldName]) != 0 {
+		t.Errorf("expected %s to be left unset", sisNotifiedFieldName)
+	}
+}
+
+func TestProcessSisRejectionIsPermanent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("PUT", "/sis/12345", 400, `{"status":400,"message":"bad request"}`)
+	addWork(fd, "oid:work5", map[string]string{"source-id": "sis:12345"})
+
+	err := Process(context.Background(), "msg-5", "test", busEvent(t, uvalibrabus.EventWorkPublish, "oid:work5"))
+	if err == nil || libracommon.IsPermanent(err) == false {
+		t.Fatalf("expected a permanent failure, got %v", err)
+	}
+	if len(fd.Easystore.Get(libracommon.LibraEtdNamespace, "oid:work5").Fields()[sisNotifiedFieldName]) != 0 {
+		t.Errorf("expected %s to be left unset", sisNotifiedFieldName)
+	}
+	if len(fd.Bus.Events()) != 0 {
+		t.Errorf("expected no audit events, got %+v", fd.Bus.Events())
+	}
+}
+
+func TestProcessIdempotent(t *testing.T) {
+	fd, svc := setupProcess(t)
+	svc.Respond("PUT", "/sis/12345", 200, `{}`)
+	addWork(fd, "oid:work6", map[string]string{"source-id": "sis:12345"})
+
+	// a command notifies every time it is processed, the ledger stops the redelivery
+	idempotent := libracommon.Idempotent(deps, Process)
+	for range 2 {
+		err := idempotent(context.Background(), "msg-6", "test", busEvent(t, uvalibrabus.EventCommandSisNotify, "oid:work6"))
+		if err != nil {
+			t.Fatalf("expected success, got %s", err.Error())
+		}
+	}
+
+	if sent := sisRequests(svc); len(sent) != 1 {
+		t.Errorf("expected the repeated event to be skipped, got %d SIS requests", len(sent))
+	}
+	records := fd.Idempotency.Records()
+	if len(records) != 1 || records[0].Outcome != libracommon.IdempotencyProcessed {
+		t.Errorf("expected one processed ledger record, got %+v", records)
+	}
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libravirgodelete "github.com/uvalib/libra-virgo-delete"
+)
+
+func main() {
+	libracommon.RunCmdline(libravirgodelete.Process)
+}
+
+//
+// end of file
+//
@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libravirgodelete "github.com/uvalib/libra-virgo-delete"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libravirgodelete.Process)
+}
+
+//
+// end of file
+//
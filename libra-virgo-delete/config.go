@@ -1,14 +1,14 @@
-package main
+package libravirgodelete
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 	// upload configuration
-	BucketName        string // the bucket name
-	BucketKeyTemplate string // the bucket key template
+	BucketName        string `env:"BUCKET_NAME" required:"true"`         // the bucket name
+	BucketKeyTemplate string `env:"BUCKET_KEY_TEMPLATE" required:"true"` // the bucket key template
 }
 
 // loadConfiguration will load the service configuration from env/cmdline
@@ -17,18 +17,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.BucketName, err = ensureSetAndNonEmpty("BUCKET_NAME")
-	if err != nil {
-		return nil, err
-	}
-	cfg.BucketKeyTemplate, err = ensureSetAndNonEmpty("BUCKET_KEY_TEMPLATE")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Printf("[conf] BucketName        = [%s]\n", cfg.BucketName)
-	fmt.Printf("[conf] BucketKeyTemplate = [%s]\n", cfg.BucketKeyTemplate)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,7 +2,7 @@
 //
 //
 
-package main
+package libravirgodelete
 
 import (
 	"bytes"
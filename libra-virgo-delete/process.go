@@ -2,30 +2,36 @@
 // main message processing
 //
 
-package main
+package libravirgodelete
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/librabus-sdk/uvalibrabus"
 	"strings"
-	"time"
 )
 
-func process(messageId string, messageSrc string, rawMsg json.RawMessage) error {
+// the external dependencies, tests replace these with fakes
+var deps = libracommon.DefaultDeps()
+
+// Process handles a single bus event
+func Process(ctx context.Context, messageId string, messageSrc string, rawMsg json.RawMessage) error {
 
 	// convert to librabus event
 	ev, err := uvalibrabus.MakeBusEvent(rawMsg)
 	if err != nil {
-		fmt.Printf("ERROR: unmarshaling libra bus event (%s)\n", err.Error())
+		libracommon.LogError("unmarshaling libra bus event (%s)", err.Error())
 		return err
 	}
 
-	fmt.Printf("INFO: EVENT %s from %s -> %s\n", messageId, messageSrc, ev.String())
+	libracommon.LogInfo("EVENT %s from %s -> %s", messageId, messageSrc, ev.String())
 
 	// initial namespace validation
-	if ev.Namespace != libraEtdNamespace {
-		fmt.Printf("WARNING: unsupported namespace (%s), ignoring\n", ev.Namespace)
+	if ev.Namespace != libracommon.LibraEtdNamespace {
+		libracommon.LogWarning("unsupported namespace (%s), ignoring", ev.Namespace)
+		libracommon.IgnoreEvent(ctx)
 		return nil
 	}
 
@@ -36,34 +42,34 @@ func process(messageId string, messageSrc string, rawMsg json.RawMessage) error
 	}
 
 	// init the S3 client
-	s3, err := newS3Client()
+	s3, err := deps.Objects()
 	if err != nil {
-		fmt.Printf("ERROR: creating S3 client (%s)\n", err.Error())
+		libracommon.LogError("creating S3 client (%s)", err.Error())
 		return err
 	}
 
 	// render the document
 	buf, err := docRender(ev.Namespace, ev.Identifier)
 	if err != nil {
-		fmt.Printf("ERROR: rendering template (%s)\n", err.Error())
+		libracommon.LogError("rendering template (%s)", err.Error())
 		return err
 	}
 
 	// populate the key template
-	year := fmt.Sprintf("%04d", time.Now().Year())
+	year := fmt.Sprintf("%04d", deps.Now().Year())
 	bucketKey := strings.Replace(cfg.BucketKeyTemplate, "{:year}", year, 1)
 	bucketKey = strings.Replace(bucketKey, "{:namespace}", ev.Namespace, 1)
 	bucketKey = strings.Replace(bucketKey, "{:id}", ev.Identifier, 1)
 
 	// upload to S3
-	err = putS3(s3, cfg.BucketName, bucketKey, buf)
+	err = s3.PutObject(ctx, cfg.BucketName, bucketKey, buf)
 	if err != nil {
-		fmt.Printf("ERROR: uploading (%s)\n", err.Error())
+		libracommon.LogError("uploading (%s)", err.Error())
 		return err
 	}
 
 	// log the happy news
-	fmt.Printf("INFO: EVENT %s from %s processed OK\n", messageId, messageSrc)
+	libracommon.LogInfo("EVENT %s from %s processed OK", messageId, messageSrc)
 	return nil
 }
 
@@ -0,0 +1,20 @@
+//
+// main entry point for the cmdline build
+//
+
+//go:build cmdline
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libravirgo "github.com/uvalib/libra-virgo"
+)
+
+func main() {
+	libracommon.RunCmdline(libravirgo.Process)
+}
+
+//
+// end of file
+//
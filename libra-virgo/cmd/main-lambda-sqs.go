@@ -0,0 +1,20 @@
+//
+// main entry point when deployed as an SQS triggered lambda
+//
+
+//go:build lambda
+
+package main
+
+import (
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libravirgo "github.com/uvalib/libra-virgo"
+)
+
+func main() {
+	libracommon.StartSqsLambda(libravirgo.Process)
+}
+
+//
+// end of file
+//
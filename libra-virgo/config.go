@@ -1,17 +1,17 @@
-package main
+package libravirgo
 
 import (
-	"fmt"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 )
 
 // Config defines all of the service configuration parameters
 type Config struct {
 	// upload configuration
-	BucketName        string // the bucket name
-	BucketKeyTemplate string // the bucket key template
+	BucketName        string `env:"BUCKET_NAME" required:"true"`         // the bucket name
+	BucketKeyTemplate string `env:"BUCKET_KEY_TEMPLATE" required:"true"` // the bucket key template
 
 	// easystore proxy configuration
-	EsProxyUrl string // the easystore proxy endpoint
+	EsProxyUrl string `env:"ES_PROXY_URL" required:"true"` // the easystore proxy endpoint
 
 	// message bus configuration
 	//	BusName    string // the message bus name
@@ -23,28 +23,12 @@ func loadConfiguration() (*Config, error) {
 
 	var cfg Config
 
-	var err error
-	cfg.BucketName, err = ensureSetAndNonEmpty("BUCKET_NAME")
+	err := libracommon.LoadConfig(&cfg)
 	if err != nil {
 		return nil, err
 	}
-	cfg.BucketKeyTemplate, err = ensureSetAndNonEmpty("BUCKET_KEY_TEMPLATE")
-	if err != nil {
-		return nil, err
-	}
-
-	cfg.EsProxyUrl, err = ensureSetAndNonEmpty("ES_PROXY_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	//	cfg.BusName = envWithDefault("MESSAGE_BUS", "")
-
-	fmt.Printf("[conf] BucketName        = [%s]\n", cfg.BucketName)
-	fmt.Printf("[conf] BucketKeyTemplate = [%s]\n", cfg.BucketKeyTemplate)
 
-	fmt.Printf("[conf] EsProxyUrl        = [%s]\n", cfg.EsProxyUrl)
-	//	fmt.Printf("[conf] BusName           = [%s]\n", cfg.BusName)
+	libracommon.PrintConfig(cfg)
 
 	return &cfg, nil
 }
@@ -2,17 +2,17 @@
 //
 //
 
-package main
+package libravirgo
 
 import (
 	"bytes"
 	"embed"
 	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"github.com/uvalib/libra-metadata"
 	"strings"
 	"text/template"
-	"time"
 )
 
 // templates holds our templates
@@ -63,8 +63,8 @@ func renderEtd(cfg *Config, tmpl *template.Template, work uvaeasystore.EasyStore
 
 	// extract the metadata
 	if work.Metadata() == nil {
-		fmt.Printf("ERROR: unable to get metadata payload for ns/oid [%s/%s]\n", work.Namespace(), work.Id())
-		return nil, ErrNoMetadata
+		libracommon.LogError("unable to get metadata payload for ns/oid [%s/%s]", work.Namespace(), work.Id())
+		return nil, libracommon.ErrNoMetadata
 	}
 
 	md := work.Metadata()
@@ -74,7 +74,7 @@ func renderEtd(cfg *Config, tmpl *template.Template, work uvaeasystore.EasyStore
 	}
 	meta, err := librametadata.ETDWorkFromBytes(pl)
 	if err != nil {
-		return nil, err
+		return nil, libracommon.Permanent(err)
 	}
 
 	//	populate the attributes
@@ -86,7 +86,7 @@ func renderEtd(cfg *Config, tmpl *template.Template, work uvaeasystore.EasyStore
 		Work:          *meta,
 		Doi:           fields["doi"],
 		Id:            work.Id(),
-		IndexDateTime: time.Now().Format("20060102150405"),
+		IndexDateTime: deps.Now().Format("20060102150405"),
 		PubDate:       fields["publish-date"],
 		PubYear:       extractYYYY(fields["publish-date"]),
 		ReceivedDate:  extractYYYY(fields["create-date"]),
@@ -102,7 +102,7 @@ func renderEtd(cfg *Config, tmpl *template.Template, work uvaeasystore.EasyStore
 		return nil, err
 	}
 
-	fmt.Printf("%s\n", renderedBuffer.String())
+	libracommon.LogDebug("%s", renderedBuffer.String())
 	return renderedBuffer.Bytes(), nil
 }
 
@@ -2,13 +2,13 @@
 //
 //
 
-package main
+package libravirgo
 
 import (
 	"bytes"
 	"encoding/xml"
-	"fmt"
 	"github.com/uvalib/easystore/uvaeasystore"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
 	"regexp"
 	"strings"
 )
@@ -161,7 +161,7 @@ func XmlEncode(str string) string {
 	var b bytes.Buffer
 	err := xml.EscapeText(&b, []byte(str))
 	if err != nil {
-		fmt.Printf("ERROR: escaping (%s)\n", err.Error())
+		libracommon.LogError("escaping (%s)", err.Error())
 		return str
 	}
 	return string(b.Bytes())
@@ -0,0 +1,112 @@
+//
+// dispatch an event to a lambda by calling its event processor in-process. The lambdas run
+// one at a time, each with the environment of its route, and the events they publish are
+// captured in a local bus file
+//
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// dispatch runs the lambda for the event and returns any events it published
+func dispatch(ctx context.Context, busFile string, route Route, messageId string, record libracommon.LocalBusRecord) ([]libracommon.LocalBusRecord, error) {
+
+	process, found := lambdas[route.Lambda]
+	if found == false {
+		return nil, fmt.Errorf("unknown lambda [%s]", route.Lambda)
+	}
+
+	env := map[string]string{"AWS_LAMBDA_FUNCTION_NAME": route.Lambda}
+	for k, v := range route.Env {
+		env[k] = v
+	}
+	// last so that it wins
+	env["MESSAGE_BUS"] = libracommon.LocalBusPrefix + busFile
+
+	restore := setEnv(env)
+	runErr := invoke(ctx, process, messageId, record)
+	restore()
+
+	// events published before a failure are still delivered
+	published, err := readBus(busFile)
+	if err != nil {
+		return nil, err
+	}
+	// the next lambda starts with an empty bus
+	err = os.Truncate(busFile, 0)
+	if err != nil {
+		return nil, err
+	}
+	return published, runErr
+}
+
+// invoke the processor, a panic fails the event rather than the runner
+func invoke(ctx context.Context, process libracommon.EventProcessor, messageId string, record libracommon.LocalBusRecord) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic (%v)", r)
+		}
+	}()
+	return libracommon.ProcessEvent(ctx, process, messageId, record.Source, record.Event)
+}
+
+// setEnv sets the environment and returns a function restoring the previous values
+func setEnv(env map[string]string) func() {
+	previous := make(map[string]*string)
+	for k, v := range env {
+		if old, set := os.LookupEnv(k); set == true {
+			previous[k] = &old
+		} else {
+			previous[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, v := range previous {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// read the events published to a local bus file
+func readBus(filename string) ([]libracommon.LocalBusRecord, error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) == true {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]libracommon.LocalBusRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record libracommon.LocalBusRecord
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return records, fmt.Errorf("decoding local bus record (%s)", err.Error())
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+//
+// end of file
+//
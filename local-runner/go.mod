@@ -0,0 +1,109 @@
+module github.com/uvalib/libra-lambda/local-runner
+
+go 1.25.0
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.41.6
+	github.com/aws/aws-sdk-go-v2/config v1.32.16
+	github.com/aws/aws-sdk-go-v2/service/cloudwatchevents v1.32.24
+	github.com/uvalib/libra-aptrust v0.0.0
+	github.com/uvalib/libra-audit v0.0.0
+	github.com/uvalib/libra-doi v0.0.0
+	github.com/uvalib/libra-event-audit v0.0.0
+	github.com/uvalib/libra-index v0.0.0
+	github.com/uvalib/libra-index-delete v0.0.0
+	github.com/uvalib/libra-ingest v0.0.0
+	github.com/uvalib/libra-lambda/lambda-common v0.0.0
+	github.com/uvalib/libra-mailer v0.0.0
+	github.com/uvalib/libra-orcid v0.0.0
+	github.com/uvalib/libra-page-metrics v0.0.0
+	github.com/uvalib/libra-sis-notify v0.0.0
+	github.com/uvalib/libra-virgo v0.0.0
+	github.com/uvalib/libra-virgo-delete v0.0.0
+	github.com/uvalib/librabus-sdk/uvalibrabus v0.0.0-20260406142030-486f51674d88
+)
+
+require (
+	github.com/aquilax/truncate v1.0.1 // indirect
+	github.com/aws/aws-lambda-go v1.54.0 // indirect
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.7.9 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.19.15 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.18.22 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.22.16 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.4.22 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.7.22 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.4.23 // indirect
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.57.2 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.13.8 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.9.14 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.11.22 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.13.22 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.19.22 // indirect
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.100.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.41.6 // indirect
+	github.com/aws/aws-sdk-go-v2/service/signin v1.0.10 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sqs v1.42.26 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssm v1.68.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.30.16 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.35.20 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.42.0 // indirect
+	github.com/aws/smithy-go v1.25.1 // indirect
+	github.com/cenkalti/backoff/v5 v5.0.3 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.30.0 // indirect
+	github.com/lib/pq v1.12.3 // indirect
+	github.com/rs/xid v1.6.0 // indirect
+	github.com/uvalib/easystore/uvaeasystore v0.0.0-20260413184000-ac1e96bfa2b7 // indirect
+	github.com/uvalib/libra-metadata v0.0.0-20250513131340-aa4ee04ad7d1 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel v1.46.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.46.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.46.0 // indirect
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.46.0 // indirect
+	go.opentelemetry.io/otel/metric v1.46.0 // indirect
+	go.opentelemetry.io/otel/sdk v1.46.0 // indirect
+	go.opentelemetry.io/otel/trace v1.46.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.11.0 // indirect
+	golang.org/x/exp v0.0.0-20260410095643-746e56fc9e2f // indirect
+	golang.org/x/net v0.58.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20260819154853-08b0e4226688 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260819154853-08b0e4226688 // indirect
+	google.golang.org/grpc v1.83.1 // indirect
+	google.golang.org/protobuf v1.36.12 // indirect
+	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
+	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df // indirect
+)
+
+replace github.com/uvalib/libra-lambda/lambda-common => ../lambda-common
+
+replace github.com/uvalib/libra-aptrust => ../libra-aptrust
+
+replace github.com/uvalib/libra-audit => ../libra-audit
+
+replace github.com/uvalib/libra-doi => ../libra-doi
+
+replace github.com/uvalib/libra-event-audit => ../libra-event-audit
+
+replace github.com/uvalib/libra-index => ../libra-index
+
+replace github.com/uvalib/libra-index-delete => ../libra-index-delete
+
+replace github.com/uvalib/libra-ingest => ../libra-ingest
+
+replace github.com/uvalib/libra-mailer => ../libra-mailer
+
+replace github.com/uvalib/libra-orcid => ../libra-orcid
+
+replace github.com/uvalib/libra-page-metrics => ../libra-page-metrics
+
+replace github.com/uvalib/libra-sis-notify => ../libra-sis-notify
+
+replace github.com/uvalib/libra-virgo => ../libra-virgo
+
+replace github.com/uvalib/libra-virgo-delete => ../libra-virgo-delete
@@ -0,0 +1,56 @@
+//
+// the lambdas the runner can dispatch to, each is run in-process through its exported
+// event processor
+//
+
+package main
+
+import (
+	"slices"
+
+	libraaptrust "github.com/uvalib/libra-aptrust"
+	libraaudit "github.com/uvalib/libra-audit"
+	libradoi "github.com/uvalib/libra-doi"
+	libraeventaudit "github.com/uvalib/libra-event-audit"
+	libraindex "github.com/uvalib/libra-index"
+	libraindexdelete "github.com/uvalib/libra-index-delete"
+	libraingest "github.com/uvalib/libra-ingest"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	libramailer "github.com/uvalib/libra-mailer"
+	libraorcid "github.com/uvalib/libra-orcid"
+	librapagemetrics "github.com/uvalib/libra-page-metrics"
+	librasisnotify "github.com/uvalib/libra-sis-notify"
+	libravirgo "github.com/uvalib/libra-virgo"
+	libravirgodelete "github.com/uvalib/libra-virgo-delete"
+)
+
+// the event processors by lambda name
+var lambdas = map[string]libracommon.EventProcessor{
+	"libra-aptrust":      libraaptrust.Process,
+	"libra-audit":        libraaudit.Process,
+	"libra-doi":          libradoi.Process,
+	"libra-event-audit":  libraeventaudit.Process,
+	"libra-index":        libraindex.Process,
+	"libra-index-delete": libraindexdelete.Process,
+	"libra-ingest":       libraingest.Process,
+	"libra-mailer":       libramailer.Process,
+	"libra-orcid":        libraorcid.Process,
+	"libra-page-metrics": librapagemetrics.Process,
+	"libra-sis-notify":   librasisnotify.Process,
+	"libra-virgo":        libravirgo.Process,
+	"libra-virgo-delete": libravirgodelete.Process,
+}
+
+// lambdaNames returns the names of the known lambdas, sorted
+func lambdaNames() []string {
+	names := make([]string, 0, len(lambdas))
+	for name := range lambdas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+//
+// end of file
+//
@@ -0,0 +1,179 @@
+//
+// local runner, replays bus events against the lambdas. Each event is dispatched to every
+// lambda that subscribes to it and the events those lambdas publish are fed back in, so a
+// complete workflow can be run locally against stand-in services. With -rules the routes are
+// checked against the deployed event bridge rules instead
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+	"github.com/uvalib/librabus-sdk/uvalibrabus"
+)
+
+func main() {
+
+	var routesFile string
+	var eventsFile string
+	var source string
+	var maxEvents int
+	var rulesBus string
+	var update bool
+
+	flag.StringVar(&routesFile, "routes", "routes.json", "Routing rules")
+	flag.StringVar(&eventsFile, "events", "-", "Bus events to replay, - for stdin")
+	flag.StringVar(&source, "source", "local.runner", "Source of the replayed events")
+	flag.IntVar(&maxEvents, "maxevents", 1000, "Stop after this many events, guards against loops")
+	flag.StringVar(&rulesBus, "rules", "", "Check the routes against the deployed rules on this event bus")
+	flag.BoolVar(&update, "update", false, "With -rules, rewrite the routes from the deployed rules")
+	flag.Parse()
+
+	routes, err := loadRoutes(routesFile)
+	if err != nil {
+		libracommon.LogError("loading routes (%s)", err.Error())
+		os.Exit(1)
+	}
+
+	// interrupting stops processing cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(rulesBus) != 0 {
+		os.Exit(checkRules(ctx, rulesBus, routesFile, routes, update))
+	}
+
+	queue, err := readEvents(eventsFile, source)
+	if err != nil {
+		libracommon.LogError("reading events (%s)", err.Error())
+		os.Exit(1)
+	}
+
+	os.Exit(replay(ctx, routes, queue, maxEvents))
+}
+
+// replay the events and those the lambdas publish in turn, returns the exit status
+func replay(ctx context.Context, routes *Routes, queue []libracommon.LocalBusRecord, maxEvents int) int {
+
+	// published events are written here
+	busFile, err := os.CreateTemp("", "libra-local-bus-*.jsonl")
+	if err != nil {
+		libracommon.LogError("creating local bus (%s)", err.Error())
+		return 1
+	}
+	busFile.Close()
+	defer os.Remove(busFile.Name())
+
+	processed := 0
+	failed := 0
+	for len(queue) != 0 {
+		if processed == maxEvents {
+			libracommon.LogError("stopping after %d events, %d not processed", processed, len(queue))
+			return 1
+		}
+
+		record := queue[0]
+		queue = queue[1:]
+		processed++
+		messageId := fmt.Sprintf("local-%06d", processed)
+
+		subscribers := routes.subscribers(record.Event.EventName)
+		libracommon.LogInfo("EVENT %s from %s -> %s (%d subscriber(s))", messageId, record.Source, record.Event.String(), len(subscribers))
+
+		for _, r := range subscribers {
+			published, err := dispatch(ctx, busFile.Name(), r, messageId, record)
+			if err != nil {
+				libracommon.LogError("%s failed for %s (%s)", r.Lambda, messageId, err.Error())
+				failed++
+			}
+			for _, p := range published {
+				libracommon.LogInfo("%s published %s", r.Lambda, p.Event.String())
+			}
+			queue = append(queue, published...)
+		}
+	}
+
+	libracommon.LogInfo("replayed %d event(s), %d lambda failure(s)", processed, failed)
+	if failed != 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkRules compares the routes with the deployed rules, or rewrites them, and returns
+// the exit status
+func checkRules(ctx context.Context, busName string, routesFile string, routes *Routes, update bool) int {
+
+	api, err := newRulesApi()
+	if err != nil {
+		libracommon.LogError("creating event bridge client (%s)", err.Error())
+		return 1
+	}
+	deployed, err := deployedRoutes(ctx, api, busName)
+	if err != nil {
+		libracommon.LogError("reading the rules on %s (%s)", busName, err.Error())
+		return 1
+	}
+
+	if update == true {
+		err = saveRoutes(routesFile, mergeRoutes(routes, deployed))
+		if err != nil {
+			libracommon.LogError("writing routes (%s)", err.Error())
+			return 1
+		}
+		libracommon.LogInfo("updated %s from the rules on %s", routesFile, busName)
+		return 0
+	}
+
+	differences := compareRoutes(routes, deployed)
+	for _, d := range differences {
+		libracommon.LogWarning("%s", d)
+	}
+	if len(differences) != 0 {
+		libracommon.LogError("%s differs from the rules on %s in %d place(s), -update rewrites it", routesFile, busName, len(differences))
+		return 1
+	}
+	libracommon.LogInfo("%s matches the rules on %s", routesFile, busName)
+	return 0
+}
+
+// read a stream of bus events, either one per line or concatenated
+func readEvents(filename string, source string) ([]libracommon.LocalBusRecord, error) {
+
+	in := os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	records := make([]libracommon.LocalBusRecord, 0)
+	decoder := json.NewDecoder(in)
+	for {
+		var ev uvalibrabus.UvaBusEvent
+		err := decoder.Decode(&ev)
+		if errors.Is(err, io.EOF) == true {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, libracommon.LocalBusRecord{Source: source, Event: ev})
+	}
+}
+
+//
+// end of file
+//
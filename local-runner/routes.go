@@ -0,0 +1,77 @@
+//
+// the routing rules, which lambdas receive which events. These mirror the deployed
+// event bridge rules, -rules checks them against the rules on the bus
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// the wildcard event name
+const anyEvent = "*"
+
+// Route describes a lambda and the events it subscribes to
+type Route struct {
+	Lambda string            `json:"lambda"`        // the lambda directory name
+	Events []string          `json:"events"`        // event names, * for all events
+	Env    map[string]string `json:"env,omitempty"` // additional environment for the lambda
+}
+
+// Routes is the full set of routing rules
+type Routes struct {
+	Routes []Route `json:"routes"`
+}
+
+func loadRoutes(filename string) (*Routes, error) {
+
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes Routes
+	err = json.Unmarshal(buf, &routes)
+	if err != nil {
+		return nil, fmt.Errorf("decoding routes [%s] (%s)", filename, err.Error())
+	}
+
+	for _, r := range routes.Routes {
+		if len(r.Lambda) == 0 || len(r.Events) == 0 {
+			return nil, fmt.Errorf("incomplete route in [%s], a lambda and events are required", filename)
+		}
+		_, found := lambdas[r.Lambda]
+		if found == false {
+			return nil, fmt.Errorf("unknown lambda [%s] in [%s], expected one of %v", r.Lambda, filename, lambdaNames())
+		}
+	}
+	return &routes, nil
+}
+
+// subscribers returns the routes that receive the named event
+func (rs *Routes) subscribers(eventName string) []Route {
+	matched := make([]Route, 0)
+	for _, r := range rs.Routes {
+		if slices.Contains(r.Events, anyEvent) == true || slices.Contains(r.Events, eventName) == true {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// route returns the route of the lambda, nil when it has none
+func (rs *Routes) route(lambda string) *Route {
+	idx := slices.IndexFunc(rs.Routes, func(r Route) bool { return r.Lambda == lambda })
+	if idx == -1 {
+		return nil
+	}
+	return &rs.Routes[idx]
+}
+
+//
+// end of file
+//
@@ -0,0 +1,208 @@
+//
+// the deployed event bridge rules. The routes are checked against (or regenerated from) the
+// rules on the bus, each rule matches event names through its detail-type pattern and its
+// targets identify the lambdas by name
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	libracommon "github.com/uvalib/libra-lambda/lambda-common"
+)
+
+// the event bridge calls used to read the rules, tests replace these
+type rulesApi interface {
+	ListRules(ctx context.Context, in *cloudwatchevents.ListRulesInput, opts ...func(*cloudwatchevents.Options)) (*cloudwatchevents.ListRulesOutput, error)
+	ListTargetsByRule(ctx context.Context, in *cloudwatchevents.ListTargetsByRuleInput, opts ...func(*cloudwatchevents.Options)) (*cloudwatchevents.ListTargetsByRuleOutput, error)
+}
+
+func newRulesApi() (rulesApi, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatchevents.NewFromConfig(cfg), nil
+}
+
+// deployedRoutes builds the routes from the enabled rules on the bus
+func deployedRoutes(ctx context.Context, api rulesApi, busName string) (*Routes, error) {
+
+	events := make(map[string][]string)
+	var nextToken *string
+	for {
+		out, err := api.ListRules(ctx, &cloudwatchevents.ListRulesInput{EventBusName: aws.String(busName), NextToken: nextToken})
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range out.Rules {
+			// scheduled rules have no pattern
+			if rule.State != types.RuleStateEnabled || rule.EventPattern == nil {
+				continue
+			}
+			names, err := patternEvents(*rule.EventPattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule [%s] (%s)", aws.ToString(rule.Name), err.Error())
+			}
+			targets, err := ruleLambdas(ctx, api, busName, aws.ToString(rule.Name))
+			if err != nil {
+				return nil, err
+			}
+			for _, lambda := range targets {
+				for _, name := range names {
+					if slices.Contains(events[lambda], name) == false {
+						events[lambda] = append(events[lambda], name)
+					}
+				}
+			}
+		}
+		nextToken = out.NextToken
+		if nextToken == nil {
+			break
+		}
+	}
+
+	routes := Routes{Routes: make([]Route, 0)}
+	for _, lambda := range slices.Sorted(maps.Keys(events)) {
+		routes.Routes = append(routes.Routes, Route{Lambda: lambda, Events: events[lambda]})
+	}
+	return &routes, nil
+}
+
+// ruleLambdas returns the lambdas targeted by the rule, targets that are not one of our
+// lambdas are ignored
+func ruleLambdas(ctx context.Context, api rulesApi, busName string, ruleName string) ([]string, error) {
+
+	result := make([]string, 0)
+	var nextToken *string
+	for {
+		out, err := api.ListTargetsByRule(ctx, &cloudwatchevents.ListTargetsByRuleInput{EventBusName: aws.String(busName), Rule: aws.String(ruleName), NextToken: nextToken})
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range out.Targets {
+			lambda := targetLambda(aws.ToString(target.Arn))
+			if len(lambda) == 0 {
+				libracommon.LogWarning("rule [%s] target %s is not a known lambda, ignoring", ruleName, aws.ToString(target.Arn))
+				continue
+			}
+			result = append(result, lambda)
+		}
+		nextToken = out.NextToken
+		if nextToken == nil {
+			return result, nil
+		}
+	}
+}
+
+// patternEvents returns the event names matched by the detail-type of the pattern, a pattern
+// without one matches every event
+func patternEvents(pattern string) ([]string, error) {
+
+	var parsed struct {
+		DetailType []json.RawMessage `json:"detail-type"`
+	}
+	err := json.Unmarshal([]byte(pattern), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("decoding event pattern (%s)", err.Error())
+	}
+	if len(parsed.DetailType) == 0 {
+		return []string{anyEvent}, nil
+	}
+
+	names := make([]string, 0, len(parsed.DetailType))
+	for _, raw := range parsed.DetailType {
+		var name string
+		err = json.Unmarshal(raw, &name)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported detail-type matcher %s", string(raw))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// targetLambda identifies the lambda from a target ARN (the function or its queue), the
+// longest lambda name contained in the resource name wins so that libra-index-delete is
+// not mistaken for libra-index
+func targetLambda(arn string) string {
+	resource := arn[strings.LastIndexAny(arn, ":/")+1:]
+	match := ""
+	for _, name := range lambdaNames() {
+		if strings.Contains(resource, name) == true && len(name) > len(match) {
+			match = name
+		}
+	}
+	return match
+}
+
+// compareRoutes describes how the routes differ from the deployed ones
+func compareRoutes(routes *Routes, deployed *Routes) []string {
+
+	differences := make([]string, 0)
+	for _, r := range routes.Routes {
+		d := deployed.route(r.Lambda)
+		if d == nil {
+			differences = append(differences, fmt.Sprintf("%s is not the target of any deployed rule", r.Lambda))
+			continue
+		}
+		for _, name := range r.Events {
+			if slices.Contains(d.Events, name) == false {
+				differences = append(differences, fmt.Sprintf("%s is routed %s, the deployed rules do not", r.Lambda, name))
+			}
+		}
+		for _, name := range d.Events {
+			if slices.Contains(r.Events, name) == false {
+				differences = append(differences, fmt.Sprintf("%s is not routed %s, the deployed rules do", r.Lambda, name))
+			}
+		}
+	}
+	for _, d := range deployed.Routes {
+		if routes.route(d.Lambda) == nil {
+			differences = append(differences, fmt.Sprintf("%s has no route, the deployed rules target it", d.Lambda))
+		}
+	}
+	return differences
+}
+
+// mergeRoutes takes the events from the deployed routes, keeping the order and the
+// environment of the existing routes
+func mergeRoutes(routes *Routes, deployed *Routes) *Routes {
+
+	merged := Routes{Routes: make([]Route, 0, len(deployed.Routes))}
+	for _, r := range routes.Routes {
+		d := deployed.route(r.Lambda)
+		if d != nil {
+			merged.Routes = append(merged.Routes, Route{Lambda: r.Lambda, Events: d.Events, Env: r.Env})
+		}
+	}
+	for _, d := range deployed.Routes {
+		if routes.route(d.Lambda) == nil {
+			merged.Routes = append(merged.Routes, d)
+		}
+	}
+	return &merged
+}
+
+func saveRoutes(filename string, routes *Routes) error {
+	buf, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(buf, '\n'), 0644)
+}
+
+//
+// end of file
+//
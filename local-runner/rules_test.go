@@ -0,0 +1,134 @@
+//
+// tests for reading the deployed rules, against a stand-in event bridge
+//
+
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+)
+
+// the rules, each page of rules is returned in turn
+type testRules struct {
+	pages   [][]types.Rule
+	targets map[string][]string
+}
+
+func (tr *testRules) ListRules(ctx context.Context, in *cloudwatchevents.ListRulesInput, opts ...func(*cloudwatchevents.Options)) (*cloudwatchevents.ListRulesOutput, error) {
+	page := 0
+	if in.NextToken != nil {
+		page = int(aws.ToString(in.NextToken)[0] - '0')
+	}
+	out := cloudwatchevents.ListRulesOutput{Rules: tr.pages[page]}
+	if page+1 < len(tr.pages) {
+		out.NextToken = aws.String(string(rune('0' + page + 1)))
+	}
+	return &out, nil
+}
+
+func (tr *testRules) ListTargetsByRule(ctx context.Context, in *cloudwatchevents.ListTargetsByRuleInput, opts ...func(*cloudwatchevents.Options)) (*cloudwatchevents.ListTargetsByRuleOutput, error) {
+	out := cloudwatchevents.ListTargetsByRuleOutput{}
+	for _, arn := range tr.targets[aws.ToString(in.Rule)] {
+		out.Targets = append(out.Targets, types.Target{Arn: aws.String(arn)})
+	}
+	return &out, nil
+}
+
+func testRule(name string, pattern string) types.Rule {
+	rule := types.Rule{Name: aws.String(name), State: types.RuleStateEnabled}
+	if len(pattern) != 0 {
+		rule.EventPattern = aws.String(pattern)
+	}
+	return rule
+}
+
+func TestDeployedRoutes(t *testing.T) {
+	api := &testRules{
+		pages: [][]types.Rule{
+			{
+				testRule("all", `{"source":[{"prefix":""}]}`),
+				testRule("publish", `{"detail-type":["workflow.work.publish"]}`),
+				testRule("schedule", ""),
+			},
+			{
+				testRule("delete", `{"detail-type":["storage.object.delete","workflow.work.publish"]}`),
+				{Name: aws.String("disabled"), State: types.RuleStateDisabled, EventPattern: aws.String(`{"detail-type":["command.work.reindex"]}`)},
+			},
+		},
+		targets: map[string][]string{
+			"all":      {"arn:aws:lambda:us-east-1:123456789012:function:uva-libra-event-audit-production"},
+			"publish":  {"arn:aws:sqs:us-east-1:123456789012:uva-libra-index-production", "arn:aws:sqs:us-east-1:123456789012:uva-libra-doi-production"},
+			"schedule": {"arn:aws:lambda:us-east-1:123456789012:function:uva-libra-schedule-production"},
+			"delete":   {"arn:aws:sqs:us-east-1:123456789012:uva-libra-index-delete-production", "arn:aws:sns:us-east-1:123456789012:someone-else"},
+			"disabled": {"arn:aws:sqs:us-east-1:123456789012:uva-libra-index-production"},
+		},
+	}
+
+	deployed, err := deployedRoutes(context.Background(), api, "the-bus")
+	if err != nil {
+		t.Fatalf("expected the routes, got %v", err)
+	}
+	expected := map[string][]string{
+		"libra-doi":          {"workflow.work.publish"},
+		"libra-event-audit":  {anyEvent},
+		"libra-index":        {"workflow.work.publish"},
+		"libra-index-delete": {"storage.object.delete", "workflow.work.publish"},
+	}
+	if len(deployed.Routes) != len(expected) {
+		t.Errorf("expected %d routes, got %+v", len(expected), deployed.Routes)
+	}
+	for lambda, events := range expected {
+		r := deployed.route(lambda)
+		if r == nil || slices.Equal(r.Events, events) == false {
+			t.Errorf("%s expected %v, got %+v", lambda, events, r)
+		}
+	}
+}
+
+func TestDeployedRoutesUnsupportedPattern(t *testing.T) {
+	api := &testRules{
+		pages:   [][]types.Rule{{testRule("prefix", `{"detail-type":[{"prefix":"storage."}]}`)}},
+		targets: map[string][]string{"prefix": {"arn:aws:sqs:us-east-1:123456789012:uva-libra-index-production"}},
+	}
+	_, err := deployedRoutes(context.Background(), api, "the-bus")
+	if err == nil {
+		t.Errorf("expected a prefix matcher to be rejected")
+	}
+}
+
+func TestCompareRoutes(t *testing.T) {
+	routes := &Routes{Routes: []Route{
+		{Lambda: "libra-doi", Events: []string{"workflow.work.publish", "command.work.doisync"}, Env: map[string]string{"DOI_BASE_URL": "http://localhost"}},
+		{Lambda: "libra-orcid", Events: []string{"workflow.work.publish"}},
+	}}
+	deployed := &Routes{Routes: []Route{
+		{Lambda: "libra-doi", Events: []string{"workflow.work.publish", "storage.object.delete"}},
+		{Lambda: "libra-index", Events: []string{"workflow.work.publish"}},
+	}}
+
+	differences := compareRoutes(routes, deployed)
+	if len(differences) != 4 {
+		t.Errorf("expected 4 differences, got %q", differences)
+	}
+	if len(compareRoutes(deployed, deployed)) != 0 {
+		t.Errorf("expected identical routes to match")
+	}
+
+	merged := mergeRoutes(routes, deployed)
+	if len(compareRoutes(merged, deployed)) != 0 {
+		t.Errorf("expected the merged routes to match the deployed ones, got %+v", merged.Routes)
+	}
+	if merged.Routes[0].Lambda != "libra-doi" || merged.Routes[0].Env["DOI_BASE_URL"] != "http://localhost" {
+		t.Errorf("expected the order and environment to be kept, got %+v", merged.Routes)
+	}
+}
+
+//
+// end of file
+//